@@ -0,0 +1,415 @@
+// Package client is a Go client for the RocksDB service. It wraps the
+// generated gRPC stubs with typed methods, a pool of connections, retries of
+// idempotent calls and a single error type for failures reported either as
+// gRPC status or in a response's error field.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	pb "rocksdb-service/api/proto"
+)
+
+// DefaultTimeout is the deadline given to unary calls whose context has
+// none
+const DefaultTimeout = 10 * time.Second
+
+// Options configures a Client. The zero value connects over plaintext with
+// one connection, the default timeout and the default retry policy.
+type Options struct {
+	// TLS enables TLS with this configuration, e.g. from
+	// tlsutil.ClientConfig. Nil connects over plaintext.
+	TLS *tls.Config
+	// Token is sent as a bearer token with every call, if set
+	Token string
+	// PoolSize is the number of connections calls are spread over, 1 if
+	// zero. More connections help clients with many concurrent streams.
+	PoolSize int
+	// Timeout is the deadline of unary calls, including retries, whose
+	// context has none: DefaultTimeout if zero, none if negative. Scans are
+	// only bounded by their context.
+	Timeout time.Duration
+	// Retry controls retries of idempotent calls: Get, Delete, Scan and
+	// ListDatabases. The zero value uses DefaultRetryPolicy.
+	Retry RetryPolicy
+	// DialOptions are passed to grpc.NewClient after the client's own
+	DialOptions []grpc.DialOption
+}
+
+// Item is a key-value pair
+type Item struct {
+	Key     string
+	Value   []byte
+	Version uint64
+}
+
+// KV is the interface of Client. It is also implemented by the in-process
+// client of package rocksdb-service/api/embedded, so code written against
+// KV can switch between a remote server and local databases by
+// configuration.
+type KV interface {
+	Get(ctx context.Context, database, key string) (*Item, error)
+	Put(ctx context.Context, database, key string, value []byte) (uint64, error)
+	PutIfVersion(ctx context.Context, database, key string, value []byte, version uint64) (uint64, error)
+	Delete(ctx context.Context, database, key string) error
+	Increment(ctx context.Context, database string, inc Increment) (*Counter, error)
+	IncrementMany(ctx context.Context, database string, incs []Increment) ([]Counter, error)
+	GetCounter(ctx context.Context, database, key string) (*Counter, error)
+	Scan(ctx context.Context, database string, opts ScanOptions) iter.Seq2[*Item, error]
+	ListDatabases(ctx context.Context) ([]Database, error)
+	Close() error
+}
+
+var _ KV = (*Client)(nil)
+
+// Client calls a RocksDB service. It is safe for concurrent use.
+type Client struct {
+	conns   []*grpc.ClientConn
+	stubs   []pb.RocksDBServiceClient
+	next    atomic.Uint32
+	timeout time.Duration
+	retry   RetryPolicy
+}
+
+// New returns a client of the server at addr. Connections are established
+// lazily, on the first call.
+func New(addr string, opts Options) (*Client, error) {
+	creds := insecure.NewCredentials()
+	if opts.TLS != nil {
+		creds = credentials.NewTLS(opts.TLS)
+	}
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if opts.Token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerToken(opts.Token)))
+	}
+	dialOpts = append(dialOpts, opts.DialOptions...)
+
+	c := &Client{
+		timeout: opts.Timeout,
+		retry:   opts.Retry,
+	}
+	if c.timeout == 0 {
+		c.timeout = DefaultTimeout
+	}
+	if c.retry.MaxAttempts == 0 {
+		c.retry = DefaultRetryPolicy
+	}
+
+	for range max(opts.PoolSize, 1) {
+		conn, err := grpc.NewClient(addr, dialOpts...)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to create connection: %w", err)
+		}
+		c.conns = append(c.conns, conn)
+		c.stubs = append(c.stubs, pb.NewRocksDBServiceClient(conn))
+	}
+	return c, nil
+}
+
+// Close closes all connections
+func (c *Client) Close() error {
+	var errs []error
+	for _, conn := range c.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Stub returns a generated client on one of the pooled connections, for
+// RPCs without a typed method
+func (c *Client) Stub() pb.RocksDBServiceClient {
+	return c.stubs[int(c.next.Add(1))%len(c.stubs)]
+}
+
+// withTimeout applies the default timeout to a context without a deadline
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout < 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// Get returns the value of key. A missing key is reported as an error
+// matching ErrNotFound.
+func (c *Client) Get(ctx context.Context, database, key string) (*Item, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var resp *pb.GetResponse
+	err := c.retry.do(ctx, func() (err error) {
+		resp, err = c.Stub().Get(ctx, &pb.GetRequest{DatabaseName: database, Key: key})
+		return err
+	})
+	if err != nil {
+		return nil, statusError("Get", err)
+	}
+	if resp.Error != "" {
+		return nil, responseError("Get", resp.Error)
+	}
+	if !resp.Found {
+		return nil, notFoundError("Get", key)
+	}
+	return &Item{Key: key, Value: resp.Value, Version: resp.Version}, nil
+}
+
+// Put stores value under key and returns its new version. Puts are not
+// retried, since a retry after a lost response would write a new version.
+func (c *Client) Put(ctx context.Context, database, key string, value []byte) (uint64, error) {
+	return c.put(ctx, &pb.PutRequest{DatabaseName: database, Key: key, Value: value})
+}
+
+// PutIfVersion stores value under key only if the key's current version is
+// version, 0 meaning the key must not exist. A mismatch is reported as an
+// error matching ErrConditionFailed.
+func (c *Client) PutIfVersion(ctx context.Context, database, key string, value []byte, version uint64) (uint64, error) {
+	return c.put(ctx, &pb.PutRequest{DatabaseName: database, Key: key, Value: value, IfVersion: &version})
+}
+
+func (c *Client) put(ctx context.Context, req *pb.PutRequest) (uint64, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.Stub().Put(ctx, req)
+	if err != nil {
+		return 0, statusError("Put", err)
+	}
+	if !resp.Success {
+		return 0, responseError("Put", resp.Error)
+	}
+	return resp.Version, nil
+}
+
+// Delete removes key. Deleting a missing key succeeds.
+func (c *Client) Delete(ctx context.Context, database, key string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var resp *pb.DeleteResponse
+	err := c.retry.do(ctx, func() (err error) {
+		resp, err = c.Stub().Delete(ctx, &pb.DeleteRequest{DatabaseName: database, Key: key})
+		return err
+	})
+	if err != nil {
+		return statusError("Delete", err)
+	}
+	if !resp.Success {
+		return responseError("Delete", resp.Error)
+	}
+	return nil
+}
+
+// Increment adds Delta to the counter Key. Min and Max, if set, bound the
+// new value.
+type Increment struct {
+	Key      string
+	Delta    int64
+	Min, Max *int64
+}
+
+// Counter is the value of a counter. Counters are stored as 8-byte
+// big-endian integers, and a missing counter has value 0 and version 0.
+type Counter struct {
+	Key     string
+	Value   int64
+	Version uint64
+}
+
+// Increment adds inc.Delta to a counter and returns its new value. An
+// increment out of the int64 range or inc's bounds fails with an error
+// matching ErrConditionFailed, whose Condition holds the current value.
+// Increments are not retried, since a retry after a lost response would
+// apply the delta twice.
+func (c *Client) Increment(ctx context.Context, database string, inc Increment) (*Counter, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.Stub().Increment(ctx, &pb.IncrementRequest{
+		DatabaseName: database,
+		Key:          inc.Key,
+		Delta:        inc.Delta,
+		Min:          inc.Min,
+		Max:          inc.Max,
+	})
+	if err != nil {
+		return nil, statusError("Increment", err)
+	}
+	if !resp.Success {
+		return nil, responseError("Increment", resp.Error)
+	}
+	return &Counter{Key: inc.Key, Value: resp.Value, Version: resp.Version}, nil
+}
+
+// IncrementMany applies incs atomically, in order, and returns the new
+// values. If any increment fails, none is applied.
+func (c *Client) IncrementMany(ctx context.Context, database string, incs []Increment) ([]Counter, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	req := &pb.IncrementManyRequest{DatabaseName: database, Increments: make([]*pb.CounterIncrement, len(incs))}
+	for i, inc := range incs {
+		req.Increments[i] = &pb.CounterIncrement{Key: inc.Key, Delta: inc.Delta, Min: inc.Min, Max: inc.Max}
+	}
+	resp, err := c.Stub().IncrementMany(ctx, req)
+	if err != nil {
+		return nil, statusError("IncrementMany", err)
+	}
+	if !resp.Success {
+		return nil, responseError("IncrementMany", resp.Error)
+	}
+	counters := make([]Counter, len(resp.Counters))
+	for i, counter := range resp.Counters {
+		counters[i] = Counter{Key: counter.Key, Value: counter.Value, Version: counter.Version}
+	}
+	return counters, nil
+}
+
+// GetCounter returns the value of a counter, 0 if it does not exist
+func (c *Client) GetCounter(ctx context.Context, database, key string) (*Counter, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var resp *pb.GetCounterResponse
+	err := c.retry.do(ctx, func() (err error) {
+		resp, err = c.Stub().GetCounter(ctx, &pb.GetCounterRequest{DatabaseName: database, Key: key})
+		return err
+	})
+	if err != nil {
+		return nil, statusError("GetCounter", err)
+	}
+	if resp.Error != "" {
+		return nil, responseError("GetCounter", resp.Error)
+	}
+	return &Counter{Key: key, Value: resp.Value, Version: resp.Version}, nil
+}
+
+// ScanOptions selects the items returned by Scan
+type ScanOptions struct {
+	// Prefix restricts the scan to keys with this prefix, if set
+	Prefix string
+	// Start is the first key, inclusive, and End the last key, exclusive.
+	// Either may be empty for an open range.
+	Start, End string
+	// Limit is the maximum number of items, 0 for no limit
+	Limit uint64
+	// KeysOnly omits values
+	KeysOnly bool
+}
+
+// Scan returns an iterator over the items of database in key order. If the
+// stream fails with a retryable error, it resumes after the last key
+// received. Iteration stops after the first error.
+//
+//	for item, err := range c.Scan(ctx, "users", client.ScanOptions{Prefix: "user:"}) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *Client) Scan(ctx context.Context, database string, opts ScanOptions) iter.Seq2[*Item, error] {
+	return func(yield func(*Item, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		req := &pb.ScanRequest{
+			DatabaseName: database,
+			Prefix:       opts.Prefix,
+			StartKey:     opts.Start,
+			EndKey:       opts.End,
+			Limit:        opts.Limit,
+			KeysOnly:     opts.KeysOnly,
+		}
+		var received uint64
+		stop := false
+		err := c.retry.do(ctx, func() error {
+			if opts.Limit > 0 && received == opts.Limit {
+				return nil
+			}
+			stream, err := c.Stub().Scan(ctx, req)
+			if err != nil {
+				return err
+			}
+			for {
+				resp, err := stream.Recv()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				// Resume after this key if the stream fails later
+				received++
+				req.StartKey = resp.Key + "\x00"
+				if opts.Limit > 0 {
+					req.Limit = opts.Limit - received
+				}
+
+				if resp.Error != "" {
+					yield(nil, responseError("Scan", resp.Error))
+					stop = true
+					return nil
+				}
+				if !yield(&Item{Key: resp.Key, Value: resp.Value, Version: resp.Version}, nil) {
+					stop = true
+					return nil
+				}
+			}
+		})
+		if err != nil && !stop {
+			yield(nil, statusError("Scan", err))
+		}
+	}
+}
+
+// Database describes a database or alias on the server
+type Database struct {
+	Name string
+	// AliasTarget is the database an alias points at, empty for databases
+	AliasTarget string
+	Open        bool
+}
+
+// ListDatabases lists the databases and aliases on the server
+func (c *Client) ListDatabases(ctx context.Context) ([]Database, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var resp *pb.ListDatabasesResponse
+	err := c.retry.do(ctx, func() (err error) {
+		resp, err = c.Stub().ListDatabases(ctx, &pb.ListDatabasesRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, statusError("ListDatabases", err)
+	}
+	databases := make([]Database, len(resp.Databases))
+	for i, d := range resp.Databases {
+		databases[i] = Database{Name: d.Name, AliasTarget: d.AliasTarget, Open: d.Open}
+	}
+	return databases, nil
+}
+
+// bearerToken sends a bearer token with every call
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity allows tokens over plaintext, like the
+// command-line client, for servers behind a TLS-terminating proxy or on a
+// local socket
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
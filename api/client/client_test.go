@@ -0,0 +1,208 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+)
+
+var testRetry = client.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+	Multiplier:     2,
+}
+
+// faults injects UNAVAILABLE errors into calls of the server
+type faults struct {
+	mu sync.Mutex
+	// unary is the number of unary calls left to fail
+	unary int
+	// scanAfter, if positive, fails the next Scan after sending that many
+	// items
+	scanAfter int
+	// scans records the Scan requests received
+	scans []*pb.ScanRequest
+}
+
+func (f *faults) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	f.mu.Lock()
+	fail := f.unary > 0
+	if fail {
+		f.unary--
+	}
+	f.mu.Unlock()
+	if fail {
+		return nil, status.Error(codes.Unavailable, "injected failure")
+	}
+	return handler(ctx, req)
+}
+
+func (f *faults) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !strings.HasSuffix(info.FullMethod, "/Scan") {
+		return handler(srv, ss)
+	}
+	f.mu.Lock()
+	after := f.scanAfter
+	f.scanAfter = 0
+	f.mu.Unlock()
+
+	fs := &faultyStream{ServerStream: ss, faults: f, after: after}
+	err := handler(srv, fs)
+	if fs.failed {
+		return status.Error(codes.Unavailable, "injected failure")
+	}
+	return err
+}
+
+// faultyStream records Scan requests and fails after sending a number of
+// messages
+type faultyStream struct {
+	grpc.ServerStream
+	faults *faults
+	after  int
+	sent   int
+	failed bool
+}
+
+func (s *faultyStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if req, ok := m.(*pb.ScanRequest); ok && err == nil {
+		s.faults.mu.Lock()
+		s.faults.scans = append(s.faults.scans, proto.Clone(req).(*pb.ScanRequest))
+		s.faults.mu.Unlock()
+	}
+	return err
+}
+
+func (s *faultyStream) SendMsg(m any) error {
+	if s.after > 0 && s.sent == s.after {
+		s.failed = true
+		return errors.New("injected failure")
+	}
+	s.sent++
+	return s.ServerStream.SendMsg(m)
+}
+
+func newTestClient(t *testing.T) (*client.Client, *faults) {
+	t.Helper()
+	f := &faults{}
+	srv, err := embedded.NewServer(embedded.Options{},
+		grpc.ChainUnaryInterceptor(f.Unary),
+		grpc.ChainStreamInterceptor(f.Stream),
+	)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	c, err := srv.Client(client.Options{Retry: testRetry})
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, f
+}
+
+func TestGetRetries(t *testing.T) {
+	ctx := context.Background()
+	c, f := newTestClient(t)
+	if _, err := c.Put(ctx, "db", "k", []byte("v")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	f.unary = testRetry.MaxAttempts - 1
+	if item, err := c.Get(ctx, "db", "k"); err != nil || string(item.Value) != "v" {
+		t.Errorf("Get after %d failures = %+v, %v; want v", testRetry.MaxAttempts-1, item, err)
+	}
+	f.unary = testRetry.MaxAttempts
+	if _, err := c.Get(ctx, "db", "k"); status.Code(err) != codes.Unavailable {
+		t.Errorf("Get failing every attempt: %v, want Unavailable", err)
+	}
+}
+
+func TestScanResumes(t *testing.T) {
+	ctx := context.Background()
+	c, f := newTestClient(t)
+	var keys []string
+	for i := range 10 {
+		key := fmt.Sprintf("key%02d", i)
+		keys = append(keys, key)
+		if _, err := c.Put(ctx, "db", key, []byte("v")); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		limit uint64
+		want  []string
+	}{
+		{"no limit", 0, keys},
+		{"limit", 5, keys[:5]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f.scanAfter = 3
+			f.scans = nil
+			var got []string
+			for item, err := range c.Scan(ctx, "db", client.ScanOptions{Limit: tt.limit}) {
+				if err != nil {
+					t.Fatalf("Scan: %v", err)
+				}
+				got = append(got, item.Key)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Scan = %v, want %v", got, tt.want)
+			}
+
+			if len(f.scans) != 2 {
+				t.Fatalf("%d Scan calls, want 2", len(f.scans))
+			}
+			resumed := f.scans[1]
+			if resumed.StartKey != keys[2]+"\x00" {
+				t.Errorf("resumed at %q, want after %q", resumed.StartKey, keys[2])
+			}
+			if tt.limit > 0 && resumed.Limit != tt.limit-3 {
+				t.Errorf("resumed with limit %d, want %d", resumed.Limit, tt.limit-3)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	_, err := c.Get(ctx, "db", "missing")
+	if !errors.Is(err, client.ErrNotFound) || errors.Is(err, client.ErrConditionFailed) {
+		t.Errorf("Get of a missing key: %v, want ErrNotFound", err)
+	}
+
+	version, err := c.Put(ctx, "db", "k", []byte("v"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, err = c.PutIfVersion(ctx, "db", "k", []byte("w"), version+1)
+	if !errors.Is(err, client.ErrConditionFailed) || errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("PutIfVersion with a wrong version: %v, want ErrConditionFailed", err)
+	}
+	var cerr *client.Error
+	if !errors.As(err, &cerr) || cerr.Op != "Put" || cerr.Condition.GetCurrentVersion() != version {
+		t.Errorf("error = %#v, want the current version %d", err, version)
+	}
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("status.Code = %s, want FailedPrecondition", status.Code(err))
+	}
+}
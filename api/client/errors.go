@@ -0,0 +1,78 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+)
+
+var (
+	// ErrNotFound matches errors for missing keys
+	ErrNotFound = errors.New("key not found")
+	// ErrConditionFailed matches errors for conditional writes whose
+	// condition did not hold
+	ErrConditionFailed = errors.New("condition failed")
+)
+
+// Error is returned for every failure of a call, whether the server
+// reported it as a gRPC status or in the error field of a response.
+// Response error fields are reported with codes.Internal. Error implements
+// GRPCStatus, so status.Code works on it too.
+type Error struct {
+	// Op is the RPC that failed, e.g. "Put"
+	Op      string
+	Code    codes.Code
+	Message string
+	// Condition holds the current state of the key when a conditional
+	// write failed, if the server reported it
+	Condition *pb.ConditionFailure
+
+	status *status.Status
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed: %s (%s)", e.Op, e.Message, e.Code)
+}
+
+// GRPCStatus returns the status the server replied with
+func (e *Error) GRPCStatus() *status.Status {
+	if e.status != nil {
+		return e.status
+	}
+	return status.New(e.Code, e.Message)
+}
+
+// Is matches ErrNotFound and ErrConditionFailed
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == codes.NotFound
+	case ErrConditionFailed:
+		return e.Code == codes.FailedPrecondition && e.Condition != nil
+	}
+	return false
+}
+
+// statusError converts an error returned by a gRPC call
+func statusError(op string, err error) error {
+	st := status.Convert(err)
+	e := &Error{Op: op, Code: st.Code(), Message: st.Message(), status: st}
+	for _, detail := range st.Details() {
+		if cond, ok := detail.(*pb.ConditionFailure); ok {
+			e.Condition = cond
+		}
+	}
+	return e
+}
+
+// responseError converts the error field of a response
+func responseError(op, message string) error {
+	return &Error{Op: op, Code: codes.Internal, Message: message}
+}
+
+func notFoundError(op, key string) error {
+	return &Error{Op: op, Code: codes.NotFound, Message: fmt.Sprintf("key %q not found", key)}
+}
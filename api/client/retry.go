@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy controls retries of idempotent calls. Calls are retried on
+// UNAVAILABLE, ABORTED and RESOURCE_EXHAUSTED, waiting an exponentially
+// growing, jittered backoff, or the delay requested by the server's
+// RetryInfo if longer.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first; 1 disables
+	// retries
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the first delay
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each attempt
+	Multiplier float64
+}
+
+// DefaultRetryPolicy is used when Options.Retry is the zero value
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 50 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+}
+
+// do calls call until it succeeds, fails with an error that is not
+// retryable, runs out of attempts or ctx is done
+func (p RetryPolicy) do(ctx context.Context, call func() error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		// Full jitter spreads out clients retrying after the same failure
+		delay := time.Duration(rand.Int64N(int64(backoff) + 1))
+		if requested := retryDelay(err); requested > delay {
+			delay = requested
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff = min(time.Duration(float64(backoff)*p.Multiplier), p.MaxBackoff)
+	}
+}
+
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns the delay requested by a RetryInfo detail, such as the
+// one sent when a quota is exceeded
+func retryDelay(err error) time.Duration {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.RetryDelay.AsDuration()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var testPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     2 * time.Millisecond,
+	Multiplier:     2,
+}
+
+// failing returns a call failing with errs in turn and then succeeding,
+// and a pointer to the number of calls made
+func failing(errs ...error) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	}, &calls
+}
+
+func TestRetryPolicy(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{"success", nil, 1, codes.OK},
+		{"retryable errors", []error{unavailable, status.Error(codes.Aborted, ""), status.Error(codes.ResourceExhausted, "")}, 4, codes.OK},
+		{"out of attempts", []error{unavailable, unavailable, unavailable, unavailable, unavailable}, 4, codes.Unavailable},
+		{"not retryable", []error{status.Error(codes.InvalidArgument, "bad")}, 1, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call, calls := failing(tt.errs...)
+			err := testPolicy.do(context.Background(), call)
+			if status.Code(err) != tt.wantCode || *calls != tt.wantCalls {
+				t.Errorf("do = %v after %d calls, want %s after %d", err, *calls, tt.wantCode, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyHonorsRetryInfo(t *testing.T) {
+	const requested = 50 * time.Millisecond
+	st, err := status.New(codes.ResourceExhausted, "quota exceeded").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(requested),
+	})
+	if err != nil {
+		t.Fatalf("WithDetails: %v", err)
+	}
+
+	call, calls := failing(st.Err())
+	start := time.Now()
+	if err := testPolicy.do(context.Background(), call); err != nil || *calls != 2 {
+		t.Fatalf("do = %v after %d calls, want success after 2", err, *calls)
+	}
+	if elapsed := time.Since(start); elapsed < requested {
+		t.Errorf("retried after %v, before the requested %v", elapsed, requested)
+	}
+}
+
+func TestRetryPolicyStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := testPolicy
+	policy.InitialBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+
+	calls := 0
+	err := policy.do(ctx, func() error {
+		calls++
+		cancel()
+		return status.Error(codes.Unavailable, "unavailable")
+	})
+	if status.Code(err) != codes.Unavailable || calls != 1 {
+		t.Errorf("do = %v after %d calls, want the last error after 1", err, calls)
+	}
+}
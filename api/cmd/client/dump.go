@@ -0,0 +1,573 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	pb "rocksdb-service/api/proto"
+)
+
+// binaryMagic starts every binary dump
+const binaryMagic = "RDBDUMP1"
+
+// maxBinaryField bounds the key and value lengths read from a binary dump,
+// so a corrupt file fails instead of exhausting memory
+const maxBinaryField = 256 << 20
+
+// dumpOptions configures export and import
+type dumpOptions struct {
+	// file is the dump path, "-" for stdout or stdin
+	file string
+	// format is jsonl, csv or binary; detected from the file extension if
+	// empty
+	format string
+	// encoding is the encoding of values in text formats: base64, hex or
+	// text
+	encoding string
+	// gzip compresses the dump; also enabled by a .gz extension
+	gzip bool
+	// prefix, start and end select the keys exported or imported; end is
+	// exclusive
+	prefix, start, end string
+	// batchSize is the StreamWrite batch size requested on import
+	batchSize int
+}
+
+// resolve fills the format and compression from the file extension
+func (o *dumpOptions) resolve() error {
+	name := o.file
+	if base, ok := strings.CutSuffix(name, ".gz"); ok {
+		o.gzip = true
+		name = base
+	}
+	if o.format == "" {
+		switch filepath.Ext(name) {
+		case ".jsonl", ".ndjson":
+			o.format = "jsonl"
+		case ".csv":
+			o.format = "csv"
+		case ".bin":
+			o.format = "binary"
+		default:
+			return fmt.Errorf("cannot detect the format of %q: use -format jsonl, csv or binary", o.file)
+		}
+	}
+	switch o.format {
+	case "jsonl", "csv", "binary":
+	default:
+		return fmt.Errorf("unknown format %q: use jsonl, csv or binary", o.format)
+	}
+	return nil
+}
+
+// inRange reports whether key is selected by the prefix and range filters
+func (o *dumpOptions) inRange(key string) bool {
+	return strings.HasPrefix(key, o.prefix) &&
+		(o.start == "" || key >= o.start) &&
+		(o.end == "" || key < o.end)
+}
+
+// valueCodec encodes values in text formats
+type valueCodec struct {
+	encode func([]byte) (string, error)
+	decode func(string) ([]byte, error)
+}
+
+func newValueCodec(name string) (valueCodec, error) {
+	switch name {
+	case "base64":
+		return valueCodec{
+			encode: func(b []byte) (string, error) { return base64.StdEncoding.EncodeToString(b), nil },
+			decode: base64.StdEncoding.DecodeString,
+		}, nil
+	case "hex":
+		return valueCodec{
+			encode: func(b []byte) (string, error) { return hex.EncodeToString(b), nil },
+			decode: hex.DecodeString,
+		}, nil
+	case "text":
+		return valueCodec{
+			encode: func(b []byte) (string, error) {
+				if !utf8.Valid(b) {
+					return "", errors.New("value is not valid UTF-8: use -encoding base64 or hex")
+				}
+				return string(b), nil
+			},
+			decode: func(s string) ([]byte, error) { return []byte(s), nil },
+		}, nil
+	}
+	return valueCodec{}, fmt.Errorf("unknown encoding %q: use base64, hex or text", name)
+}
+
+// record is a key-value pair in a dump
+type record struct {
+	key   string
+	value []byte
+}
+
+type recordWriter interface {
+	write(record) error
+	flush() error
+}
+
+type recordReader interface {
+	// read returns the next record, or io.EOF after the last one
+	read() (record, error)
+}
+
+// jsonRecord is a line of a JSON Lines dump
+type jsonRecord struct {
+	Key   *string `json:"key"`
+	Value *string `json:"value"`
+}
+
+type jsonlWriter struct {
+	enc   *json.Encoder
+	codec valueCodec
+}
+
+func (w *jsonlWriter) write(r record) error {
+	value, err := w.codec.encode(r.value)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", r.key, err)
+	}
+	return w.enc.Encode(jsonRecord{Key: &r.key, Value: &value})
+}
+
+func (w *jsonlWriter) flush() error { return nil }
+
+type jsonlReader struct {
+	dec   *json.Decoder
+	codec valueCodec
+	line  int
+}
+
+func (r *jsonlReader) read() (record, error) {
+	var rec jsonRecord
+	if err := r.dec.Decode(&rec); err != nil {
+		if err == io.EOF {
+			return record{}, err
+		}
+		return record{}, fmt.Errorf("record %d: %w", r.line+1, err)
+	}
+	r.line++
+	if rec.Key == nil || rec.Value == nil {
+		return record{}, fmt.Errorf("record %d: key and value are required", r.line)
+	}
+	value, err := r.codec.decode(*rec.Value)
+	if err != nil {
+		return record{}, fmt.Errorf("record %d: invalid value: %w", r.line, err)
+	}
+	return record{key: *rec.Key, value: value}, nil
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	codec  valueCodec
+	header bool
+}
+
+func (w *csvWriter) write(r record) error {
+	if !w.header {
+		if err := w.w.Write([]string{"key", "value"}); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	value, err := w.codec.encode(r.value)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", r.key, err)
+	}
+	return w.w.Write([]string{r.key, value})
+}
+
+func (w *csvWriter) flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type csvReader struct {
+	r     *csv.Reader
+	codec valueCodec
+	row   int
+}
+
+func (r *csvReader) read() (record, error) {
+	for {
+		row, err := r.r.Read()
+		if err != nil {
+			return record{}, err
+		}
+		r.row++
+		if r.row == 1 && row[0] == "key" && row[1] == "value" {
+			continue
+		}
+		value, err := r.codec.decode(row[1])
+		if err != nil {
+			return record{}, fmt.Errorf("row %d: invalid value: %w", r.row, err)
+		}
+		return record{key: row[0], value: value}, nil
+	}
+}
+
+// binaryWriter writes records as a uvarint key length, the key, a uvarint
+// value length and the value, after binaryMagic
+type binaryWriter struct {
+	w     io.Writer
+	magic bool
+	buf   []byte
+}
+
+func (w *binaryWriter) write(r record) error {
+	w.buf = w.buf[:0]
+	if !w.magic {
+		w.buf = append(w.buf, binaryMagic...)
+		w.magic = true
+	}
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(r.key)))
+	w.buf = append(w.buf, r.key...)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(r.value)))
+	w.buf = append(w.buf, r.value...)
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+func (w *binaryWriter) flush() error { return nil }
+
+type binaryReader struct {
+	r     *bufio.Reader
+	magic bool
+}
+
+func (r *binaryReader) read() (record, error) {
+	if !r.magic {
+		magic := make([]byte, len(binaryMagic))
+		if _, err := io.ReadFull(r.r, magic); err != nil {
+			if err == io.EOF {
+				return record{}, err
+			}
+			return record{}, fmt.Errorf("not a binary dump: %w", err)
+		}
+		if string(magic) != binaryMagic {
+			return record{}, errors.New("not a binary dump")
+		}
+		r.magic = true
+	}
+
+	key, err := r.field()
+	if err != nil {
+		return record{}, err
+	}
+	value, err := r.field()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return record{}, fmt.Errorf("key %q: %w", key, err)
+	}
+	return record{key: string(key), value: value}, nil
+}
+
+// field reads a length-prefixed field, returning io.EOF only if the input
+// ends before it
+func (r *binaryReader) field() ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxBinaryField {
+		return nil, fmt.Errorf("field of %d bytes exceeds the limit", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func newRecordWriter(format string, w io.Writer, codec valueCodec) recordWriter {
+	switch format {
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w), codec: codec}
+	case "binary":
+		return &binaryWriter{w: w}
+	}
+	return &jsonlWriter{enc: json.NewEncoder(w), codec: codec}
+}
+
+func newRecordReader(format string, r io.Reader, codec valueCodec) recordReader {
+	switch format {
+	case "csv":
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = 2
+		cr.ReuseRecord = true
+		return &csvReader{r: cr, codec: codec}
+	case "binary":
+		return &binaryReader{r: bufio.NewReader(r)}
+	}
+	return &jsonlReader{dec: json.NewDecoder(r), codec: codec}
+}
+
+// createDump opens the dump file for writing. The returned function
+// flushes and closes it.
+func createDump(path string, compress bool) (io.Writer, func() error, error) {
+	f := os.Stdout
+	if path != "-" {
+		var err error
+		if f, err = os.Create(path); err != nil {
+			return nil, nil, fmt.Errorf("failed to create dump: %w", err)
+		}
+	}
+	bw := bufio.NewWriter(f)
+	var gz *gzip.Writer
+	var w io.Writer = bw
+	if compress {
+		gz = gzip.NewWriter(bw)
+		w = gz
+	}
+
+	closeDump := func() error {
+		var errs []error
+		if gz != nil {
+			errs = append(errs, gz.Close())
+		}
+		errs = append(errs, bw.Flush())
+		if f != os.Stdout {
+			errs = append(errs, f.Close())
+		}
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("failed to write dump: %w", err)
+		}
+		return nil
+	}
+	return w, closeDump, nil
+}
+
+// openDump opens the dump file for reading. The returned function closes
+// it.
+func openDump(path string, compressed bool) (io.Reader, func() error, error) {
+	f := os.Stdin
+	if path != "-" {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, nil, fmt.Errorf("failed to open dump: %w", err)
+		}
+	}
+	closeDump := func() error {
+		if f != os.Stdin {
+			return f.Close()
+		}
+		return nil
+	}
+
+	var r io.Reader = bufio.NewReader(f)
+	if compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			closeDump()
+			return nil, nil, fmt.Errorf("failed to open dump: %w", err)
+		}
+		r = gz
+	}
+	return r, closeDump, nil
+}
+
+// progress reports the number of keys and bytes processed to stderr, at
+// most once a second
+type progress struct {
+	verb   string
+	start  time.Time
+	last   time.Time
+	keys   uint64
+	bytes  uint64
+	output io.Writer
+}
+
+func newProgress(verb string) *progress {
+	now := time.Now()
+	return &progress{verb: verb, start: now, last: now, output: os.Stderr}
+}
+
+func (p *progress) add(r record) {
+	p.keys++
+	p.bytes += uint64(len(r.key) + len(r.value))
+	if now := time.Now(); now.Sub(p.last) >= time.Second {
+		p.last = now
+		p.report("")
+	}
+}
+
+func (p *progress) report(suffix string) {
+	elapsed := time.Since(p.start)
+	fmt.Fprintf(p.output, "%s %d keys (%.1f MB, %.0f keys/s)%s\n",
+		p.verb, p.keys, float64(p.bytes)/1e6, float64(p.keys)/elapsed.Seconds(), suffix)
+}
+
+func (p *progress) done() {
+	p.report(fmt.Sprintf(" in %s", time.Since(p.start).Round(time.Millisecond)))
+}
+
+// runExport writes the selected keys of database to a dump
+func runExport(ctx context.Context, client pb.RocksDBServiceClient, database string, opts dumpOptions) error {
+	if err := opts.resolve(); err != nil {
+		return err
+	}
+	codec, err := newValueCodec(opts.encoding)
+	if err != nil {
+		return err
+	}
+
+	stream, err := client.Scan(ctx, &pb.ScanRequest{
+		DatabaseName: database,
+		Prefix:       opts.prefix,
+		StartKey:     opts.start,
+		EndKey:       opts.end,
+	})
+	if err != nil {
+		return err
+	}
+
+	out, closeDump, err := createDump(opts.file, opts.gzip)
+	if err != nil {
+		return err
+	}
+	defer closeDump()
+	w := newRecordWriter(opts.format, out, codec)
+
+	p := newProgress("Exported")
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("failed to read key %q: %s", resp.Key, resp.Error)
+		}
+		rec := record{key: resp.Key, value: resp.Value}
+		if err := w.write(rec); err != nil {
+			return err
+		}
+		p.add(rec)
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := closeDump(); err != nil {
+		return err
+	}
+	p.done()
+	return nil
+}
+
+// runImport writes the selected records of a dump to database, in
+// StreamWrite batches
+func runImport(ctx context.Context, client pb.RocksDBServiceClient, database string, opts dumpOptions) error {
+	if err := opts.resolve(); err != nil {
+		return err
+	}
+	codec, err := newValueCodec(opts.encoding)
+	if err != nil {
+		return err
+	}
+
+	in, closeDump, err := openDump(opts.file, opts.gzip)
+	if err != nil {
+		return err
+	}
+	defer closeDump()
+	r := newRecordReader(opts.format, in, codec)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.StreamWrite(ctx)
+	if err != nil {
+		return err
+	}
+
+	var committed atomic.Uint64
+	acks := make(chan error, 1)
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				acks <- nil
+				return
+			}
+			if err != nil {
+				acks <- err
+				return
+			}
+			if resp.Error != "" {
+				acks <- fmt.Errorf("write failed after %d keys: %s", resp.CommittedSequence, resp.Error)
+				return
+			}
+			committed.Store(resp.CommittedSequence)
+		}
+	}()
+
+	p := newProgress("Imported")
+	var seq uint64
+	for {
+		rec, err := r.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read dump: %w", err)
+		}
+		if !opts.inRange(rec.key) {
+			continue
+		}
+
+		seq++
+		req := &pb.StreamWriteRequest{Sequence: seq, Key: rec.key, Value: rec.value}
+		if seq == 1 {
+			req.DatabaseName = database
+			req.BatchSize = uint32(opts.batchSize)
+		}
+		if err := stream.Send(req); err != nil {
+			if err == io.EOF {
+				// The server ended the stream; its error is returned by Recv
+				if ackErr := <-acks; ackErr != nil {
+					return ackErr
+				}
+			}
+			return err
+		}
+		p.add(rec)
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	if err := <-acks; err != nil {
+		return err
+	}
+	if c := committed.Load(); c != seq {
+		return fmt.Errorf("server committed %d of %d keys", c, seq)
+	}
+	p.done()
+	return nil
+}
@@ -4,25 +4,56 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"os"
+	"slices"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/tlsutil"
 )
 
 func main() {
 	var (
 		serverAddr = flag.String("server", "localhost:50051", "The server address in the format of host:port")
 		dbName     = flag.String("db", "default", "Database name to use")
-		operation  = flag.String("op", "", "Operation to perform: put, get, delete, or prefix")
+		operation  = flag.String("op", "", "Operation to perform: put, get, delete, incr, counter, prefix, swap-alias, usage, rotate-key, jobs, stats, shell, export, or import")
 		key        = flag.String("key", "", "Key to operate on")
 		value      = flag.String("value", "", "Value to put (only used with put operation)")
-		prefix     = flag.String("prefix", "", "Key prefix to search for (only used with prefix operation)")
+		prefix     = flag.String("prefix", "", "Key prefix to search for (used with prefix, export and import operations)")
+		ifVersion  = flag.Int64("if-version", -1, "Only put if the key's current version matches (only used with put operation)")
+		delta      = flag.Int64("delta", 1, "Amount added to the counter (only used with incr operation)")
+		minValue   = flag.Int64("min", 0, "Lowest value the counter may reach, if set (only used with incr operation)")
+		maxValue   = flag.Int64("max", 0, "Highest value the counter may reach, if set (only used with incr operation)")
+		alias      = flag.String("alias", "", "Alias to point at -db (only used with swap-alias operation)")
+		tlsCA      = flag.String("tls-ca", "", "CA bundle verifying the server certificate; enables TLS")
+		tlsCert    = flag.String("tls-cert", "", "Client certificate file for mutual TLS; enables TLS")
+		tlsKey     = flag.String("tls-key", "", "Client private key file for mutual TLS")
+		token      = flag.String("token", os.Getenv("ROCKSDB_TOKEN"), "Bearer token sent with each request (default $ROCKSDB_TOKEN)")
+		file       = flag.String("file", "-", "Dump file, - for stdout or stdin (used with export and import operations)")
+		format     = flag.String("format", "", "Dump format: jsonl, csv or binary (default: from the -file extension)")
+		encoding   = flag.String("encoding", "base64", "Encoding of values in jsonl and csv dumps: base64, hex or text")
+		compress   = flag.Bool("gzip", false, "Compress the dump with gzip (default: true for a .gz -file)")
+		startKey   = flag.String("start", "", "First key to export or import, inclusive")
+		endKey     = flag.String("end", "", "Last key to export or import, exclusive")
+		batchSize  = flag.Int("batch-size", 1000, "Number of keys written per batch on import")
+		output     = flag.String("output", "text", "Output format: text, json, jsonl, table, hex, base64 or raw")
+		protoSet   = flag.String("proto-descriptor-set", "", "FileDescriptorSet file describing -proto-type")
+		protoType  = flag.String("proto-type", "", "Fully-qualified protobuf message type of values, printed as JSON")
 	)
 	flag.Parse()
 
+	out, err := newPrinter(*output, os.Stdout, *protoSet, *protoType)
+	if err != nil {
+		log.Fatalf("Invalid output options: %v", err)
+	}
+
 	if *operation == "" {
 		log.Fatal("Operation is required")
 	}
@@ -35,34 +66,88 @@ func main() {
 		log.Fatal("Prefix is required for prefix operation")
 	}
 
+	if *operation == "swap-alias" && *alias == "" {
+		log.Fatal("Alias is required for swap-alias operation")
+	}
+
 	if *operation == "put" && *value == "" {
 		log.Fatal("Value is required for put operation")
 	}
 
-	conn, err := grpc.Dial(*serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	creds := insecure.NewCredentials()
+	if *tlsCA != "" || *tlsCert != "" || *tlsKey != "" {
+		tlsConfig, err := tlsutil.ClientConfig(*tlsCA, *tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.Dial(*serverAddr, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
 	defer conn.Close()
 
 	client := pb.NewRocksDBServiceClient(conn)
+	switch *operation {
+	case "shell":
+		if err := runShell(client, out, *dbName, *token); err != nil {
+			log.Fatalf("Shell failed: %v", err)
+		}
+		return
+
+	case "export", "import":
+		// Dumps can take arbitrarily long, so they run without a timeout
+		ctx := context.Background()
+		if *token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*token)
+		}
+		opts := dumpOptions{
+			file:      *file,
+			format:    *format,
+			encoding:  *encoding,
+			gzip:      *compress,
+			prefix:    *prefix,
+			start:     *startKey,
+			end:       *endKey,
+			batchSize: *batchSize,
+		}
+		if *operation == "export" {
+			if err := runExport(ctx, client, *dbName, opts); err != nil {
+				log.Fatalf("Export failed: %v", err)
+			}
+		} else if err := runImport(ctx, client, *dbName, opts); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
+	if *token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*token)
+	}
 
 	switch *operation {
 	case "put":
-		resp, err := client.Put(ctx, &pb.PutRequest{
+		req := &pb.PutRequest{
 			DatabaseName: *dbName,
 			Key:          *key,
 			Value:        []byte(*value),
-		})
+		}
+		if *ifVersion >= 0 {
+			v := uint64(*ifVersion)
+			req.IfVersion = &v
+		}
+		resp, err := client.Put(ctx, req)
 		if err != nil {
 			log.Fatalf("Put failed: %v", err)
 		}
 		if !resp.Success {
 			log.Fatalf("Put failed: %s", resp.Error)
 		}
-		fmt.Println("Put successful")
+		out.one([]string{"key", "version"}, []any{*key, resp.Version}, "Put successful")
 
 	case "get":
 		resp, err := client.Get(ctx, &pb.GetRequest{
@@ -73,10 +158,13 @@ func main() {
 			log.Fatalf("Get failed: %v", err)
 		}
 		if !resp.Found {
-			fmt.Println("Key not found")
+			if out.structured() {
+				out.one([]string{"key", "found"}, []any{*key, false}, "")
+			}
+			out.say("Key not found")
 			return
 		}
-		fmt.Printf("Value: %s\n", string(resp.Value))
+		out.one([]string{"value", "version"}, []any{blob(resp.Value), resp.Version}, "")
 
 	case "delete":
 		resp, err := client.Delete(ctx, &pb.DeleteRequest{
@@ -89,7 +177,43 @@ func main() {
 		if !resp.Success {
 			log.Fatalf("Delete failed: %s", resp.Error)
 		}
-		fmt.Println("Delete successful")
+		out.one([]string{"key", "deleted"}, []any{*key, true}, "Delete successful")
+
+	case "incr":
+		req := &pb.IncrementRequest{
+			DatabaseName: *dbName,
+			Key:          *key,
+			Delta:        *delta,
+		}
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "min":
+				req.Min = minValue
+			case "max":
+				req.Max = maxValue
+			}
+		})
+		resp, err := client.Increment(ctx, req)
+		if err != nil {
+			log.Fatalf("Increment failed: %v", err)
+		}
+		if !resp.Success {
+			log.Fatalf("Increment failed: %s", resp.Error)
+		}
+		out.one([]string{"key", "value", "version"}, []any{*key, resp.Value, resp.Version}, "")
+
+	case "counter":
+		resp, err := client.GetCounter(ctx, &pb.GetCounterRequest{
+			DatabaseName: *dbName,
+			Key:          *key,
+		})
+		if err != nil {
+			log.Fatalf("GetCounter failed: %v", err)
+		}
+		if resp.Error != "" {
+			log.Fatalf("GetCounter failed: %s", resp.Error)
+		}
+		out.one([]string{"key", "value", "version"}, []any{*key, resp.Value, resp.Version}, "")
 
 	case "prefix":
 		stream, err := client.StreamGet(ctx, &pb.StreamGetRequest{
@@ -103,21 +227,104 @@ func main() {
 		}
 
 		count := 0
-		fmt.Println("Keys with prefix:", *prefix)
+		out.say("Keys with prefix: " + *prefix)
+		out.begin("key", "value", "version")
 		for {
 			resp, err := stream.Recv()
-			if err != nil {
-				// End of stream
+			if err == io.EOF {
 				break
 			}
+			if err != nil {
+				out.end("")
+				log.Fatalf("StreamGet failed: %v", err)
+			}
 			if resp.Error != "" {
-				fmt.Printf("Error for key %s: %s\n", resp.Key, resp.Error)
+				fmt.Fprintf(os.Stderr, "Error for key %s: %s\n", resp.Key, resp.Error)
 				continue
 			}
-			fmt.Printf("Key: %s, Value: %s\n", resp.Key, string(resp.Value))
+			out.row(resp.Key, blob(resp.Value), resp.Version)
 			count++
 		}
-		fmt.Printf("Found %d key-value pairs with prefix: %s\n", count, *prefix)
+		out.end(fmt.Sprintf("Found %d key-value pairs with prefix: %s", count, *prefix))
+
+	case "swap-alias":
+		resp, err := client.SwapAlias(ctx, &pb.SwapAliasRequest{
+			Alias:        *alias,
+			DatabaseName: *dbName,
+		})
+		if err != nil {
+			log.Fatalf("SwapAlias failed: %v", err)
+		}
+		if !resp.Success {
+			log.Fatalf("SwapAlias failed: %s", resp.Error)
+		}
+		out.one([]string{"alias", "database", "previous_database"},
+			[]any{*alias, *dbName, resp.PreviousDatabaseName}, "Alias swapped")
+
+	case "usage":
+		// -db defaults to "default", so only filter by database when set
+		req := &pb.GetUsageRequest{}
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "db" {
+				req.DatabaseName = *dbName
+			}
+		})
+		resp, err := client.GetUsage(ctx, req)
+		if err != nil {
+			log.Fatalf("GetUsage failed: %v", err)
+		}
+		out.begin("kind", "name", "requests", "bytes_read", "bytes_written", "active_streams", "storage_bytes")
+		for _, u := range resp.Principals {
+			out.row("principal", u.Name, u.Requests, u.BytesRead, u.BytesWritten, u.ActiveStreams, nil)
+		}
+		for _, u := range resp.Databases {
+			out.row("database", u.Name, u.Requests, u.BytesRead, u.BytesWritten, u.ActiveStreams, u.StorageBytes)
+		}
+		out.end("")
+
+	case "rotate-key":
+		resp, err := client.RotateKey(ctx, &pb.RotateKeyRequest{DatabaseName: *dbName})
+		if err != nil {
+			log.Fatalf("RotateKey failed: %v", err)
+		}
+		if !resp.Success {
+			log.Fatalf("RotateKey failed: %s", resp.Error)
+		}
+		out.one([]string{"database", "job_id"}, []any{*dbName, resp.JobId}, "Key rotation started")
+
+	case "jobs":
+		// -db defaults to "default", so only filter by database when set
+		req := &pb.ListJobsRequest{}
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "db" {
+				req.DatabaseName = *dbName
+			}
+		})
+		resp, err := client.ListJobs(ctx, req)
+		if err != nil {
+			log.Fatalf("ListJobs failed: %v", err)
+		}
+		out.begin("id", "kind", "database", "state", "processed", "started", "finished", "error")
+		for _, j := range resp.Jobs {
+			var finished any
+			if j.Finished != nil {
+				finished = j.Finished.AsTime()
+			}
+			out.row(j.Id, j.Kind, j.DatabaseName, j.State, j.Processed, j.Started.AsTime(), finished, j.Error)
+		}
+		out.end("")
+
+	case "stats":
+		resp, err := client.GetStats(ctx, &pb.GetStatsRequest{DatabaseName: *dbName})
+		if err != nil {
+			log.Fatalf("GetStats failed: %v", err)
+		}
+		names := slices.Sorted(maps.Keys(resp.Properties))
+		out.begin("property", "value")
+		for _, name := range names {
+			out.row(name, resp.Properties[name])
+		}
+		out.end("")
 
 	default:
 		log.Fatalf("Unknown operation: %s", *operation)
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// outputModes lists the values of -output
+var outputModes = []string{"text", "json", "jsonl", "table", "hex", "base64", "raw"}
+
+// blob is a stored value. Unlike other fields, blobs may be binary and are
+// rendered according to the output mode.
+type blob []byte
+
+// printer writes command results in one of the output modes:
+//   - text: one "Field: value" line per field, or a line per record in lists
+//   - json: an indented object, or an array of objects for lists
+//   - jsonl: one compact object per record
+//   - table: aligned columns with a header
+//   - hex, base64, raw: only the stored values, encoded or as is; results
+//     without values are printed as text
+//
+// Text and table output print values as is if they are printable, and
+// quoted otherwise. Values holding a JSON object or array, or a protobuf
+// message of the configured type, are pretty-printed in every mode but
+// hex, base64 and raw.
+type printer struct {
+	mode    string
+	out     io.Writer
+	message protoreflect.MessageDescriptor
+
+	// State of the list being printed
+	columns []string
+	rows    int
+	table   *tabwriter.Writer
+}
+
+// newPrinter returns a printer for mode. If typeName is set, values are
+// decoded as that protobuf message type, found in the FileDescriptorSet at
+// descriptorSet.
+func newPrinter(mode string, out io.Writer, descriptorSet, typeName string) (*printer, error) {
+	if !slices.Contains(outputModes, mode) {
+		return nil, fmt.Errorf("unknown output mode %q: use %s", mode, strings.Join(outputModes, ", "))
+	}
+	p := &printer{mode: mode, out: out}
+	if typeName == "" {
+		return p, nil
+	}
+	if descriptorSet == "" {
+		return nil, fmt.Errorf("a protobuf type requires a descriptor set")
+	}
+
+	data, err := os.ReadFile(descriptorSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(typeName))
+	if err != nil {
+		return nil, fmt.Errorf("protobuf type %s: %w", typeName, err)
+	}
+	message, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message type", typeName)
+	}
+	p.message = message
+	return p, nil
+}
+
+// structured reports whether the mode prints machine-readable records, in
+// which case messages are omitted
+func (p *printer) structured() bool {
+	return p.mode == "json" || p.mode == "jsonl"
+}
+
+// valuesOnly reports whether only the stored values of a result with the
+// given fields are printed
+func (p *printer) valuesOnly(values []any) bool {
+	if p.mode != "hex" && p.mode != "base64" && p.mode != "raw" {
+		return false
+	}
+	for _, v := range values {
+		if _, ok := v.(blob); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// one prints a single record followed by message
+func (p *printer) one(columns []string, values []any, message string) {
+	switch {
+	case p.valuesOnly(values):
+		for _, v := range values {
+			if v, ok := v.(blob); ok {
+				p.writeValue(v, "")
+			}
+		}
+	case p.mode == "json" || p.mode == "jsonl":
+		p.writeJSON(columns, values, p.mode == "json", "", "\n")
+	case p.mode == "table":
+		p.begin(columns...)
+		p.row(values...)
+		p.end(message)
+	default:
+		for i, column := range columns {
+			fmt.Fprintf(p.out, "%s: %s\n", title(column), p.text(values[i], true))
+		}
+		p.say(message)
+	}
+}
+
+// begin starts a list of records with the given fields
+func (p *printer) begin(columns ...string) {
+	p.columns, p.rows = columns, 0
+	switch p.mode {
+	case "json":
+		fmt.Fprint(p.out, "[")
+	case "table":
+		p.table = tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+		titles := make([]string, len(columns))
+		for i, column := range columns {
+			titles[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(p.table, strings.Join(titles, "\t"))
+	}
+}
+
+// row prints a record of the current list
+func (p *printer) row(values ...any) {
+	p.rows++
+	switch {
+	case p.valuesOnly(values):
+		for _, v := range values {
+			if v, ok := v.(blob); ok {
+				p.writeValue(v, "\n")
+			}
+		}
+	case p.mode == "json":
+		if p.rows > 1 {
+			fmt.Fprint(p.out, ",")
+		}
+		fmt.Fprint(p.out, "\n")
+		p.writeJSON(p.columns, values, true, "  ", "")
+	case p.mode == "jsonl":
+		p.writeJSON(p.columns, values, false, "", "\n")
+	case p.mode == "table":
+		cells := make([]string, len(values))
+		for i, v := range values {
+			cells[i] = p.text(v, false)
+		}
+		fmt.Fprintln(p.table, strings.Join(cells, "\t"))
+	default:
+		fields := make([]string, len(values))
+		for i, v := range values {
+			fields[i] = title(p.columns[i]) + ": " + p.text(v, false)
+		}
+		fmt.Fprintln(p.out, strings.Join(fields, ", "))
+	}
+}
+
+// end finishes the current list, followed by message
+func (p *printer) end(message string) {
+	switch p.mode {
+	case "json":
+		if p.rows > 0 {
+			fmt.Fprint(p.out, "\n")
+		}
+		fmt.Fprintln(p.out, "]")
+	case "table":
+		p.table.Flush()
+		p.table = nil
+	}
+	p.say(message)
+}
+
+// say prints a message in text and table modes
+func (p *printer) say(message string) {
+	if message == "" || p.structured() || p.mode == "hex" || p.mode == "base64" || p.mode == "raw" {
+		return
+	}
+	fmt.Fprintln(p.out, message)
+}
+
+func (p *printer) writeValue(v blob, sep string) {
+	switch p.mode {
+	case "hex":
+		fmt.Fprintln(p.out, hex.EncodeToString(v))
+	case "base64":
+		fmt.Fprintln(p.out, base64.StdEncoding.EncodeToString(v))
+	default:
+		p.out.Write(v)
+		fmt.Fprint(p.out, sep)
+	}
+}
+
+// writeJSON prints a record as a JSON object with fields in column order.
+// Values that are not text are base64-encoded and flagged by a
+// "<field>_encoding" field. Indented objects are written with prefix before
+// each line.
+func (p *printer) writeJSON(columns []string, values []any, indent bool, prefix, suffix string) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	field := func(name string, raw []byte) {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(raw)
+	}
+	for i, column := range columns {
+		switch v := values[i].(type) {
+		case blob:
+			if decoded, ok := p.decode(v); ok {
+				field(column, decoded)
+			} else if utf8.Valid(v) {
+				s, _ := json.Marshal(string(v))
+				field(column, s)
+			} else {
+				s, _ := json.Marshal(base64.StdEncoding.EncodeToString(v))
+				field(column, s)
+				field(column+"_encoding", []byte(`"base64"`))
+			}
+		default:
+			raw, _ := json.Marshal(v)
+			field(column, raw)
+		}
+	}
+	buf.WriteByte('}')
+
+	out := buf.Bytes()
+	if indent {
+		var indented bytes.Buffer
+		json.Indent(&indented, out, prefix, "  ")
+		out = indented.Bytes()
+	}
+	fmt.Fprint(p.out, prefix)
+	p.out.Write(out)
+	fmt.Fprint(p.out, suffix)
+}
+
+// decode returns a value holding a protobuf message of the configured type
+// or a JSON object or array as compact JSON
+func (p *printer) decode(v blob) ([]byte, bool) {
+	if p.message != nil {
+		m := dynamicpb.NewMessage(p.message)
+		if err := proto.Unmarshal(v, m); err == nil {
+			if out, err := protojson.Marshal(m); err == nil {
+				var compact bytes.Buffer
+				if json.Compact(&compact, out) == nil {
+					return compact.Bytes(), true
+				}
+			}
+		}
+	}
+	trimmed := bytes.TrimSpace(v)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		var compact bytes.Buffer
+		json.Compact(&compact, trimmed)
+		return compact.Bytes(), true
+	}
+	return nil, false
+}
+
+// text renders a field for text and table output. Multiline output is
+// only used for single records.
+func (p *printer) text(v any, multiline bool) string {
+	switch v := v.(type) {
+	case blob:
+		if decoded, ok := p.decode(v); ok {
+			if !multiline {
+				return string(decoded)
+			}
+			var indented bytes.Buffer
+			json.Indent(&indented, decoded, "", "  ")
+			return indented.String()
+		}
+		if printable(v, multiline) {
+			return string(v)
+		}
+		return strconv.Quote(string(v))
+	case time.Time:
+		if v.IsZero() {
+			return "-"
+		}
+		return v.Local().Format(time.RFC3339)
+	case nil:
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
+// printable reports whether b is UTF-8 text without control characters,
+// other than tabs and newlines if multiline
+func printable(b []byte, multiline bool) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if (r == '\n' || r == '\t') && multiline {
+			continue
+		}
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// title capitalizes a field name for text output, e.g. "bytes_read" becomes
+// "Bytes read"
+func title(column string) string {
+	s := strings.ReplaceAll(column, "_", " ")
+	return strings.ToUpper(s[:1]) + s[1:]
+}
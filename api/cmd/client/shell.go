@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"google.golang.org/grpc/metadata"
+	pb "rocksdb-service/api/proto"
+)
+
+const (
+	// commandTimeout bounds each shell command
+	commandTimeout = 10 * time.Second
+	// completionTimeout bounds the lookups behind tab completion, so a slow
+	// server does not freeze the prompt
+	completionTimeout = 2 * time.Second
+	// completionLimit is the number of keys fetched to complete a key
+	completionLimit = 100
+	// defaultScanLimit is the number of pairs printed by scan without a limit
+	defaultScanLimit = 100
+	// historySize is the number of lines kept in the history file
+	historySize = 1000
+)
+
+var shellCommands = []string{"use", "dbs", "get", "put", "del", "scan", "count", "help", "exit"}
+
+const shellHelp = `Commands:
+  use <db>                 Switch to a database
+  dbs                      List databases and aliases
+  get <key>                Print the value and version of a key
+  put <key> <value>        Store a value
+  del <key>                Delete a key
+  scan [prefix] [limit]    Print keys and values in order (default limit 100, 0 for all)
+  count [prefix]           Count keys
+  help                     Show this help
+  exit                     Leave the shell
+Arguments containing spaces or quotes can be given as Go-style quoted strings.
+Tab completes commands, database names and keys.`
+
+// shell is an interactive session on a single connection
+type shell struct {
+	client pb.RocksDBServiceClient
+	token  string
+	db     string
+	out    io.Writer
+	// p prints results to out
+	p *printer
+}
+
+// runShell reads commands from the terminal, with line editing, history and
+// completion, or line by line from a non-interactive stdin
+func runShell(client pb.RocksDBServiceClient, p *printer, db, token string) error {
+	s := &shell{client: client, token: token, db: db, out: p.out, p: p}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if !s.run(scanner.Text()) {
+				break
+			}
+		}
+		return scanner.Err()
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set terminal mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, s.prompt())
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		t.SetSize(width, height)
+	}
+	t.History = loadHistory()
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return s.complete(t, line, pos)
+	}
+	s.out = t
+	tp := *p
+	tp.out = t
+	s.p = &tp
+
+	fmt.Fprintln(t, `Type "help" for commands.`)
+	for {
+		line, err := t.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !s.run(line) {
+			return nil
+		}
+		t.SetPrompt(s.prompt())
+	}
+}
+
+func (s *shell) prompt() string {
+	return s.db + "> "
+}
+
+// context returns a context for one request, carrying the bearer token
+func (s *shell) context(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if s.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+s.token)
+	}
+	return ctx, cancel
+}
+
+// run executes one command line, reporting whether the shell should go on
+func (s *shell) run(line string) bool {
+	args, err := splitArgs(line)
+	if err != nil {
+		fmt.Fprintf(s.out, "Error: %v\n", err)
+		return true
+	}
+	if len(args) == 0 {
+		return true
+	}
+
+	switch args[0] {
+	case "exit", "quit":
+		return false
+	case "help":
+		fmt.Fprintln(s.out, shellHelp)
+		return true
+	case "use":
+		if len(args) != 2 {
+			fmt.Fprintln(s.out, "Usage: use <db>")
+			return true
+		}
+		s.db = args[1]
+		return true
+	}
+
+	start := time.Now()
+	if err := s.execute(args); err != nil {
+		fmt.Fprintf(s.out, "Error: %v\n", err)
+	}
+	s.p.say(fmt.Sprintf("(%s)", time.Since(start).Round(time.Microsecond)))
+	return true
+}
+
+// execute runs a command against the server
+func (s *shell) execute(args []string) error {
+	ctx, cancel := s.context(commandTimeout)
+	defer cancel()
+
+	usage := func(format string) error {
+		return fmt.Errorf("usage: %s", format)
+	}
+
+	switch cmd := args[0]; cmd {
+	case "dbs":
+		resp, err := s.client.ListDatabases(ctx, &pb.ListDatabasesRequest{})
+		if err != nil {
+			return err
+		}
+		s.p.begin("name", "alias_target", "open")
+		for _, d := range resp.Databases {
+			s.p.row(d.Name, d.AliasTarget, d.Open)
+		}
+		s.p.end("")
+
+	case "get":
+		if len(args) != 2 {
+			return usage("get <key>")
+		}
+		resp, err := s.client.Get(ctx, &pb.GetRequest{DatabaseName: s.db, Key: args[1]})
+		if err != nil {
+			return err
+		}
+		if !resp.Found {
+			if s.p.structured() {
+				s.p.one([]string{"key", "found"}, []any{args[1], false}, "")
+			}
+			s.p.say("Key not found")
+			return nil
+		}
+		s.p.one([]string{"value", "version"}, []any{blob(resp.Value), resp.Version}, "")
+
+	case "put":
+		if len(args) != 3 {
+			return usage("put <key> <value>")
+		}
+		resp, err := s.client.Put(ctx, &pb.PutRequest{DatabaseName: s.db, Key: args[1], Value: []byte(args[2])})
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return errors.New(resp.Error)
+		}
+		s.p.one([]string{"key", "version"}, []any{args[1], resp.Version}, "OK")
+
+	case "del":
+		if len(args) != 2 {
+			return usage("del <key>")
+		}
+		resp, err := s.client.Delete(ctx, &pb.DeleteRequest{DatabaseName: s.db, Key: args[1]})
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return errors.New(resp.Error)
+		}
+		s.p.one([]string{"key", "deleted"}, []any{args[1], true}, "OK")
+
+	case "scan", "count":
+		req := &pb.ScanRequest{DatabaseName: s.db, KeysOnly: cmd == "count"}
+		if cmd == "scan" {
+			req.Limit = defaultScanLimit
+		}
+		switch {
+		case cmd == "scan" && len(args) <= 3:
+			if len(args) == 3 {
+				limit, err := strconv.ParseUint(args[2], 10, 64)
+				if err != nil {
+					return usage("scan [prefix] [limit]")
+				}
+				req.Limit = limit
+			}
+		case cmd == "count" && len(args) <= 2:
+		default:
+			return usage(cmd + " [prefix]")
+		}
+		if len(args) > 1 {
+			req.Prefix = args[1]
+		}
+		if cmd == "count" {
+			// Counting may take a while on large databases
+			cancel()
+			ctx, cancel = s.context(time.Hour)
+			defer cancel()
+		}
+
+		stream, err := s.client.Scan(ctx, req)
+		if err != nil {
+			return err
+		}
+		if cmd == "count" {
+			var n uint64
+			for {
+				_, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				n++
+			}
+			s.p.one([]string{"count"}, []any{n}, "")
+			return nil
+		}
+
+		var n uint64
+		s.p.begin("key", "value", "version")
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				s.p.end("")
+				return err
+			}
+			n++
+			if resp.Error != "" {
+				fmt.Fprintf(s.out, "Error for key %s: %s\n", resp.Key, resp.Error)
+				continue
+			}
+			s.p.row(resp.Key, blob(resp.Value), resp.Version)
+		}
+		if req.Limit > 0 && n == req.Limit {
+			s.p.end(fmt.Sprintf("%d keys (limit reached)", n))
+		} else {
+			s.p.end(fmt.Sprintf("%d keys", n))
+		}
+
+	default:
+		return fmt.Errorf("unknown command %q, type \"help\" for commands", cmd)
+	}
+	return nil
+}
+
+// complete completes the word before the cursor: a command name, a database
+// name after "use", or a key after commands taking one
+func (s *shell) complete(t *term.Terminal, line string, pos int) (string, int, bool) {
+	before := line[:pos]
+	fields := strings.Fields(before)
+	partial := ""
+	if len(fields) > 0 && !strings.HasSuffix(before, " ") {
+		partial = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+	if strings.HasPrefix(partial, `"`) {
+		// Quoted arguments are not completed
+		return "", 0, false
+	}
+
+	var candidates []string
+	switch {
+	case len(fields) == 0:
+		candidates = matching(shellCommands, partial)
+	case len(fields) == 1 && fields[0] == "use":
+		candidates = s.databaseNames(partial)
+	case len(fields) == 1 && slices.Contains([]string{"get", "put", "del", "scan", "count"}, fields[0]):
+		candidates = s.keys(partial)
+	}
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	completion := candidates[0]
+	if len(candidates) > 1 {
+		completion = commonPrefix(candidates)
+		if completion == partial || strings.ContainsAny(completion, " \t") {
+			fmt.Fprintln(t, strings.Join(candidates, "  "))
+			return "", 0, false
+		}
+	} else {
+		completion = quoteArg(completion) + " "
+	}
+
+	start := pos - len(partial)
+	newLine := line[:start] + completion + line[pos:]
+	return newLine, start + len(completion), true
+}
+
+// databaseNames returns the databases and aliases starting with prefix
+func (s *shell) databaseNames(prefix string) []string {
+	ctx, cancel := s.context(completionTimeout)
+	defer cancel()
+	resp, err := s.client.ListDatabases(ctx, &pb.ListDatabasesRequest{})
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, d := range resp.Databases {
+		names = append(names, d.Name)
+	}
+	return matching(names, prefix)
+}
+
+// keys returns the first keys starting with prefix in the current database
+func (s *shell) keys(prefix string) []string {
+	ctx, cancel := s.context(completionTimeout)
+	defer cancel()
+	stream, err := s.client.Scan(ctx, &pb.ScanRequest{
+		DatabaseName: s.db,
+		Prefix:       prefix,
+		Limit:        completionLimit,
+		KeysOnly:     true,
+	})
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		keys = append(keys, resp.Key)
+	}
+	return keys
+}
+
+func matching(words []string, prefix string) []string {
+	var matches []string
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			matches = append(matches, w)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
+
+// quoteArg quotes an argument if splitArgs would not read it back as is
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t") || arg[0] == '"' {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
+
+// splitArgs splits a command line into whitespace-separated arguments.
+// Arguments starting with a double quote are Go-style quoted strings.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return args, nil
+		}
+		if line[0] != '"' {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			args = append(args, line[:end])
+			line = line[end:]
+			continue
+		}
+
+		end := 1
+		for end < len(line) && line[end] != '"' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(line) {
+			return nil, fmt.Errorf("unterminated quoted string")
+		}
+		arg, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", line[:end+1])
+		}
+		args = append(args, arg)
+		line = line[end+1:]
+	}
+}
+
+// history is the shell history, kept in memory and appended to a file in
+// the home directory so it survives sessions
+type history struct {
+	entries []string
+	file    *os.File
+}
+
+// loadHistory reads the history file. Without a usable file, history is
+// only kept for the session.
+func loadHistory() *history {
+	h := &history{}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return h
+	}
+	path := filepath.Join(home, ".rocksdb_client_history")
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+		h.entries = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		if len(h.entries) > historySize {
+			h.entries = h.entries[len(h.entries)-historySize:]
+			// Rewrite the file so it does not grow forever
+			os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600)
+		}
+	}
+	h.file, _ = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	return h
+}
+
+func (h *history) Add(entry string) {
+	if entry == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if h.file != nil {
+		fmt.Fprintln(h.file, entry)
+	}
+}
+
+func (h *history) Len() int {
+	return len(h.entries)
+}
+
+func (h *history) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
@@ -0,0 +1,65 @@
+package embedded_test
+
+import (
+	"context"
+	"testing"
+
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+)
+
+func TestSwapAlias(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t, embedded.Options{AliasRetention: 2}), client.Options{})
+	if _, err := c.Put(ctx, "catalog-v1", "k", []byte("v1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := c.Put(ctx, "catalog-v2", "k", []byte("v2")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	swap := func(target string) string {
+		t.Helper()
+		resp, err := c.Stub().SwapAlias(ctx, &pb.SwapAliasRequest{Alias: "catalog", DatabaseName: target})
+		if err != nil || !resp.Success {
+			t.Fatalf("SwapAlias(%s) = %v, %v", target, resp, err)
+		}
+		return resp.PreviousDatabaseName
+	}
+	read := func() string {
+		t.Helper()
+		item, err := c.Get(ctx, "catalog", "k")
+		if err != nil {
+			t.Fatalf("Get through the alias: %v", err)
+		}
+		return string(item.Value)
+	}
+
+	if prev := swap("catalog-v1"); prev != "" {
+		t.Errorf("previous target of a new alias = %q", prev)
+	}
+	if got := read(); got != "v1" {
+		t.Errorf("read through the alias = %q, want v1", got)
+	}
+	if prev := swap("catalog-v2"); prev != "catalog-v1" {
+		t.Errorf("previous target = %q, want catalog-v1", prev)
+	}
+	if got := read(); got != "v2" {
+		t.Errorf("read after the swap = %q, want v2", got)
+	}
+
+	dbs, err := c.ListDatabases(ctx)
+	if err != nil {
+		t.Fatalf("ListDatabases: %v", err)
+	}
+	var target string
+	for _, d := range dbs {
+		if d.Name == "catalog" {
+			target = d.AliasTarget
+		}
+	}
+	if target != "catalog-v2" {
+		t.Errorf("ListDatabases reports the alias pointing at %q, want catalog-v2", target)
+	}
+}
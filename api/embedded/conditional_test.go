@@ -0,0 +1,135 @@
+package embedded_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+)
+
+func newTestStub(t *testing.T) pb.RocksDBServiceClient {
+	t.Helper()
+	cc, err := newTestServer(t, embedded.Options{}).Dial()
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return pb.NewRocksDBServiceClient(cc)
+}
+
+// conditionFailure returns the ConditionFailure detail of a
+// FailedPrecondition error
+func conditionFailure(t *testing.T, err error) *pb.ConditionFailure {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("error %v, want FailedPrecondition", err)
+	}
+	for _, detail := range st.Details() {
+		if cf, ok := detail.(*pb.ConditionFailure); ok {
+			return cf
+		}
+	}
+	t.Fatalf("FailedPrecondition without a ConditionFailure detail: %v", err)
+	return nil
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	stub := newTestStub(t)
+	put, err := stub.Put(ctx, &pb.PutRequest{DatabaseName: "db", Key: "k", Value: []byte("one")})
+	if err != nil || !put.Success {
+		t.Fatalf("Put = %v, %v", put, err)
+	}
+
+	resp, err := stub.CompareAndSwap(ctx, &pb.CompareAndSwapRequest{DatabaseName: "db", Key: "k", ExpectedValue: []byte("one"), Value: []byte("two")})
+	if err != nil || !resp.Success || resp.Version <= put.Version {
+		t.Fatalf("CompareAndSwap with the current value = %v, %v; want a version above %d", resp, err, put.Version)
+	}
+
+	_, err = stub.CompareAndSwap(ctx, &pb.CompareAndSwapRequest{DatabaseName: "db", Key: "k", ExpectedValue: []byte("one"), Value: []byte("three")})
+	cf := conditionFailure(t, err)
+	if !cf.Found || string(cf.CurrentValue) != "two" || cf.CurrentVersion != resp.Version {
+		t.Errorf("ConditionFailure = %v, want two at version %d", cf, resp.Version)
+	}
+
+	_, err = stub.CompareAndSwap(ctx, &pb.CompareAndSwapRequest{DatabaseName: "db", Key: "missing", ExpectedValue: []byte("one"), Value: []byte("two")})
+	if cf := conditionFailure(t, err); cf.Found {
+		t.Errorf("ConditionFailure of a missing key = %v, want Found false", cf)
+	}
+}
+
+func TestCompareAndSwapConcurrently(t *testing.T) {
+	ctx := context.Background()
+	stub := newTestStub(t)
+	if _, err := stub.Put(ctx, &pb.PutRequest{DatabaseName: "db", Key: "k", Value: []byte("initial")}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	const writers = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		winners []string
+	)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value := fmt.Sprintf("writer%d", i)
+			resp, err := stub.CompareAndSwap(ctx, &pb.CompareAndSwapRequest{DatabaseName: "db", Key: "k", ExpectedValue: []byte("initial"), Value: []byte(value)})
+			if status.Code(err) == codes.FailedPrecondition {
+				return
+			}
+			if err != nil || !resp.Success {
+				t.Errorf("CompareAndSwap = %v, %v", resp, err)
+				return
+			}
+			mu.Lock()
+			winners = append(winners, value)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(winners) != 1 {
+		t.Fatalf("%d CompareAndSwaps succeeded, want 1: %v", len(winners), winners)
+	}
+	got, err := stub.Get(ctx, &pb.GetRequest{DatabaseName: "db", Key: "k"})
+	if err != nil || string(got.Value) != winners[0] {
+		t.Errorf("Get = %v, %v; want %s", got, err, winners[0])
+	}
+}
+
+func TestDeleteIfEquals(t *testing.T) {
+	ctx := context.Background()
+	stub := newTestStub(t)
+	put, err := stub.Put(ctx, &pb.PutRequest{DatabaseName: "db", Key: "k", Value: []byte("one")})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	_, err = stub.DeleteIfEquals(ctx, &pb.DeleteIfEqualsRequest{DatabaseName: "db", Key: "k", ExpectedValue: []byte("other")})
+	cf := conditionFailure(t, err)
+	if !cf.Found || string(cf.CurrentValue) != "one" || cf.CurrentVersion != put.Version {
+		t.Errorf("ConditionFailure = %v, want one at version %d", cf, put.Version)
+	}
+
+	resp, err := stub.DeleteIfEquals(ctx, &pb.DeleteIfEqualsRequest{DatabaseName: "db", Key: "k", ExpectedValue: []byte("one")})
+	if err != nil || !resp.Success {
+		t.Fatalf("DeleteIfEquals with the current value = %v, %v", resp, err)
+	}
+	if got, err := stub.Get(ctx, &pb.GetRequest{DatabaseName: "db", Key: "k"}); err != nil || got.Found {
+		t.Errorf("Get after DeleteIfEquals = %v, %v; want not found", got, err)
+	}
+
+	_, err = stub.DeleteIfEquals(ctx, &pb.DeleteIfEqualsRequest{DatabaseName: "db", Key: "k", ExpectedValue: []byte("one")})
+	if cf := conditionFailure(t, err); cf.Found {
+		t.Errorf("ConditionFailure of a deleted key = %v, want Found false", cf)
+	}
+}
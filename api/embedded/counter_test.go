@@ -0,0 +1,37 @@
+package embedded_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+)
+
+func TestCounters(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t, embedded.Options{}), client.Options{})
+
+	limit := int64(10)
+	if counter, err := c.Increment(ctx, "db", client.Increment{Key: "n", Delta: 7, Max: &limit}); err != nil || counter.Value != 7 {
+		t.Fatalf("Increment = %+v, %v; want 7", counter, err)
+	}
+	if _, err := c.Increment(ctx, "db", client.Increment{Key: "n", Delta: 7, Max: &limit}); !errors.Is(err, client.ErrConditionFailed) {
+		t.Fatalf("Increment over the bound: %v, want ErrConditionFailed", err)
+	}
+
+	// A failing increment leaves the others unapplied
+	_, err := c.IncrementMany(ctx, "db", []client.Increment{{Key: "m", Delta: 1}, {Key: "n", Delta: 7, Max: &limit}})
+	if !errors.Is(err, client.ErrConditionFailed) {
+		t.Fatalf("IncrementMany over a bound: %v, want ErrConditionFailed", err)
+	}
+	if counter, err := c.GetCounter(ctx, "db", "m"); err != nil || counter.Value != 0 {
+		t.Fatalf("counter after a failed IncrementMany = %+v, %v; want 0", counter, err)
+	}
+
+	counters, err := c.IncrementMany(ctx, "db", []client.Increment{{Key: "m", Delta: 1}, {Key: "n", Delta: -2}})
+	if err != nil || len(counters) != 2 || counters[0].Value != 1 || counters[1].Value != 5 {
+		t.Fatalf("IncrementMany = %+v, %v; want 1 and 5", counters, err)
+	}
+}
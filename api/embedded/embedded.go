@@ -0,0 +1,294 @@
+// Package embedded runs the RocksDB service in process. Client serves the
+// databases directly, without a network hop, behind the same client.KV
+// interface as the remote client, and Open picks one of the two by
+// configuration. Server serves the gRPC API over an in-memory connection
+// for integration tests.
+package embedded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"rocksdb-service/api/client"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+	"rocksdb-service/internal/server"
+)
+
+// Options configures databases opened in process
+type Options struct {
+	// Dir is the data directory, like the server's -db-path
+	Dir string
+	// Backend is "rocksdb" or "memory". If empty, it is "rocksdb" when Dir
+	// is set and "memory" otherwise.
+	Backend string
+	// AliasRetention is the number of previous alias targets kept for
+	// rollback
+	AliasRetention int
+	// History enables history mode for the named databases, keeping
+	// previous values for the given duration
+	History map[string]time.Duration
+}
+
+// manager opens a DBManager with opts
+func (o Options) manager() (*db.DBManager, error) {
+	backend := o.Backend
+	if backend == "" {
+		backend = db.BackendRocksDB
+		if o.Dir == "" {
+			backend = db.BackendMemory
+		}
+	}
+	if backend == db.BackendRocksDB && o.Dir == "" {
+		return nil, fmt.Errorf("the %s backend requires a data directory", db.BackendRocksDB)
+	}
+
+	databases := make(map[string]db.DBOptions, len(o.History))
+	for name, retention := range o.History {
+		databases[name] = db.DBOptions{HistoryRetention: retention}
+	}
+	return db.NewDBManager(o.Dir, db.Options{
+		Backend:        backend,
+		AliasRetention: o.AliasRetention,
+		Databases:      databases,
+	})
+}
+
+// Config selects between a remote server and databases opened in process
+type Config struct {
+	// Address is the address of a remote server. If empty, databases are
+	// opened in process.
+	Address string
+	// Client configures the connection to a remote server
+	Client client.Options
+	// Embedded configures the databases opened in process
+	Embedded Options
+}
+
+// Open returns a client of the remote server at cfg.Address, or of
+// databases opened in process if no address is set
+func Open(cfg Config) (client.KV, error) {
+	if cfg.Address != "" {
+		return client.New(cfg.Address, cfg.Client)
+	}
+	return New(cfg.Embedded)
+}
+
+// Client serves databases opened in process. It runs the server's request
+// handlers directly, so results and errors are those of a remote server
+// without authentication or quotas. It is safe for concurrent use.
+type Client struct {
+	manager *db.DBManager
+	srv     *server.Server
+}
+
+var _ client.KV = (*Client)(nil)
+
+// New opens the databases configured by opts. Only one Client or Server
+// may use a data directory at a time.
+func New(opts Options) (*Client, error) {
+	manager, err := opts.manager()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{manager: manager, srv: server.New(manager, server.Options{})}, nil
+}
+
+// Close closes all databases
+func (c *Client) Close() error {
+	c.manager.Close()
+	return nil
+}
+
+// Get returns the value of key. A missing key is reported as an error
+// matching client.ErrNotFound.
+func (c *Client) Get(ctx context.Context, database, key string) (*client.Item, error) {
+	resp, err := c.srv.Get(ctx, &pb.GetRequest{DatabaseName: database, Key: key})
+	if err != nil {
+		return nil, statusError("Get", err)
+	}
+	if resp.Error != "" {
+		return nil, responseError("Get", resp.Error)
+	}
+	if !resp.Found {
+		return nil, &client.Error{Op: "Get", Code: codes.NotFound, Message: fmt.Sprintf("key %q not found", key)}
+	}
+	return &client.Item{Key: key, Value: resp.Value, Version: resp.Version}, nil
+}
+
+// Put stores value under key and returns its new version
+func (c *Client) Put(ctx context.Context, database, key string, value []byte) (uint64, error) {
+	return c.put(ctx, &pb.PutRequest{DatabaseName: database, Key: key, Value: value})
+}
+
+// PutIfVersion stores value under key only if the key's current version is
+// version, 0 meaning the key must not exist. A mismatch is reported as an
+// error matching client.ErrConditionFailed.
+func (c *Client) PutIfVersion(ctx context.Context, database, key string, value []byte, version uint64) (uint64, error) {
+	return c.put(ctx, &pb.PutRequest{DatabaseName: database, Key: key, Value: value, IfVersion: &version})
+}
+
+func (c *Client) put(ctx context.Context, req *pb.PutRequest) (uint64, error) {
+	resp, err := c.srv.Put(ctx, req)
+	if err != nil {
+		return 0, statusError("Put", err)
+	}
+	if !resp.Success {
+		return 0, responseError("Put", resp.Error)
+	}
+	return resp.Version, nil
+}
+
+// Delete removes key. Deleting a missing key succeeds.
+func (c *Client) Delete(ctx context.Context, database, key string) error {
+	resp, err := c.srv.Delete(ctx, &pb.DeleteRequest{DatabaseName: database, Key: key})
+	if err != nil {
+		return statusError("Delete", err)
+	}
+	if !resp.Success {
+		return responseError("Delete", resp.Error)
+	}
+	return nil
+}
+
+// Increment adds inc.Delta to a counter and returns its new value. An
+// increment out of range fails with an error matching
+// client.ErrConditionFailed.
+func (c *Client) Increment(ctx context.Context, database string, inc client.Increment) (*client.Counter, error) {
+	resp, err := c.srv.Increment(ctx, &pb.IncrementRequest{
+		DatabaseName: database,
+		Key:          inc.Key,
+		Delta:        inc.Delta,
+		Min:          inc.Min,
+		Max:          inc.Max,
+	})
+	if err != nil {
+		return nil, statusError("Increment", err)
+	}
+	if !resp.Success {
+		return nil, responseError("Increment", resp.Error)
+	}
+	return &client.Counter{Key: inc.Key, Value: resp.Value, Version: resp.Version}, nil
+}
+
+// IncrementMany applies incs atomically, in order, and returns the new
+// values
+func (c *Client) IncrementMany(ctx context.Context, database string, incs []client.Increment) ([]client.Counter, error) {
+	req := &pb.IncrementManyRequest{DatabaseName: database, Increments: make([]*pb.CounterIncrement, len(incs))}
+	for i, inc := range incs {
+		req.Increments[i] = &pb.CounterIncrement{Key: inc.Key, Delta: inc.Delta, Min: inc.Min, Max: inc.Max}
+	}
+	resp, err := c.srv.IncrementMany(ctx, req)
+	if err != nil {
+		return nil, statusError("IncrementMany", err)
+	}
+	if !resp.Success {
+		return nil, responseError("IncrementMany", resp.Error)
+	}
+	counters := make([]client.Counter, len(resp.Counters))
+	for i, counter := range resp.Counters {
+		counters[i] = client.Counter{Key: counter.Key, Value: counter.Value, Version: counter.Version}
+	}
+	return counters, nil
+}
+
+// GetCounter returns the value of a counter, 0 if it does not exist
+func (c *Client) GetCounter(ctx context.Context, database, key string) (*client.Counter, error) {
+	resp, err := c.srv.GetCounter(ctx, &pb.GetCounterRequest{DatabaseName: database, Key: key})
+	if err != nil {
+		return nil, statusError("GetCounter", err)
+	}
+	if resp.Error != "" {
+		return nil, responseError("GetCounter", resp.Error)
+	}
+	return &client.Counter{Key: key, Value: resp.Value, Version: resp.Version}, nil
+}
+
+// Scan returns an iterator over the items of database in key order.
+// Iteration stops after the first error.
+func (c *Client) Scan(ctx context.Context, database string, opts client.ScanOptions) iter.Seq2[*client.Item, error] {
+	return func(yield func(*client.Item, error) bool) {
+		// Canceling the context stops the database scan once the handler
+		// returns early
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream := &scanStream{ctx: ctx, yield: yield}
+		err := c.srv.Scan(&pb.ScanRequest{
+			DatabaseName: database,
+			Prefix:       opts.Prefix,
+			StartKey:     opts.Start,
+			EndKey:       opts.End,
+			Limit:        opts.Limit,
+			KeysOnly:     opts.KeysOnly,
+		}, stream)
+		if err != nil && !stream.stopped {
+			yield(nil, statusError("Scan", err))
+		}
+	}
+}
+
+// errStopped fails a send after iteration has stopped
+var errStopped = errors.New("iteration stopped")
+
+// scanStream passes the responses of the Scan handler to an iterator
+type scanStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	yield   func(*client.Item, error) bool
+	stopped bool
+}
+
+func (s *scanStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *scanStream) Send(resp *pb.ScanResponse) error {
+	if resp.Error != "" {
+		s.yield(nil, responseError("Scan", resp.Error))
+		s.stopped = true
+		return errStopped
+	}
+	if !s.yield(&client.Item{Key: resp.Key, Value: resp.Value, Version: resp.Version}, nil) {
+		s.stopped = true
+		return errStopped
+	}
+	return nil
+}
+
+// ListDatabases lists the databases and aliases
+func (c *Client) ListDatabases(ctx context.Context) ([]client.Database, error) {
+	resp, err := c.srv.ListDatabases(ctx, &pb.ListDatabasesRequest{})
+	if err != nil {
+		return nil, statusError("ListDatabases", err)
+	}
+	databases := make([]client.Database, len(resp.Databases))
+	for i, d := range resp.Databases {
+		databases[i] = client.Database{Name: d.Name, AliasTarget: d.AliasTarget, Open: d.Open}
+	}
+	return databases, nil
+}
+
+// statusError converts an error returned by a handler like the remote
+// client converts gRPC errors
+func statusError(op string, err error) error {
+	st := status.Convert(err)
+	e := &client.Error{Op: op, Code: st.Code(), Message: st.Message()}
+	for _, detail := range st.Details() {
+		if cond, ok := detail.(*pb.ConditionFailure); ok {
+			e.Condition = cond
+		}
+	}
+	return e
+}
+
+// responseError converts the error field of a response
+func responseError(op, message string) error {
+	return &client.Error{Op: op, Code: codes.Internal, Message: message}
+}
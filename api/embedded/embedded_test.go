@@ -0,0 +1,60 @@
+package embedded_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	kv, err := embedded.Open(embedded.Config{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer kv.Close()
+
+	if _, err := kv.Get(ctx, "db", "k"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Get of a missing key: %v, want ErrNotFound", err)
+	}
+	version, err := kv.PutIfVersion(ctx, "db", "k", []byte("v"), 0)
+	if err != nil {
+		t.Fatalf("PutIfVersion(0): %v", err)
+	}
+	if _, err := kv.PutIfVersion(ctx, "db", "k", []byte("v"), 0); !errors.Is(err, client.ErrConditionFailed) {
+		t.Fatalf("PutIfVersion(0) on an existing key: %v, want ErrConditionFailed", err)
+	}
+	if _, err := kv.Put(ctx, "db", "l", []byte("w")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	var items []*client.Item
+	for item, err := range kv.Scan(ctx, "db", client.ScanOptions{Limit: 1}) {
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		items = append(items, item)
+	}
+	if len(items) != 1 || items[0].Key != "k" || items[0].Version != version {
+		t.Fatalf("Scan = %+v, want k at version %d", items, version)
+	}
+
+	// Stopping iteration early ends the scan
+	for range kv.Scan(ctx, "db", client.ScanOptions{}) {
+		break
+	}
+
+	dbs, err := kv.ListDatabases(ctx)
+	if err != nil || len(dbs) != 1 || dbs[0].Name != "db" {
+		t.Fatalf("ListDatabases = %+v, %v; want db", dbs, err)
+	}
+}
+
+func TestOptionsRequireDirForRocksDB(t *testing.T) {
+	if _, err := embedded.New(embedded.Options{Backend: "rocksdb"}); err == nil {
+		t.Error("the rocksdb backend was opened without a data directory")
+	}
+}
@@ -0,0 +1,57 @@
+package embedded_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+)
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t, embedded.Options{History: map[string]time.Duration{"hist": time.Hour}}), client.Options{})
+
+	if _, err := c.Put(ctx, "hist", "k", []byte("one")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	between := time.Now()
+	time.Sleep(time.Millisecond)
+	if _, err := c.Put(ctx, "hist", "k", []byte("two")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	asOf, err := c.Stub().GetAsOf(ctx, &pb.GetAsOfRequest{DatabaseName: "hist", Key: "k", Timestamp: timestamppb.New(between)})
+	if err != nil || !asOf.Found || string(asOf.Value) != "one" {
+		t.Fatalf("GetAsOf = %v, %v; want one", asOf, err)
+	}
+
+	stream, err := c.Stub().GetHistory(ctx, &pb.GetHistoryRequest{DatabaseName: "hist", Key: "k"})
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	var values []string
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		values = append(values, string(resp.Value))
+	}
+	if len(values) != 2 || values[0] != "two" || values[1] != "one" {
+		t.Fatalf("GetHistory = %q, want [two one]", values)
+	}
+
+	if _, err := c.Stub().GetAsOf(ctx, &pb.GetAsOfRequest{DatabaseName: "plain", Key: "k", Timestamp: timestamppb.Now()}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("GetAsOf without history mode: %v, want FailedPrecondition", err)
+	}
+}
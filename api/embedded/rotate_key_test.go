@@ -0,0 +1,47 @@
+package embedded_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+)
+
+func TestRotateKeyJob(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t, embedded.Options{}), client.Options{})
+	if _, err := c.Put(ctx, "db", "k", []byte("v")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	resp, err := c.Stub().RotateKey(ctx, &pb.RotateKeyRequest{DatabaseName: "db"})
+	if err != nil || !resp.Success {
+		t.Fatalf("RotateKey = %v, %v", resp, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jobs, err := c.Stub().ListJobs(ctx, &pb.ListJobsRequest{DatabaseName: "db"})
+		if err != nil {
+			t.Fatalf("ListJobs: %v", err)
+		}
+		if len(jobs.Jobs) != 1 || jobs.Jobs[0].Id != resp.JobId {
+			t.Fatalf("ListJobs = %v, want the rotation job", jobs.Jobs)
+		}
+		job := jobs.Jobs[0]
+		if job.State != "running" {
+			// The database is not encrypted, so there is nothing to rotate
+			if job.State != "failed" || job.Error == "" || job.Finished == nil {
+				t.Fatalf("rotation of an unencrypted database ended as %v", job)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("rotation job did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
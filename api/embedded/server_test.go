@@ -0,0 +1,129 @@
+package embedded_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/auth"
+)
+
+func newTestServer(t *testing.T, opts embedded.Options, serverOpts ...grpc.ServerOption) *embedded.Server {
+	t.Helper()
+	srv, err := embedded.NewServer(opts, serverOpts...)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(t *testing.T, srv *embedded.Server, opts client.Options) *client.Client {
+	t.Helper()
+	c, err := srv.Client(opts)
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestScanAndStreamGet(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t, embedded.Options{}), client.Options{})
+	for _, key := range []string{"user:1", "user:2", "user:3", "order:1"} {
+		if _, err := c.Put(ctx, "db", key, []byte(key)); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	var keys []string
+	for item, err := range c.Scan(ctx, "db", client.ScanOptions{Prefix: "user:", Start: "user:2", Limit: 5, KeysOnly: true}) {
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		if item.Value != nil {
+			t.Errorf("keys-only scan returned a value for %s", item.Key)
+		}
+		keys = append(keys, item.Key)
+	}
+	if len(keys) != 2 || keys[0] != "user:2" || keys[1] != "user:3" {
+		t.Errorf("Scan = %q, want [user:2 user:3]", keys)
+	}
+
+	stream, err := c.Stub().StreamGet(ctx, &pb.StreamGetRequest{
+		DatabaseName: "db",
+		Query:        &pb.StreamGetRequest_Keys{Keys: &pb.KeySet{Keys: []string{"order:1", "missing", "user:1"}}},
+	})
+	if err != nil {
+		t.Fatalf("StreamGet: %v", err)
+	}
+	var got []string
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		got = append(got, resp.Key+"="+string(resp.Value))
+	}
+	if len(got) != 2 || got[0] != "order:1=order:1" || got[1] != "user:1=user:1" {
+		t.Errorf("StreamGet = %q, want the two existing keys", got)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	acl, err := auth.NewACL([]auth.Rule{
+		{Principal: "etl", Databases: []string{"*"}, Access: "write"},
+		{Principal: "web", Databases: []string{"*"}, Access: "read"},
+	})
+	if err != nil {
+		t.Fatalf("NewACL: %v", err)
+	}
+	tokens := tokenMap{"tok-etl": "etl", "tok-web": "web"}
+	interceptor := auth.NewInterceptor(acl, tokens)
+	srv := newTestServer(t, embedded.Options{},
+		grpc.ChainUnaryInterceptor(interceptor.Unary()),
+		grpc.ChainStreamInterceptor(interceptor.Stream()))
+
+	ctx := context.Background()
+	etl := newTestClient(t, srv, client.Options{Token: "tok-etl"})
+	web := newTestClient(t, srv, client.Options{Token: "tok-web"})
+	anonymous := newTestClient(t, srv, client.Options{})
+
+	if _, err := etl.Put(ctx, "db", "k", []byte("v")); err != nil {
+		t.Fatalf("Put with write access: %v", err)
+	}
+	if _, err := web.Get(ctx, "db", "k"); err != nil {
+		t.Errorf("Get with read access: %v", err)
+	}
+	if _, err := web.Put(ctx, "db", "k", []byte("v")); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Put with read access: %v, want PermissionDenied", err)
+	}
+	if _, err := anonymous.Get(ctx, "db", "k"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Get without a token: %v, want Unauthenticated", err)
+	}
+	for _, err := range web.Scan(ctx, "db", client.ScanOptions{}) {
+		if err != nil {
+			t.Errorf("Scan with read access: %v", err)
+		}
+	}
+}
+
+// tokenMap is an auth.TokenVerifier backed by a map
+type tokenMap map[string]string
+
+func (m tokenMap) Verify(token string) (string, error) {
+	if principal, ok := m[token]; ok {
+		return principal, nil
+	}
+	return "", auth.ErrInvalidToken
+}
@@ -0,0 +1,91 @@
+package embedded_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+)
+
+func TestStreamWrite(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t, embedded.Options{}), client.Options{})
+
+	stream, err := c.Stub().StreamWrite(ctx)
+	if err != nil {
+		t.Fatalf("StreamWrite: %v", err)
+	}
+	reqs := []*pb.StreamWriteRequest{
+		{DatabaseName: "db", Key: "a", Value: []byte("1"), BatchSize: 2},
+		{Key: "b", Value: []byte("2")},
+		{Key: "c", Value: []byte("3")},
+		{Key: "a", Delete: true},
+	}
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+
+	var committed uint64
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if resp.Error != "" {
+			t.Fatalf("batch failed: %s", resp.Error)
+		}
+		if resp.CommittedSequence <= committed {
+			t.Fatalf("committed sequence went from %d to %d", committed, resp.CommittedSequence)
+		}
+		committed = resp.CommittedSequence
+	}
+	if committed != uint64(len(reqs)) {
+		t.Fatalf("last committed sequence = %d, want %d", committed, len(reqs))
+	}
+
+	var keys []string
+	for item, err := range c.Scan(ctx, "db", client.ScanOptions{}) {
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		keys = append(keys, item.Key)
+	}
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "c" {
+		t.Fatalf("keys after the stream = %q, want [b c]", keys)
+	}
+}
+
+func TestStreamWriteRejectsDecreasingSequence(t *testing.T) {
+	c := newTestClient(t, newTestServer(t, embedded.Options{}), client.Options{})
+
+	stream, err := c.Stub().StreamWrite(context.Background())
+	if err != nil {
+		t.Fatalf("StreamWrite: %v", err)
+	}
+	stream.Send(&pb.StreamWriteRequest{DatabaseName: "db", Sequence: 5, Key: "a"})
+	stream.Send(&pb.StreamWriteRequest{Sequence: 5, Key: "b"})
+	stream.CloseSend()
+	for {
+		_, err := stream.Recv()
+		if err == nil {
+			continue
+		}
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("stream ended with %v, want InvalidArgument", err)
+		}
+		return
+	}
+}
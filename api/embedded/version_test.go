@@ -0,0 +1,46 @@
+package embedded_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+)
+
+func TestPutGetVersions(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t, embedded.Options{}), client.Options{})
+
+	if _, err := c.Get(ctx, "db", "k"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Get of a missing key: %v, want ErrNotFound", err)
+	}
+
+	v1, err := c.PutIfVersion(ctx, "db", "k", []byte("one"), 0)
+	if err != nil {
+		t.Fatalf("PutIfVersion(0) creating the key: %v", err)
+	}
+	if _, err := c.PutIfVersion(ctx, "db", "k", []byte("again"), 0); !errors.Is(err, client.ErrConditionFailed) {
+		t.Fatalf("PutIfVersion(0) on an existing key: %v, want ErrConditionFailed", err)
+	}
+	v2, err := c.PutIfVersion(ctx, "db", "k", []byte("two"), v1)
+	if err != nil || v2 <= v1 {
+		t.Fatalf("PutIfVersion with the current version = %d, %v; want a version above %d", v2, err, v1)
+	}
+	if _, err := c.PutIfVersion(ctx, "db", "k", []byte("stale"), v1); !errors.Is(err, client.ErrConditionFailed) {
+		t.Fatalf("PutIfVersion with a stale version: %v, want ErrConditionFailed", err)
+	}
+
+	item, err := c.Get(ctx, "db", "k")
+	if err != nil || string(item.Value) != "two" || item.Version != v2 {
+		t.Fatalf("Get = %+v, %v; want two at version %d", item, err, v2)
+	}
+
+	if err := c.Delete(ctx, "db", "k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Get(ctx, "db", "k"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Get after Delete: %v, want ErrNotFound", err)
+	}
+}
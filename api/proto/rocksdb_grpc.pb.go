@@ -19,10 +19,26 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	RocksDBService_Put_FullMethodName       = "/rocksdb.RocksDBService/Put"
-	RocksDBService_Get_FullMethodName       = "/rocksdb.RocksDBService/Get"
-	RocksDBService_Delete_FullMethodName    = "/rocksdb.RocksDBService/Delete"
-	RocksDBService_StreamGet_FullMethodName = "/rocksdb.RocksDBService/StreamGet"
+	RocksDBService_Put_FullMethodName            = "/rocksdb.RocksDBService/Put"
+	RocksDBService_Get_FullMethodName            = "/rocksdb.RocksDBService/Get"
+	RocksDBService_Delete_FullMethodName         = "/rocksdb.RocksDBService/Delete"
+	RocksDBService_StreamGet_FullMethodName      = "/rocksdb.RocksDBService/StreamGet"
+	RocksDBService_GetAsOf_FullMethodName        = "/rocksdb.RocksDBService/GetAsOf"
+	RocksDBService_GetHistory_FullMethodName     = "/rocksdb.RocksDBService/GetHistory"
+	RocksDBService_CompareAndSwap_FullMethodName = "/rocksdb.RocksDBService/CompareAndSwap"
+	RocksDBService_PutIfAbsent_FullMethodName    = "/rocksdb.RocksDBService/PutIfAbsent"
+	RocksDBService_DeleteIfEquals_FullMethodName = "/rocksdb.RocksDBService/DeleteIfEquals"
+	RocksDBService_StreamWrite_FullMethodName    = "/rocksdb.RocksDBService/StreamWrite"
+	RocksDBService_SwapAlias_FullMethodName      = "/rocksdb.RocksDBService/SwapAlias"
+	RocksDBService_GetUsage_FullMethodName       = "/rocksdb.RocksDBService/GetUsage"
+	RocksDBService_RotateKey_FullMethodName      = "/rocksdb.RocksDBService/RotateKey"
+	RocksDBService_ListJobs_FullMethodName       = "/rocksdb.RocksDBService/ListJobs"
+	RocksDBService_Scan_FullMethodName           = "/rocksdb.RocksDBService/Scan"
+	RocksDBService_ListDatabases_FullMethodName  = "/rocksdb.RocksDBService/ListDatabases"
+	RocksDBService_Increment_FullMethodName      = "/rocksdb.RocksDBService/Increment"
+	RocksDBService_GetCounter_FullMethodName     = "/rocksdb.RocksDBService/GetCounter"
+	RocksDBService_IncrementMany_FullMethodName  = "/rocksdb.RocksDBService/IncrementMany"
+	RocksDBService_GetStats_FullMethodName       = "/rocksdb.RocksDBService/GetStats"
 )
 
 // RocksDBServiceClient is the client API for RocksDBService service.
@@ -37,6 +53,38 @@ type RocksDBServiceClient interface {
 	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
 	// StreamGet retrieves multiple key-value pairs based on exact keys or prefix from the specified database
 	StreamGet(ctx context.Context, in *StreamGetRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamGetResponse], error)
+	// GetAsOf retrieves the value a key held at a point in time from a database with history enabled
+	GetAsOf(ctx context.Context, in *GetAsOfRequest, opts ...grpc.CallOption) (*GetAsOfResponse, error)
+	// GetHistory streams the current and retained previous values of a key, newest first
+	GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetHistoryResponse], error)
+	// CompareAndSwap replaces a value only if the current value matches the expected one
+	CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapResponse, error)
+	// PutIfAbsent stores a key-value pair only if the key does not exist
+	PutIfAbsent(ctx context.Context, in *PutIfAbsentRequest, opts ...grpc.CallOption) (*PutIfAbsentResponse, error)
+	// DeleteIfEquals removes a key only if its current value matches the expected one
+	DeleteIfEquals(ctx context.Context, in *DeleteIfEqualsRequest, opts ...grpc.CallOption) (*DeleteIfEqualsResponse, error)
+	// StreamWrite applies a stream of puts and deletes in batches, acknowledging committed sequence numbers
+	StreamWrite(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamWriteRequest, StreamWriteResponse], error)
+	// SwapAlias atomically points an alias at a physical database, retaining the previous target for rollback
+	SwapAlias(ctx context.Context, in *SwapAliasRequest, opts ...grpc.CallOption) (*SwapAliasResponse, error)
+	// GetUsage reports quota usage of principals and databases since startup
+	GetUsage(ctx context.Context, in *GetUsageRequest, opts ...grpc.CallOption) (*GetUsageResponse, error)
+	// RotateKey starts a background job re-encrypting a database with the current encryption key
+	RotateKey(ctx context.Context, in *RotateKeyRequest, opts ...grpc.CallOption) (*RotateKeyResponse, error)
+	// ListJobs lists running and recently finished background jobs
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	// Scan streams key-value pairs in key order, optionally restricted to a prefix and a key range
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScanResponse], error)
+	// ListDatabases lists the databases and aliases on the server
+	ListDatabases(ctx context.Context, in *ListDatabasesRequest, opts ...grpc.CallOption) (*ListDatabasesResponse, error)
+	// Increment atomically adds a delta to a 64-bit counter and returns its new value
+	Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error)
+	// GetCounter returns the value of a counter
+	GetCounter(ctx context.Context, in *GetCounterRequest, opts ...grpc.CallOption) (*GetCounterResponse, error)
+	// IncrementMany atomically applies increments to several counters, all or none
+	IncrementMany(ctx context.Context, in *IncrementManyRequest, opts ...grpc.CallOption) (*IncrementManyResponse, error)
+	// GetStats returns RocksDB statistics of a database
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
 type rocksDBServiceClient struct {
@@ -96,6 +144,187 @@ func (c *rocksDBServiceClient) StreamGet(ctx context.Context, in *StreamGetReque
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type RocksDBService_StreamGetClient = grpc.ServerStreamingClient[StreamGetResponse]
 
+func (c *rocksDBServiceClient) GetAsOf(ctx context.Context, in *GetAsOfRequest, opts ...grpc.CallOption) (*GetAsOfResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetAsOfResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_GetAsOf_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetHistoryResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RocksDBService_ServiceDesc.Streams[1], RocksDBService_GetHistory_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetHistoryRequest, GetHistoryResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RocksDBService_GetHistoryClient = grpc.ServerStreamingClient[GetHistoryResponse]
+
+func (c *rocksDBServiceClient) CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompareAndSwapResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_CompareAndSwap_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) PutIfAbsent(ctx context.Context, in *PutIfAbsentRequest, opts ...grpc.CallOption) (*PutIfAbsentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PutIfAbsentResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_PutIfAbsent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) DeleteIfEquals(ctx context.Context, in *DeleteIfEqualsRequest, opts ...grpc.CallOption) (*DeleteIfEqualsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteIfEqualsResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_DeleteIfEquals_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) StreamWrite(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamWriteRequest, StreamWriteResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RocksDBService_ServiceDesc.Streams[2], RocksDBService_StreamWrite_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamWriteRequest, StreamWriteResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RocksDBService_StreamWriteClient = grpc.BidiStreamingClient[StreamWriteRequest, StreamWriteResponse]
+
+func (c *rocksDBServiceClient) SwapAlias(ctx context.Context, in *SwapAliasRequest, opts ...grpc.CallOption) (*SwapAliasResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SwapAliasResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_SwapAlias_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) GetUsage(ctx context.Context, in *GetUsageRequest, opts ...grpc.CallOption) (*GetUsageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUsageResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_GetUsage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) RotateKey(ctx context.Context, in *RotateKeyRequest, opts ...grpc.CallOption) (*RotateKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RotateKeyResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_RotateKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScanResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RocksDBService_ServiceDesc.Streams[3], RocksDBService_Scan_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ScanRequest, ScanResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RocksDBService_ScanClient = grpc.ServerStreamingClient[ScanResponse]
+
+func (c *rocksDBServiceClient) ListDatabases(ctx context.Context, in *ListDatabasesRequest, opts ...grpc.CallOption) (*ListDatabasesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDatabasesResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_ListDatabases_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IncrementResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_Increment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) GetCounter(ctx context.Context, in *GetCounterRequest, opts ...grpc.CallOption) (*GetCounterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCounterResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_GetCounter_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) IncrementMany(ctx context.Context, in *IncrementManyRequest, opts ...grpc.CallOption) (*IncrementManyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IncrementManyResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_IncrementMany_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rocksDBServiceClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, RocksDBService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RocksDBServiceServer is the server API for RocksDBService service.
 // All implementations must embed UnimplementedRocksDBServiceServer
 // for forward compatibility.
@@ -108,6 +337,38 @@ type RocksDBServiceServer interface {
 	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
 	// StreamGet retrieves multiple key-value pairs based on exact keys or prefix from the specified database
 	StreamGet(*StreamGetRequest, grpc.ServerStreamingServer[StreamGetResponse]) error
+	// GetAsOf retrieves the value a key held at a point in time from a database with history enabled
+	GetAsOf(context.Context, *GetAsOfRequest) (*GetAsOfResponse, error)
+	// GetHistory streams the current and retained previous values of a key, newest first
+	GetHistory(*GetHistoryRequest, grpc.ServerStreamingServer[GetHistoryResponse]) error
+	// CompareAndSwap replaces a value only if the current value matches the expected one
+	CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapResponse, error)
+	// PutIfAbsent stores a key-value pair only if the key does not exist
+	PutIfAbsent(context.Context, *PutIfAbsentRequest) (*PutIfAbsentResponse, error)
+	// DeleteIfEquals removes a key only if its current value matches the expected one
+	DeleteIfEquals(context.Context, *DeleteIfEqualsRequest) (*DeleteIfEqualsResponse, error)
+	// StreamWrite applies a stream of puts and deletes in batches, acknowledging committed sequence numbers
+	StreamWrite(grpc.BidiStreamingServer[StreamWriteRequest, StreamWriteResponse]) error
+	// SwapAlias atomically points an alias at a physical database, retaining the previous target for rollback
+	SwapAlias(context.Context, *SwapAliasRequest) (*SwapAliasResponse, error)
+	// GetUsage reports quota usage of principals and databases since startup
+	GetUsage(context.Context, *GetUsageRequest) (*GetUsageResponse, error)
+	// RotateKey starts a background job re-encrypting a database with the current encryption key
+	RotateKey(context.Context, *RotateKeyRequest) (*RotateKeyResponse, error)
+	// ListJobs lists running and recently finished background jobs
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	// Scan streams key-value pairs in key order, optionally restricted to a prefix and a key range
+	Scan(*ScanRequest, grpc.ServerStreamingServer[ScanResponse]) error
+	// ListDatabases lists the databases and aliases on the server
+	ListDatabases(context.Context, *ListDatabasesRequest) (*ListDatabasesResponse, error)
+	// Increment atomically adds a delta to a 64-bit counter and returns its new value
+	Increment(context.Context, *IncrementRequest) (*IncrementResponse, error)
+	// GetCounter returns the value of a counter
+	GetCounter(context.Context, *GetCounterRequest) (*GetCounterResponse, error)
+	// IncrementMany atomically applies increments to several counters, all or none
+	IncrementMany(context.Context, *IncrementManyRequest) (*IncrementManyResponse, error)
+	// GetStats returns RocksDB statistics of a database
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 	mustEmbedUnimplementedRocksDBServiceServer()
 }
 
@@ -130,6 +391,54 @@ func (UnimplementedRocksDBServiceServer) Delete(context.Context, *DeleteRequest)
 func (UnimplementedRocksDBServiceServer) StreamGet(*StreamGetRequest, grpc.ServerStreamingServer[StreamGetResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamGet not implemented")
 }
+func (UnimplementedRocksDBServiceServer) GetAsOf(context.Context, *GetAsOfRequest) (*GetAsOfResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAsOf not implemented")
+}
+func (UnimplementedRocksDBServiceServer) GetHistory(*GetHistoryRequest, grpc.ServerStreamingServer[GetHistoryResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
+}
+func (UnimplementedRocksDBServiceServer) CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareAndSwap not implemented")
+}
+func (UnimplementedRocksDBServiceServer) PutIfAbsent(context.Context, *PutIfAbsentRequest) (*PutIfAbsentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutIfAbsent not implemented")
+}
+func (UnimplementedRocksDBServiceServer) DeleteIfEquals(context.Context, *DeleteIfEqualsRequest) (*DeleteIfEqualsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteIfEquals not implemented")
+}
+func (UnimplementedRocksDBServiceServer) StreamWrite(grpc.BidiStreamingServer[StreamWriteRequest, StreamWriteResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamWrite not implemented")
+}
+func (UnimplementedRocksDBServiceServer) SwapAlias(context.Context, *SwapAliasRequest) (*SwapAliasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SwapAlias not implemented")
+}
+func (UnimplementedRocksDBServiceServer) GetUsage(context.Context, *GetUsageRequest) (*GetUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsage not implemented")
+}
+func (UnimplementedRocksDBServiceServer) RotateKey(context.Context, *RotateKeyRequest) (*RotateKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateKey not implemented")
+}
+func (UnimplementedRocksDBServiceServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedRocksDBServiceServer) Scan(*ScanRequest, grpc.ServerStreamingServer[ScanResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedRocksDBServiceServer) ListDatabases(context.Context, *ListDatabasesRequest) (*ListDatabasesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDatabases not implemented")
+}
+func (UnimplementedRocksDBServiceServer) Increment(context.Context, *IncrementRequest) (*IncrementResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Increment not implemented")
+}
+func (UnimplementedRocksDBServiceServer) GetCounter(context.Context, *GetCounterRequest) (*GetCounterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCounter not implemented")
+}
+func (UnimplementedRocksDBServiceServer) IncrementMany(context.Context, *IncrementManyRequest) (*IncrementManyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IncrementMany not implemented")
+}
+func (UnimplementedRocksDBServiceServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
 func (UnimplementedRocksDBServiceServer) mustEmbedUnimplementedRocksDBServiceServer() {}
 func (UnimplementedRocksDBServiceServer) testEmbeddedByValue()                        {}
 
@@ -216,6 +525,269 @@ func _RocksDBService_StreamGet_Handler(srv interface{}, stream grpc.ServerStream
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type RocksDBService_StreamGetServer = grpc.ServerStreamingServer[StreamGetResponse]
 
+func _RocksDBService_GetAsOf_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAsOfRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).GetAsOf(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_GetAsOf_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).GetAsOf(ctx, req.(*GetAsOfRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_GetHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetHistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RocksDBServiceServer).GetHistory(m, &grpc.GenericServerStream[GetHistoryRequest, GetHistoryResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RocksDBService_GetHistoryServer = grpc.ServerStreamingServer[GetHistoryResponse]
+
+func _RocksDBService_CompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).CompareAndSwap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_CompareAndSwap_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).CompareAndSwap(ctx, req.(*CompareAndSwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_PutIfAbsent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutIfAbsentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).PutIfAbsent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_PutIfAbsent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).PutIfAbsent(ctx, req.(*PutIfAbsentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_DeleteIfEquals_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteIfEqualsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).DeleteIfEquals(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_DeleteIfEquals_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).DeleteIfEquals(ctx, req.(*DeleteIfEqualsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_StreamWrite_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RocksDBServiceServer).StreamWrite(&grpc.GenericServerStream[StreamWriteRequest, StreamWriteResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RocksDBService_StreamWriteServer = grpc.BidiStreamingServer[StreamWriteRequest, StreamWriteResponse]
+
+func _RocksDBService_SwapAlias_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwapAliasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).SwapAlias(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_SwapAlias_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).SwapAlias(ctx, req.(*SwapAliasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_GetUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).GetUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_GetUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).GetUsage(ctx, req.(*GetUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_RotateKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).RotateKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_RotateKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).RotateKey(ctx, req.(*RotateKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RocksDBServiceServer).Scan(m, &grpc.GenericServerStream[ScanRequest, ScanResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RocksDBService_ScanServer = grpc.ServerStreamingServer[ScanResponse]
+
+func _RocksDBService_ListDatabases_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDatabasesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).ListDatabases(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_ListDatabases_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).ListDatabases(ctx, req.(*ListDatabasesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_Increment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).Increment(ctx, req.(*IncrementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_GetCounter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCounterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).GetCounter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_GetCounter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).GetCounter(ctx, req.(*GetCounterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_IncrementMany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementManyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).IncrementMany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_IncrementMany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).IncrementMany(ctx, req.(*IncrementManyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RocksDBService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RocksDBServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RocksDBService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RocksDBServiceServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // RocksDBService_ServiceDesc is the grpc.ServiceDesc for RocksDBService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -235,6 +807,58 @@ var RocksDBService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Delete",
 			Handler:    _RocksDBService_Delete_Handler,
 		},
+		{
+			MethodName: "GetAsOf",
+			Handler:    _RocksDBService_GetAsOf_Handler,
+		},
+		{
+			MethodName: "CompareAndSwap",
+			Handler:    _RocksDBService_CompareAndSwap_Handler,
+		},
+		{
+			MethodName: "PutIfAbsent",
+			Handler:    _RocksDBService_PutIfAbsent_Handler,
+		},
+		{
+			MethodName: "DeleteIfEquals",
+			Handler:    _RocksDBService_DeleteIfEquals_Handler,
+		},
+		{
+			MethodName: "SwapAlias",
+			Handler:    _RocksDBService_SwapAlias_Handler,
+		},
+		{
+			MethodName: "GetUsage",
+			Handler:    _RocksDBService_GetUsage_Handler,
+		},
+		{
+			MethodName: "RotateKey",
+			Handler:    _RocksDBService_RotateKey_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _RocksDBService_ListJobs_Handler,
+		},
+		{
+			MethodName: "ListDatabases",
+			Handler:    _RocksDBService_ListDatabases_Handler,
+		},
+		{
+			MethodName: "Increment",
+			Handler:    _RocksDBService_Increment_Handler,
+		},
+		{
+			MethodName: "GetCounter",
+			Handler:    _RocksDBService_GetCounter_Handler,
+		},
+		{
+			MethodName: "IncrementMany",
+			Handler:    _RocksDBService_IncrementMany_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _RocksDBService_GetStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -242,6 +866,22 @@ var RocksDBService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _RocksDBService_StreamGet_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GetHistory",
+			Handler:       _RocksDBService_GetHistory_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamWrite",
+			Handler:       _RocksDBService_StreamWrite_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Scan",
+			Handler:       _RocksDBService_Scan_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "api/proto/rocksdb.proto",
 }
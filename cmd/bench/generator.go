@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"strconv"
+)
+
+// Key distributions
+const (
+	distUniform = "uniform"
+	distZipfian = "zipfian"
+	distLatest  = "latest"
+)
+
+// zipfianTheta is the skew of the zipfian distributions, YCSB's default
+const zipfianTheta = 0.99
+
+// scrambledItems is the number of items of the zipfian distribution behind
+// scrambled zipfian keys, and scrambledZeta its zeta constant, as in YCSB
+const (
+	scrambledItems = 10_000_000_000
+	scrambledZeta  = 26.46902820178302
+)
+
+// recordKey returns the key of the i-th record. Hashing the record number
+// spreads inserts over the key space, like YCSB's hashed key order.
+func recordKey(i uint64) string {
+	return "user" + strconv.FormatUint(fnvHash(i), 10)
+}
+
+func fnvHash(i uint64) uint64 {
+	h := fnv.New64a()
+	var b [8]byte
+	for j := range b {
+		b[j] = byte(i >> (8 * j))
+	}
+	h.Write(b[:])
+	return h.Sum64()
+}
+
+// chooser picks the record an operation reads or updates among the n
+// records inserted so far. Choosers are not safe for concurrent use; each
+// worker has its own.
+type chooser interface {
+	next(r *rand.Rand, n uint64) uint64
+}
+
+// newChooser returns a chooser of distribution dist for a database of
+// records records
+func newChooser(dist string, records uint64) (func() chooser, error) {
+	switch dist {
+	case distUniform:
+		return func() chooser { return uniform{} }, nil
+	case distZipfian:
+		return func() chooser { return scrambledZipfian{newZipfian(scrambledItems, scrambledZeta)} }, nil
+	case distLatest:
+		// Computing zeta is linear in the number of records, so it is done
+		// once and copied to each worker
+		z := newZipfian(records, zeta(0, records, 0))
+		return func() chooser {
+			c := *z
+			return latest{&c}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown distribution %q: use %s, %s or %s", dist, distUniform, distZipfian, distLatest)
+}
+
+// uniform picks every record with the same probability
+type uniform struct{}
+
+func (uniform) next(r *rand.Rand, n uint64) uint64 {
+	return r.Uint64N(n)
+}
+
+// scrambledZipfian picks popular records, spread over the key space rather
+// than clustered at the first records
+type scrambledZipfian struct {
+	z *zipfian
+}
+
+func (s scrambledZipfian) next(r *rand.Rand, n uint64) uint64 {
+	return fnvHash(s.z.next(r, scrambledItems)) % n
+}
+
+// latest picks recently inserted records most often
+type latest struct {
+	z *zipfian
+}
+
+func (l latest) next(r *rand.Rand, n uint64) uint64 {
+	return n - 1 - min(l.z.next(r, n), n-1)
+}
+
+// zipfian picks item i of n with a probability proportional to
+// 1/(i+1)^theta, using the algorithm of Gray et al., "Quickly Generating
+// Billion-Record Synthetic Databases", as YCSB does
+type zipfian struct {
+	items uint64
+	zetan float64
+	zeta2 float64
+	alpha float64
+	eta   float64
+}
+
+// newZipfian returns a zipfian distribution over items items, whose zeta
+// constant is zetan
+func newZipfian(items uint64, zetan float64) *zipfian {
+	z := &zipfian{
+		items: items,
+		zetan: zetan,
+		zeta2: zeta(0, 2, 0),
+		alpha: 1 / (1 - zipfianTheta),
+	}
+	z.eta = z.computeEta()
+	return z
+}
+
+// zeta adds the terms of the zeta constant from item from to item to to
+// sum
+func zeta(from, to uint64, sum float64) float64 {
+	for i := from; i < to; i++ {
+		sum += 1 / math.Pow(float64(i+1), zipfianTheta)
+	}
+	return sum
+}
+
+func (z *zipfian) computeEta() float64 {
+	return (1 - math.Pow(2/float64(z.items), 1-zipfianTheta)) / (1 - z.zeta2/z.zetan)
+}
+
+// next returns an item of n. The zeta constant is extended incrementally
+// as n grows with inserts.
+func (z *zipfian) next(r *rand.Rand, n uint64) uint64 {
+	if n > z.items {
+		z.zetan = zeta(z.items, n, z.zetan)
+		z.items = n
+		z.eta = z.computeEta()
+	}
+
+	u := r.Float64()
+	uz := u * z.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, zipfianTheta) {
+		return 1
+	}
+	return min(uint64(float64(n)*math.Pow(z.eta*u-z.eta+1, z.alpha)), n-1)
+}
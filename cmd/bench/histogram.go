@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the precision of histograms: values are counted in
+// buckets at most 1/2^(subBucketBits-1) of their value wide, i.e. with an
+// error under 1.6%
+const subBucketBits = 7
+
+const (
+	subBuckets     = 1 << subBucketBits
+	halfSubBuckets = subBuckets / 2
+	bucketCount    = subBuckets + (64-subBucketBits)*halfSubBuckets
+)
+
+// histogram counts latencies in log-linear buckets, like an HDR histogram,
+// so percentiles of long runs take constant memory. Histograms of workers
+// are merged for the report.
+type histogram struct {
+	counts   [bucketCount]uint64
+	count    uint64
+	sum      time.Duration
+	min, max time.Duration
+}
+
+func bucketIndex(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits
+	return subBuckets + (shift-1)*halfSubBuckets + int(v>>shift) - halfSubBuckets
+}
+
+// bucketValue returns the middle of a bucket
+func bucketValue(i int) uint64 {
+	if i < subBuckets {
+		return uint64(i)
+	}
+	shift := (i-subBuckets)/halfSubBuckets + 1
+	top := uint64((i-subBuckets)%halfSubBuckets + halfSubBuckets)
+	return top<<shift + 1<<(shift-1)
+}
+
+func (h *histogram) record(d time.Duration) {
+	d = max(d, 0)
+	h.counts[bucketIndex(uint64(d))]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	h.max = max(h.max, d)
+	h.count++
+	h.sum += d
+}
+
+func (h *histogram) merge(o *histogram) {
+	if o.count == 0 {
+		return
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	h.max = max(h.max, o.max)
+	h.count += o.count
+	h.sum += o.sum
+}
+
+func (h *histogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// percentile returns the latency q of the recorded latencies are at or
+// below, for q in [0, 1]
+func (h *histogram) percentile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= max(rank, 1) {
+			return min(max(time.Duration(bucketValue(i)), h.min), h.max)
+		}
+	}
+	return h.max
+}
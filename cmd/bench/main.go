@@ -0,0 +1,217 @@
+// Command bench measures the throughput and latency of the RocksDB service
+// with the core YCSB workloads A to F. It drives a running server, or one
+// started in process, first loading the records and then running the
+// workload's mix of operations for a duration.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+	"rocksdb-service/internal/db"
+	"rocksdb-service/internal/tlsutil"
+)
+
+func main() {
+	var (
+		serverAddr   = flag.String("server", "localhost:50051", "The server address in the format of host:port")
+		inProcess    = flag.Bool("in-process", false, "Benchmark a server started in process instead of -server")
+		backend      = flag.String("backend", db.BackendMemory, "Storage backend of the in-process server: "+strings.Join(db.Backends, " or "))
+		dbPath       = flag.String("db-path", "", "Data directory of the in-process server, required by the rocksdb backend")
+		tlsCA        = flag.String("tls-ca", "", "CA bundle verifying the server certificate; enables TLS")
+		tlsCert      = flag.String("tls-cert", "", "Client certificate file for mutual TLS; enables TLS")
+		tlsKey       = flag.String("tls-key", "", "Client private key file for mutual TLS")
+		token        = flag.String("token", os.Getenv("ROCKSDB_TOKEN"), "Bearer token sent with each request (default $ROCKSDB_TOKEN)")
+		conns        = flag.Int("conns", 4, "Number of connections the workers share")
+		dbName       = flag.String("db", "bench", "Database to benchmark")
+		workloadName = flag.String("workload", "a", "YCSB workload: a, b, c, d, e or f")
+		distribution = flag.String("distribution", "", "Key distribution: uniform, zipfian or latest (default: the workload's)")
+		mixFlag      = flag.String("mix", "", "Operation proportions, e.g. read=0.9,update=0.1, of read, update, insert, scan and read-modify-write (default: the workload's)")
+		records      = flag.Uint64("records", 100000, "Number of records loaded, and assumed present with -load=false")
+		load         = flag.Bool("load", true, "Insert the records before running the workload")
+		valueMin     = flag.Int("value-size", 1000, "Size of written values in bytes")
+		valueMax     = flag.Int("value-size-max", 0, "If set, values are between -value-size and this many bytes, uniformly")
+		maxScan      = flag.Uint64("max-scan", 100, "Maximum number of records per scan; scan lengths are uniform up to it")
+		concurrency  = flag.Int("concurrency", 16, "Number of concurrent workers")
+		duration     = flag.Duration("duration", 30*time.Second, "Duration of the run")
+		opLimit      = flag.Uint64("operations", 0, "Stop the run after this many operations, if set")
+		seed         = flag.Uint64("seed", 0, "Random seed, for repeatable runs (default: from the time)")
+		progress     = flag.Duration("progress", 10*time.Second, "Interval of progress reports on stderr, 0 to disable")
+		output       = flag.String("output", "text", "Report format: text or json")
+	)
+	flag.Parse()
+
+	wl, ok := workloads[strings.ToLower(*workloadName)]
+	if !ok {
+		log.Fatalf("Unknown workload %q: use a, b, c, d, e or f", *workloadName)
+	}
+	if *distribution != "" {
+		wl.distribution = *distribution
+	}
+	if *mixFlag != "" {
+		mix, err := parseMix(*mixFlag)
+		if err != nil {
+			log.Fatalf("Invalid -mix: %v", err)
+		}
+		wl.mix = mix
+	}
+	chooserFor, err := newChooser(wl.distribution, *records)
+	if err != nil {
+		log.Fatalf("Invalid -distribution: %v", err)
+	}
+	if *valueMax == 0 {
+		*valueMax = *valueMin
+	}
+	switch {
+	case *records == 0:
+		log.Fatal("-records must be positive")
+	case *concurrency < 1:
+		log.Fatal("-concurrency must be positive")
+	case *valueMin < 0 || *valueMax < *valueMin:
+		log.Fatal("-value-size must not be negative, nor exceed -value-size-max")
+	case *maxScan == 0:
+		log.Fatal("-max-scan must be positive")
+	case *output != "text" && *output != "json":
+		log.Fatalf("Unknown output format %q: use text or json", *output)
+	}
+	if *seed == 0 {
+		*seed = uint64(time.Now().UnixNano())
+	}
+
+	// Retries would hide failures in the latencies
+	opts := client.Options{
+		Token:    *token,
+		PoolSize: *conns,
+		Retry:    client.RetryPolicy{MaxAttempts: 1},
+	}
+	target := *serverAddr
+	var kv client.KV
+	if *inProcess {
+		srv, err := embedded.NewServer(embedded.Options{Dir: *dbPath, Backend: *backend})
+		if err != nil {
+			log.Fatalf("Failed to start in-process server: %v", err)
+		}
+		defer srv.Close()
+		if kv, err = srv.Client(opts); err != nil {
+			log.Fatalf("Failed to connect: %v", err)
+		}
+		target = "in-process " + *backend + " server"
+	} else {
+		if *tlsCA != "" || *tlsCert != "" || *tlsKey != "" {
+			if opts.TLS, err = tlsutil.ClientConfig(*tlsCA, *tlsCert, *tlsKey); err != nil {
+				log.Fatalf("Failed to configure TLS: %v", err)
+			}
+		}
+		if kv, err = client.New(*serverAddr, opts); err != nil {
+			log.Fatalf("Failed to connect: %v", err)
+		}
+	}
+	defer kv.Close()
+
+	// Interrupting stops the current phase and still reports
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	r := &report{
+		Workload:     strings.ToUpper(*workloadName),
+		Target:       target,
+		Database:     *dbName,
+		Distribution: wl.distribution,
+		Mix:          wl.mix,
+		Records:      *records,
+		ValueSizeMin: *valueMin,
+		ValueSizeMax: *valueMax,
+		MaxScan:      *maxScan,
+		Concurrency:  *concurrency,
+		Seed:         *seed,
+		Started:      time.Now().UTC(),
+	}
+	b := &bench{
+		kv:       kv,
+		database: *dbName,
+		value:    valueSize{min: *valueMin, max: *valueMax},
+		maxScan:  *maxScan,
+	}
+
+	if *load {
+		b.next.Store(0)
+		b.inserted.set(0)
+		loadWorkload := workload{mix: map[string]float64{opInsert: 1}, distribution: distUniform}
+		r.Phases = append(r.Phases, b.runPhase(ctx, "load", loadWorkload, chooserFor, *concurrency, *seed, *records, *progress))
+	} else {
+		b.next.Store(*records)
+		b.inserted.set(*records)
+	}
+
+	if ctx.Err() == nil {
+		runCtx, cancel := context.WithTimeout(ctx, *duration)
+		r.Phases = append(r.Phases, b.runPhase(runCtx, "run", wl, chooserFor, *concurrency, *seed+1, *opLimit, *progress))
+		cancel()
+	}
+
+	if *output == "json" {
+		if err := r.writeJSON(os.Stdout); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		return
+	}
+	r.writeText(os.Stdout)
+}
+
+// runPhase runs operations of wl with concurrency workers until ctx is done
+// or limit operations were started, if limit is set
+func (b *bench) runPhase(ctx context.Context, name string, wl workload, newChooser func() chooser, concurrency int, seed, limit uint64, progress time.Duration) phaseResult {
+	var started, completed atomic.Uint64
+	workers := make([]*worker, concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range workers {
+		w := newWorker(b, wl, newChooser, seed, i)
+		workers[i] = w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil && (limit == 0 || started.Add(1) <= limit) {
+				w.run(ctx, w.pick())
+				completed.Add(1)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	if progress > 0 {
+		go reportProgress(name, start, progress, &completed, done)
+	}
+	wg.Wait()
+	close(done)
+	return newPhaseResult(name, time.Since(start), workers)
+}
+
+// reportProgress writes the throughput of each interval to stderr until
+// done is closed
+func reportProgress(name string, start time.Time, interval time.Duration, completed *atomic.Uint64, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last uint64
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			n := completed.Load()
+			fmt.Fprintf(os.Stderr, "%s: %s, %d operations, %.0f ops/s\n",
+				name, now.Sub(start).Round(time.Second), n, float64(n-last)/interval.Seconds())
+			last = n
+		}
+	}
+}
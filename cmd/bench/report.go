@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// report is the result of a benchmark run. Its JSON form is meant to be
+// kept and compared between runs.
+type report struct {
+	Workload     string             `json:"workload"`
+	Target       string             `json:"target"`
+	Database     string             `json:"database"`
+	Distribution string             `json:"distribution"`
+	Mix          map[string]float64 `json:"mix"`
+	Records      uint64             `json:"records"`
+	ValueSizeMin int                `json:"value_size_min"`
+	ValueSizeMax int                `json:"value_size_max"`
+	MaxScan      uint64             `json:"max_scan"`
+	Concurrency  int                `json:"concurrency"`
+	Seed         uint64             `json:"seed"`
+	Started      time.Time          `json:"started"`
+	Phases       []phaseResult      `json:"phases"`
+}
+
+// phaseResult is the result of the load or run phase
+type phaseResult struct {
+	Name       string  `json:"name"`
+	Elapsed    float64 `json:"elapsed_s"`
+	Operations uint64  `json:"operations"`
+	Throughput float64 `json:"throughput"`
+	// Ops holds the results per operation, in the order of operations
+	Ops []opResult `json:"ops"`
+}
+
+type opResult struct {
+	Operation string `json:"operation"`
+	// Count is the number of operations that succeeded, including reads
+	// of missing records
+	Count      uint64  `json:"count"`
+	NotFound   uint64  `json:"not_found"`
+	Errors     uint64  `json:"errors"`
+	Throughput float64 `json:"throughput"`
+	// Latency is in microseconds, of successful operations only
+	Latency    latencyResult `json:"latency_us"`
+	FirstError string        `json:"first_error,omitempty"`
+}
+
+type latencyResult struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	P50  float64 `json:"p50"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+}
+
+func micros(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+// newPhaseResult merges the statistics of the workers of a phase
+func newPhaseResult(name string, elapsed time.Duration, workers []*worker) phaseResult {
+	result := phaseResult{Name: name, Elapsed: elapsed.Seconds()}
+	for _, op := range operations {
+		var merged opStats
+		seen := false
+		for _, w := range workers {
+			s := w.stats[op]
+			if s == nil {
+				continue
+			}
+			seen = true
+			merged.latency.merge(&s.latency)
+			merged.errors += s.errors
+			merged.notFound += s.notFound
+			if merged.firstError == nil {
+				merged.firstError = s.firstError
+			}
+		}
+		if !seen {
+			continue
+		}
+
+		h := &merged.latency
+		r := opResult{
+			Operation:  op,
+			Count:      h.count,
+			NotFound:   merged.notFound,
+			Errors:     merged.errors,
+			Throughput: float64(h.count) / elapsed.Seconds(),
+			Latency: latencyResult{
+				Mean: micros(h.mean()),
+				Min:  micros(h.min),
+				P50:  micros(h.percentile(0.5)),
+				P99:  micros(h.percentile(0.99)),
+				P999: micros(h.percentile(0.999)),
+				Max:  micros(h.max),
+			},
+		}
+		if merged.firstError != nil {
+			r.FirstError = merged.firstError.Error()
+		}
+		result.Ops = append(result.Ops, r)
+		result.Operations += r.Count
+	}
+	result.Throughput = float64(result.Operations) / elapsed.Seconds()
+	return result
+}
+
+func (r *report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *report) writeText(w io.Writer) {
+	valueSize := fmt.Sprint(r.ValueSizeMin)
+	if r.ValueSizeMax > r.ValueSizeMin {
+		valueSize += "-" + fmt.Sprint(r.ValueSizeMax)
+	}
+	fmt.Fprintf(w, "Workload %s (%s, %s) on %s, database %s: %d records, %s-byte values, %d workers\n",
+		r.Workload, formatMix(r.Mix), r.Distribution, r.Target, r.Database, r.Records, valueSize, r.Concurrency)
+
+	for _, phase := range r.Phases {
+		fmt.Fprintf(w, "\n%s: %d operations in %s, %.0f ops/s\n",
+			phase.Name, phase.Operations, time.Duration(phase.Elapsed*float64(time.Second)).Round(time.Millisecond), phase.Throughput)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "OPERATION\tCOUNT\tNOT FOUND\tERRORS\tOPS/S\tMEAN\tP50\tP99\tP999\tMAX\t")
+		for _, op := range phase.Ops {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.0f\t%s\t%s\t%s\t%s\t%s\t\n",
+				op.Operation, op.Count, op.NotFound, op.Errors, op.Throughput,
+				formatMicros(op.Latency.Mean), formatMicros(op.Latency.P50), formatMicros(op.Latency.P99),
+				formatMicros(op.Latency.P999), formatMicros(op.Latency.Max))
+		}
+		tw.Flush()
+		for _, op := range phase.Ops {
+			if op.FirstError != "" {
+				fmt.Fprintf(w, "%s errors, the first: %s\n", op.Operation, strings.TrimSpace(op.FirstError))
+			}
+		}
+	}
+}
+
+// formatMicros formats a latency in microseconds as a duration
+func formatMicros(us float64) string {
+	d := time.Duration(us * float64(time.Microsecond))
+	if d >= time.Millisecond {
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rocksdb-service/api/client"
+)
+
+// Operations of a workload
+const (
+	opRead   = "read"
+	opUpdate = "update"
+	opInsert = "insert"
+	opScan   = "scan"
+	opRMW    = "read-modify-write"
+)
+
+// operations lists the operations in the order they are reported
+var operations = []string{opRead, opUpdate, opInsert, opScan, opRMW}
+
+// workload is the mix of operations run against the database and how they
+// pick records
+type workload struct {
+	// mix holds the proportion of each operation, summing to 1
+	mix          map[string]float64
+	distribution string
+}
+
+// workloads are the core YCSB workloads
+var workloads = map[string]workload{
+	// Update heavy, like a session store recording recent actions
+	"a": {mix: map[string]float64{opRead: 0.5, opUpdate: 0.5}, distribution: distZipfian},
+	// Read mostly, like photo tagging
+	"b": {mix: map[string]float64{opRead: 0.95, opUpdate: 0.05}, distribution: distZipfian},
+	// Read only, like a user profile cache
+	"c": {mix: map[string]float64{opRead: 1}, distribution: distZipfian},
+	// Read latest, like user status updates
+	"d": {mix: map[string]float64{opRead: 0.95, opInsert: 0.05}, distribution: distLatest},
+	// Short ranges, like threaded conversations
+	"e": {mix: map[string]float64{opScan: 0.95, opInsert: 0.05}, distribution: distZipfian},
+	// Read-modify-write, like a user database
+	"f": {mix: map[string]float64{opRead: 0.5, opRMW: 0.5}, distribution: distZipfian},
+}
+
+// parseMix parses proportions such as "read=0.9,update=0.1", normalizing
+// them to sum to 1
+func parseMix(s string) (map[string]float64, error) {
+	mix := make(map[string]float64)
+	var total float64
+	for _, part := range strings.Split(s, ",") {
+		op, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		var p float64
+		if ok {
+			_, err := fmt.Sscanf(value, "%g", &p)
+			ok = err == nil && p >= 0
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid proportion %q: use operation=proportion", part)
+		}
+		if !slices.Contains(operations, op) {
+			return nil, fmt.Errorf("unknown operation %q: use %s", op, strings.Join(operations, ", "))
+		}
+		mix[op] += p
+		total += p
+	}
+	if total == 0 {
+		return nil, errors.New("the proportions must not all be 0")
+	}
+	for op := range mix {
+		mix[op] /= total
+	}
+	return mix, nil
+}
+
+// formatMix formats proportions like parseMix accepts them
+func formatMix(mix map[string]float64) string {
+	var parts []string
+	for _, op := range operations {
+		if p := mix[op]; p > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%g", op, p))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// bench holds the state shared by the workers of a phase
+type bench struct {
+	kv       client.KV
+	database string
+	value    valueSize
+	maxScan  uint64
+
+	// next is the number of the next record to insert
+	next     atomic.Uint64
+	inserted acknowledged
+}
+
+// acknowledged tracks the records whose insert has completed. Reads only
+// pick records below the first one still in flight, as YCSB does.
+type acknowledged struct {
+	mu    sync.Mutex
+	done  map[uint64]bool
+	limit atomic.Uint64
+}
+
+// load returns the number of records before the first one in flight
+func (a *acknowledged) load() uint64 {
+	return a.limit.Load()
+}
+
+// set marks the first n records as inserted
+func (a *acknowledged) set(n uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.done = make(map[uint64]bool)
+	a.limit.Store(n)
+}
+
+// ack marks record n as inserted
+func (a *acknowledged) ack(n uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	limit := a.limit.Load()
+	a.done[n] = true
+	for a.done[limit] {
+		delete(a.done, limit)
+		limit++
+	}
+	a.limit.Store(limit)
+}
+
+// valueSize is the size of written values, uniform between min and max
+type valueSize struct {
+	min, max int
+}
+
+// worker runs operations of one goroutine. It is not safe for concurrent
+// use.
+type worker struct {
+	b       *bench
+	rand    *rand.Rand
+	chooser chooser
+	ops     []string
+	cumul   []float64
+	buf     []byte
+	stats   map[string]*opStats
+}
+
+// opStats accumulates the results of one operation
+type opStats struct {
+	latency  histogram
+	errors   uint64
+	notFound uint64
+	// firstError is kept to explain failures in the report
+	firstError error
+}
+
+func newWorker(b *bench, w workload, newChooser func() chooser, seed uint64, id int) *worker {
+	wk := &worker{
+		b:       b,
+		rand:    rand.New(rand.NewPCG(seed, uint64(id))),
+		chooser: newChooser(),
+		buf:     make([]byte, b.value.max),
+		stats:   make(map[string]*opStats),
+	}
+	var sum float64
+	for _, op := range operations {
+		if p := w.mix[op]; p > 0 {
+			sum += p
+			wk.ops = append(wk.ops, op)
+			wk.cumul = append(wk.cumul, sum)
+		}
+	}
+	return wk
+}
+
+// pick returns the next operation of the mix
+func (w *worker) pick() string {
+	u := w.rand.Float64()
+	for i, c := range w.cumul {
+		if u < c {
+			return w.ops[i]
+		}
+	}
+	return w.ops[len(w.ops)-1]
+}
+
+// randomValue returns a value of random bytes and size. The buffer is
+// reused, which the clients allow as they do not retain values.
+func (w *worker) randomValue() []byte {
+	size := w.b.value.min
+	if w.b.value.max > w.b.value.min {
+		size += w.rand.IntN(w.b.value.max - w.b.value.min + 1)
+	}
+	v := w.buf[:size]
+	for i := 0; i < len(v); i += 8 {
+		x := w.rand.Uint64()
+		for j := i; j < min(i+8, len(v)); j++ {
+			v[j] = byte(x)
+			x >>= 8
+		}
+	}
+	return v
+}
+
+// chooseKey returns the key of an existing record picked by the workload's
+// distribution
+func (w *worker) chooseKey() string {
+	return recordKey(w.chooser.next(w.rand, max(w.b.inserted.load(), 1)))
+}
+
+// run runs op once and records its latency or failure
+func (w *worker) run(ctx context.Context, op string) {
+	start := time.Now()
+	var err error
+	switch op {
+	case opRead:
+		_, err = w.b.kv.Get(ctx, w.b.database, w.chooseKey())
+	case opUpdate:
+		_, err = w.b.kv.Put(ctx, w.b.database, w.chooseKey(), w.randomValue())
+	case opInsert:
+		err = w.insert(ctx)
+	case opScan:
+		limit := 1 + w.rand.Uint64N(w.b.maxScan)
+		for _, scanErr := range w.b.kv.Scan(ctx, w.b.database, client.ScanOptions{Start: w.chooseKey(), Limit: limit}) {
+			if scanErr != nil {
+				err = scanErr
+			}
+		}
+	case opRMW:
+		key := w.chooseKey()
+		if _, err = w.b.kv.Get(ctx, w.b.database, key); err == nil || errors.Is(err, client.ErrNotFound) {
+			_, err = w.b.kv.Put(ctx, w.b.database, key, w.randomValue())
+		}
+	}
+	elapsed := time.Since(start)
+
+	if ctx.Err() != nil {
+		// Cut short by the end of the run
+		return
+	}
+	s := w.stats[op]
+	if s == nil {
+		s = &opStats{}
+		w.stats[op] = s
+	}
+	switch {
+	case err == nil:
+		s.latency.record(elapsed)
+	case errors.Is(err, client.ErrNotFound):
+		// Reads of records that failed to insert, or that were never
+		// loaded
+		s.notFound++
+		s.latency.record(elapsed)
+	default:
+		s.errors++
+		if s.firstError == nil {
+			s.firstError = err
+		}
+	}
+}
+
+// insert writes the next record. A failed insert is acknowledged too, so
+// later records become readable; reads of it count as not found.
+func (w *worker) insert(ctx context.Context) error {
+	n := w.b.next.Add(1) - 1
+	_, err := w.b.kv.Put(ctx, w.b.database, recordKey(n), w.randomValue())
+	w.b.inserted.ack(n)
+	return err
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"rocksdb-service/internal/auth"
+	"rocksdb-service/internal/config"
+	"rocksdb-service/internal/db"
+	"rocksdb-service/internal/quota"
+)
+
+// loadConfig reads the configuration file at path, or the defaults if path
+// is empty, applies overrides and validates the result
+func loadConfig(path string, overrides func(*config.Config)) (*config.Config, error) {
+	cfg := config.Default()
+	if path != "" {
+		var err error
+		if cfg, err = config.Load(path); err != nil {
+			return nil, err
+		}
+	}
+	overrides(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// splitList splits a comma-separated flag value
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// dbOptions converts the per-database configuration
+func dbOptions(cfg *config.Config) map[string]db.DBOptions {
+	databases := make(map[string]db.DBOptions, len(cfg.Databases))
+	for name, d := range cfg.Databases {
+		databases[name] = db.DBOptions{
+			HistoryRetention: time.Duration(d.HistoryRetention),
+			Encrypt:          d.Encrypt,
+			RocksDB:          d.RocksDB,
+		}
+	}
+	return databases
+}
+
+// listen listens on a "host:port" or "unix:///path" address
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix://")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	// Remove the socket left behind by a previous run
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// reloader applies the settings of a changed configuration that can change
+// at runtime: the log level, ACLs, quotas, the block cache size and mutable
+// RocksDB options
+type reloader struct {
+	path      string
+	overrides func(*config.Config)
+	current   *config.Config
+
+	level     slog.LevelVar
+	dbManager *db.DBManager
+	acl       *auth.ACL
+	quotas    *quota.Manager
+}
+
+// reload reloads the configuration. An invalid configuration is logged and
+// the current one kept.
+func (r *reloader) reload() {
+	cfg, err := loadConfig(r.path, r.overrides)
+	if err != nil {
+		log.Printf("Failed to reload configuration, keeping the current one: %v", err)
+		return
+	}
+
+	level, _ := config.ParseLevel(cfg.Logging.Level)
+	r.level.Set(level)
+
+	if r.acl != nil {
+		if cfg.Auth.ACLFile != "" {
+			err = r.acl.Reload()
+		} else {
+			err = r.acl.SetRules(cfg.Auth.ACL)
+		}
+		if err != nil {
+			log.Printf("Failed to reload ACL: %v", err)
+		}
+	}
+
+	if r.quotas != nil {
+		if cfg.Quotas.File != "" {
+			err = r.quotas.Reload()
+		} else {
+			r.quotas.SetConfig(cfg.Quotas.Config())
+		}
+		if err != nil {
+			log.Printf("Failed to reload quotas: %v", err)
+		}
+	}
+
+	if cfg.Memory.BlockCache != r.current.Memory.BlockCache {
+		if err := r.dbManager.SetBlockCacheSize(uint64(cfg.Memory.BlockCache)); err != nil {
+			log.Printf("Failed to resize block cache: %v", err)
+		}
+	}
+
+	options := make(map[string]map[string]string, len(cfg.Databases))
+	for name, d := range cfg.Databases {
+		options[name] = d.RocksDB
+	}
+	if err := r.dbManager.SetRocksDBOptions(options); err != nil {
+		log.Printf("Failed to apply RocksDB options: %v", err)
+	}
+
+	if changed := restartRequired(r.current, cfg); len(changed) > 0 {
+		log.Printf("Changes to %s take effect after a restart", strings.Join(changed, ", "))
+	}
+	r.current = cfg
+	log.Println("Configuration reloaded")
+}
+
+// restartRequired returns the top-level settings that differ between two
+// configurations in ways that cannot be applied at runtime
+func restartRequired(old, cfg *config.Config) []string {
+	fixed := func(c *config.Config) map[string]json.RawMessage {
+		c2 := *c
+		c2.Logging.Level = ""
+		c2.Memory.BlockCache = 0
+		c2.Databases = make(map[string]config.Database, len(c.Databases))
+		for name, d := range c.Databases {
+			d.RocksDB = nil
+			c2.Databases[name] = d
+		}
+		// Rules and limits can change, but not whether they are enabled or
+		// where they are read from
+		c2.Auth.ACL = nil
+		c2.Quotas.Principals, c2.Quotas.Databases = nil, nil
+
+		var fields map[string]json.RawMessage
+		data, _ := json.Marshal(c2)
+		json.Unmarshal(data, &fields)
+		return fields
+	}
+
+	a, b := fixed(old), fixed(cfg)
+	var changed []string
+	for _, name := range []string{"listen", "data_dir", "backend", "alias_retention", "health_check_interval", "stream_write", "tls", "auth", "quotas", "encryption", "memory", "databases", "logging", "tracing", "resp", "http"} {
+		if string(a[name]) != string(b[name]) {
+			changed = append(changed, name)
+		}
+	}
+	if (old.Auth.ACL == nil) != (cfg.Auth.ACL == nil) {
+		changed = append(changed, "auth.acl")
+	}
+	if old.Quotas.Enabled() != cfg.Quotas.Enabled() && !slices.Contains(changed, "quotas") {
+		changed = append(changed, "quotas")
+	}
+	return changed
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+)
+
+// setServing sets the overall status and that of the RocksDB service
+func setServing(hs *health.Server, status healthpb.HealthCheckResponse_ServingStatus) {
+	hs.SetServingStatus("", status)
+	hs.SetServingStatus(pb.RocksDBService_ServiceDesc.ServiceName, status)
+}
+
+// watchHealth opens the configured databases, reports SERVING once they are
+// open, and then reports NOT_SERVING while any open database has hit a
+// background error. It returns when ctx is done.
+func watchHealth(ctx context.Context, hs *health.Server, dbManager *db.DBManager, interval time.Duration) {
+	start := time.Now()
+	if err := dbManager.Preload(ctx); err != nil {
+		log.Printf("Failed to open configured databases, not serving: %v", err)
+		return
+	}
+	log.Printf("Opened configured databases in %v", time.Since(start))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var failing []string
+	for {
+		next := dbManager.Failing()
+		if len(next) > 0 {
+			setServing(hs, healthpb.HealthCheckResponse_NOT_SERVING)
+		} else {
+			setServing(hs, healthpb.HealthCheckResponse_SERVING)
+		}
+		if strings.Join(next, ",") != strings.Join(failing, ",") {
+			if len(next) > 0 {
+				log.Printf("Databases with background errors, not serving: %s", strings.Join(next, ", "))
+			} else if len(failing) > 0 {
+				log.Println("No databases with background errors, serving")
+			}
+			failing = next
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
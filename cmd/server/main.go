@@ -2,131 +2,480 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
+	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/accesslog"
+	"rocksdb-service/internal/auth"
+	"rocksdb-service/internal/config"
+	"rocksdb-service/internal/console"
+	"rocksdb-service/internal/cors"
 	"rocksdb-service/internal/db"
+	"rocksdb-service/internal/gateway"
+	"rocksdb-service/internal/grpcweb"
+	"rocksdb-service/internal/quota"
+	"rocksdb-service/internal/resp"
+	"rocksdb-service/internal/server"
+	"rocksdb-service/internal/tlsutil"
+	"rocksdb-service/internal/tracing"
 )
 
-type server struct {
-	pb.UnimplementedRocksDBServiceServer
-	dbManager *db.DBManager
-}
+const (
+	// httpReadHeaderTimeout bounds the time to read HTTP request headers
+	httpReadHeaderTimeout = 10 * time.Second
+	// httpShutdownTimeout bounds the wait for HTTP requests on shutdown
+	httpShutdownTimeout = 10 * time.Second
+)
 
-func (s *server) Put(ctx context.Context, req *pb.PutRequest) (*pb.PutResponse, error) {
-	database, err := s.dbManager.GetDB(req.DatabaseName)
-	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+func main() {
+	def := config.Default()
+	var (
+		cfgFile = flag.String("config", "", "YAML, TOML or JSON configuration file; flags given on the command line override it")
+		port    = flag.Int("port", 50051, "The server port")
+		dbPath  = flag.String("db-path", def.DataDir, "Path to RocksDB data directory")
+		backend = flag.String("backend", def.Backend, "Storage backend: \"rocksdb\", or \"memory\" to keep databases in process until exit")
+		retain  = flag.Int("alias-retain", def.AliasRetention, "Number of previous alias targets kept for rollback")
+		wbSize  = flag.Int("write-batch-size", def.StreamWrite.BatchSize, "Maximum number of mutations per StreamWrite batch")
+		wbWait  = flag.Duration("write-flush-interval", time.Duration(def.StreamWrite.FlushInterval), "Interval at which partial StreamWrite batches are committed")
+		histDB  = flag.String("history-dbs", "", "Comma-separated databases keeping previous values for time-travel reads")
+		histTT  = flag.Duration("history-retention", 7*24*time.Hour, "How long previous values are kept in history mode")
+		tlsCrt  = flag.String("tls-cert", "", "TLS certificate file; enables TLS together with -tls-key")
+		tlsKey  = flag.String("tls-key", "", "TLS private key file")
+		tlsCA   = flag.String("tls-client-ca", "", "CA bundle verifying client certificates; enables mutual TLS")
+		tokens  = flag.String("auth-tokens", "", "JSON file mapping bearer tokens to principals; enables authentication")
+		jwtKey  = flag.String("auth-jwt-secret", "", "File holding the HMAC secret of HS256 bearer tokens; enables authentication")
+		aclFile = flag.String("acl", "", "JSON file granting principals access to databases; requires authentication")
+		quotas  = flag.String("quotas", "", "JSON file with per-principal and per-database limits; enables quotas")
+		keyFile = flag.String("encryption-keys", "", "JSON file with base64 AES keys decrypting stored values")
+		encDB   = flag.String("encrypt-dbs", "", "Comma-separated databases encrypting new values; requires -encryption-keys")
+		traceTo = flag.String("trace-output", "", "Write OpenTelemetry spans as JSON to \"stdout\" or this file; enables tracing")
+		traceR  = flag.Float64("trace-sample-ratio", def.Tracing.SampleRatio, "Fraction of new traces sampled; traces sampled by the caller are always kept")
+		access  = flag.String("access-log", "", "Write a JSON line per request to \"stdout\", \"stderr\" or this file")
+		redact  = flag.Bool("access-log-redact-keys", false, "Log hashes instead of keys and prefixes")
+		slowLog = flag.String("slow-log", "", "Write a JSON line with RocksDB perf counters per slow request to \"stdout\", \"stderr\" or this file")
+		slowMin = flag.Duration("slow-log-threshold", time.Duration(def.Logging.SlowThreshold), "Minimum latency of requests written to -slow-log")
+		bgCheck = flag.Duration("health-check-interval", time.Duration(def.HealthCheckInterval), "Interval at which open databases are checked for background errors")
+		respAt  = flag.String("resp-listen", "", "Address serving the Redis protocol, e.g. \":6379\"; disabled if empty")
+		respDBs = flag.String("resp-databases", "", "Comma-separated databases selected by the Redis SELECT indexes 0, 1, ...")
+		httpAt  = flag.String("http-listen", "", "Address serving the REST API, e.g. \":8080\"; disabled if empty")
+		grpcWeb = flag.Bool("grpc-web", false, "Serve gRPC-Web on -http-listen for browsers")
+		httpUI  = flag.Bool("http-ui", false, "Serve the admin console at /ui/ on -http-listen")
+		origins = flag.String("cors-origins", "", "Comma-separated origins allowed to call -http-listen from browsers, * for any")
+	)
+	flag.Parse()
+
+	if *histDB != "" && *histTT <= 0 {
+		log.Fatal("Invalid -history-retention: must be positive")
 	}
 
-	err = database.Put(req.Key, req.Value)
-	if err != nil {
-		return &pb.PutResponse{Success: false, Error: err.Error()}, nil
+	// overrides applies the flags given on the command line over the
+	// configuration file
+	overrides := func(cfg *config.Config) {
+		database := func(name string, set func(d *config.Database)) {
+			if cfg.Databases == nil {
+				cfg.Databases = make(map[string]config.Database)
+			}
+			d := cfg.Databases[name]
+			set(&d)
+			cfg.Databases[name] = d
+		}
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "port":
+				cfg.Listen = []string{fmt.Sprintf(":%d", *port)}
+			case "db-path":
+				cfg.DataDir = *dbPath
+			case "backend":
+				cfg.Backend = *backend
+			case "alias-retain":
+				cfg.AliasRetention = *retain
+			case "write-batch-size":
+				cfg.StreamWrite.BatchSize = *wbSize
+			case "write-flush-interval":
+				cfg.StreamWrite.FlushInterval = config.Duration(*wbWait)
+			case "history-dbs":
+				for _, name := range splitList(*histDB) {
+					database(name, func(d *config.Database) { d.HistoryRetention = config.Duration(*histTT) })
+				}
+			case "tls-cert":
+				cfg.TLS.Cert = *tlsCrt
+			case "tls-key":
+				cfg.TLS.Key = *tlsKey
+			case "tls-client-ca":
+				cfg.TLS.ClientCA = *tlsCA
+			case "auth-tokens":
+				cfg.Auth.TokensFile = *tokens
+			case "auth-jwt-secret":
+				cfg.Auth.JWTSecretFile = *jwtKey
+			case "acl":
+				cfg.Auth.ACLFile, cfg.Auth.ACL = *aclFile, nil
+			case "quotas":
+				cfg.Quotas = config.Quotas{File: *quotas}
+			case "encryption-keys":
+				cfg.Encryption.KeysFile = *keyFile
+			case "encrypt-dbs":
+				for _, name := range splitList(*encDB) {
+					database(name, func(d *config.Database) { d.Encrypt = true })
+				}
+			case "trace-output":
+				cfg.Tracing.Output = *traceTo
+			case "trace-sample-ratio":
+				cfg.Tracing.SampleRatio = *traceR
+			case "access-log":
+				cfg.Logging.AccessLog = *access
+			case "access-log-redact-keys":
+				cfg.Logging.RedactKeys = *redact
+			case "slow-log":
+				cfg.Logging.SlowLog = *slowLog
+			case "slow-log-threshold":
+				cfg.Logging.SlowThreshold = config.Duration(*slowMin)
+			case "health-check-interval":
+				cfg.HealthCheckInterval = config.Duration(*bgCheck)
+			case "resp-listen":
+				cfg.RESP.Listen = *respAt
+			case "resp-databases":
+				cfg.RESP.Databases = splitList(*respDBs)
+			case "http-listen":
+				cfg.HTTP.Listen = *httpAt
+			case "grpc-web":
+				cfg.HTTP.GRPCWeb = *grpcWeb
+			case "http-ui":
+				cfg.HTTP.UI = *httpUI
+			case "cors-origins":
+				cfg.HTTP.CORSOrigins = splitList(*origins)
+			}
+		})
 	}
-	return &pb.PutResponse{Success: true}, nil
-}
 
-func (s *server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
-	database, err := s.dbManager.GetDB(req.DatabaseName)
+	cfg, err := loadConfig(*cfgFile, overrides)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+		log.Fatal(err)
 	}
 
-	value, exists, err := database.Get(req.Key)
-	if err != nil {
-		return &pb.GetResponse{Found: false, Error: err.Error()}, nil
+	if cfg.Tracing.Output != "" {
+		shutdown, err := tracing.Setup(cfg.Tracing.Output, cfg.Tracing.SampleRatio)
+		if err != nil {
+			log.Fatalf("Failed to set up tracing: %v", err)
+		}
+		defer func() {
+			if err := shutdown(context.Background()); err != nil {
+				log.Printf("Failed to flush traces: %v", err)
+			}
+		}()
 	}
-	return &pb.GetResponse{Value: value, Found: exists}, nil
-}
 
-func (s *server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
-	database, err := s.dbManager.GetDB(req.DatabaseName)
-	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	var keys db.KeyProvider
+	if cfg.Encryption.KeysFile != "" {
+		kf, err := db.LoadKeyFile(cfg.Encryption.KeysFile)
+		if err != nil {
+			log.Fatalf("Failed to load encryption keys: %v", err)
+		}
+		keys = kf
 	}
 
-	err = database.Delete(req.Key)
+	// Initialize DBManager
+	dbManager, err := db.NewDBManager(cfg.DataDir, db.Options{
+		Backend:         cfg.Backend,
+		AliasRetention:  cfg.AliasRetention,
+		Databases:       dbOptions(cfg),
+		Keys:            keys,
+		BlockCacheSize:  uint64(cfg.Memory.BlockCache),
+		WriteBufferSize: uint64(cfg.Memory.WriteBuffers),
+	})
 	if err != nil {
-		return &pb.DeleteResponse{Success: false, Error: err.Error()}, nil
+		log.Fatalf("Failed to initialize database manager: %v", err)
 	}
-	return &pb.DeleteResponse{Success: true}, nil
-}
+	defer dbManager.Close()
 
-func (s *server) StreamGet(req *pb.StreamGetRequest, stream pb.RocksDBService_StreamGetServer) error {
-	database, err := s.dbManager.GetDB(req.DatabaseName)
-	if err != nil {
-		return status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	// Initialize gRPC server
+	listeners := make([]net.Listener, len(cfg.Listen))
+	for i, addr := range cfg.Listen {
+		if listeners[i], err = listen(addr); err != nil {
+			log.Fatalf("Failed to listen: %v", err)
+		}
 	}
 
-	var ch chan db.KeyValuePair
+	// The stats handler starts a span per RPC, continuing traces from the
+	// traceparent metadata of callers. Without tracing spans are no-ops.
+	opts := []grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}
+	var (
+		creds     []grpc.ServerOption
+		tlsConfig *tls.Config
+	)
+	if cfg.TLS.Cert != "" {
+		tlsConfig, err = tlsutil.ServerConfig(cfg.TLS.Cert, cfg.TLS.Key, cfg.TLS.ClientCA)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		creds = append(creds, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
 
-	switch query := req.Query.(type) {
-	case *pb.StreamGetRequest_Prefix:
-		ch = database.GetByPrefix(query.Prefix)
-	case *pb.StreamGetRequest_Keys:
-		ch = database.GetMultiple(query.Keys.Keys)
-	default:
-		return fmt.Errorf("invalid query type")
+	r := &reloader{
+		path:      *cfgFile,
+		overrides: overrides,
+		current:   cfg,
+		dbManager: dbManager,
 	}
+	level, _ := config.ParseLevel(cfg.Logging.Level)
+	r.level.Set(level)
 
-	for pair := range ch {
-		if pair.Err != nil {
-			return status.Errorf(codes.Internal, "stream error: %v", pair.Err)
+	// The access log runs first so requests rejected by authentication or
+	// quotas are logged too
+	if cfg.Logging.AccessLog != "" || cfg.Logging.SlowLog != "" {
+		logOpts := accesslog.Options{
+			SlowThreshold: time.Duration(cfg.Logging.SlowThreshold),
+			RedactKeys:    cfg.Logging.RedactKeys,
+			Level:         &r.level,
+		}
+		if cfg.Logging.AccessLog != "" {
+			if logOpts.Access, err = accesslog.Open(cfg.Logging.AccessLog); err != nil {
+				log.Fatalf("Failed to open access log: %v", err)
+			}
+		}
+		if cfg.Logging.SlowLog != "" {
+			if logOpts.Slow, err = accesslog.Open(cfg.Logging.SlowLog); err != nil {
+				log.Fatalf("Failed to open slow log: %v", err)
+			}
 		}
+		logger := accesslog.New(logOpts)
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(logger.Unary()),
+			grpc.ChainStreamInterceptor(logger.Stream()),
+		)
+	}
 
-		err := stream.Send(&pb.StreamGetResponse{
-			Key:   pair.Key,
-			Value: pair.Value,
-		})
+	// Calls the server makes on its own behalf skip authentication and
+	// quotas
+	trustedOpts := slices.Clone(opts)
+
+	var (
+		verifiers    []auth.TokenVerifier
+		authenticate func(token string) error
+	)
+	if cfg.Auth.TokensFile != "" {
+		v, err := auth.LoadStaticTokens(cfg.Auth.TokensFile)
 		if err != nil {
-			return status.Errorf(codes.Internal, "failed to send response: %v", err)
+			log.Fatalf("Failed to load tokens: %v", err)
+		}
+		verifiers = append(verifiers, v)
+	}
+	if cfg.Auth.JWTSecretFile != "" {
+		v, err := auth.LoadJWTSecret(cfg.Auth.JWTSecretFile)
+		if err != nil {
+			log.Fatalf("Failed to load JWT secret: %v", err)
+		}
+		verifiers = append(verifiers, v)
+	}
+	if len(verifiers) > 0 {
+		switch {
+		case cfg.Auth.ACLFile != "":
+			if r.acl, err = auth.LoadACL(cfg.Auth.ACLFile); err != nil {
+				log.Fatalf("Failed to load ACL: %v", err)
+			}
+		case cfg.Auth.ACL != nil:
+			if r.acl, err = auth.NewACL(cfg.Auth.ACL); err != nil {
+				log.Fatalf("Failed to load ACL: %v", err)
+			}
 		}
+		interceptor := auth.NewInterceptor(r.acl, verifiers...)
+		authenticate = func(token string) error {
+			_, err := interceptor.Verify(token)
+			return err
+		}
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(interceptor.Unary()),
+			grpc.ChainStreamInterceptor(interceptor.Stream()),
+		)
 	}
 
-	return nil
-}
+	var quotaManager *quota.Manager
+	if cfg.Quotas.File != "" {
+		if quotaManager, err = quota.Load(cfg.Quotas.File, server.StorageSize(dbManager)); err != nil {
+			log.Fatalf("Failed to load quotas: %v", err)
+		}
+	} else if cfg.Quotas.Enabled() {
+		quotaManager = quota.New(cfg.Quotas.Config(), server.StorageSize(dbManager))
+	}
+	if quotaManager != nil {
+		r.quotas = quotaManager
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(quotaManager.UnaryInterceptor()),
+			grpc.ChainStreamInterceptor(quotaManager.StreamInterceptor()),
+		)
+	}
+	srv := server.New(dbManager, server.Options{
+		WriteBatchSize:     cfg.StreamWrite.BatchSize,
+		WriteFlushInterval: time.Duration(cfg.StreamWrite.FlushInterval),
+		Quotas:             quotaManager,
+	})
 
-func main() {
+	s := grpc.NewServer(append(slices.Clone(opts), creds...)...)
+	pb.RegisterRocksDBServiceServer(s, srv)
+	reflection.Register(s)
+
+	// Front-ends for other protocols call an in-process server with the
+	// same interceptors, but without TLS. The client addresses they
+	// forward are trusted there.
+	forwarded := grpc.InTapHandle(accesslog.TrustClientAddress)
 	var (
-		port   = flag.Int("port", 50051, "The server port")
-		dbPath = flag.String("db-path", "/data/rocksdb", "Path to RocksDB data directory")
+		local       *grpc.Server
+		localClient pb.RocksDBServiceClient
 	)
-	flag.Parse()
+	if cfg.RESP.Listen != "" || cfg.HTTP.Listen != "" {
+		local = grpc.NewServer(append(opts, forwarded)...)
+		pb.RegisterRocksDBServiceServer(local, srv)
+		conn, err := serveLocal(local)
+		if err != nil {
+			log.Fatalf("Failed to connect to the local server: %v", err)
+		}
+		defer conn.Close()
+		localClient = pb.NewRocksDBServiceClient(conn)
+	}
 
-	// Initialize DBManager
-	dbManager := db.NewDBManager(*dbPath)
-	defer dbManager.Close()
+	var (
+		respLis    net.Listener
+		respServer *resp.Server
+		trusted    *grpc.Server
+	)
+	if cfg.RESP.Listen != "" {
+		if respLis, err = listen(cfg.RESP.Listen); err != nil {
+			log.Fatalf("Failed to listen: %v", err)
+		}
+		// Expired keys are deleted by the server rather than as any client
+		trusted = grpc.NewServer(append(trustedOpts, forwarded)...)
+		pb.RegisterRocksDBServiceServer(trusted, srv)
+		conn, err := serveLocal(trusted)
+		if err != nil {
+			log.Fatalf("Failed to connect to the local server: %v", err)
+		}
+		defer conn.Close()
+		respServer = resp.NewServer(localClient, resp.Options{
+			Databases:    cfg.RESP.Databases,
+			Trusted:      pb.NewRocksDBServiceClient(conn),
+			Authenticate: authenticate,
+		})
+	}
 
-	// Initialize gRPC server
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
-	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+	var (
+		httpLis    net.Listener
+		httpServer *http.Server
+	)
+	if cfg.HTTP.Listen != "" {
+		if httpLis, err = listen(cfg.HTTP.Listen); err != nil {
+			log.Fatalf("Failed to listen: %v", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/v1/", gateway.New(localClient))
+		if cfg.HTTP.GRPCWeb {
+			mux.Handle("/rocksdb.RocksDBService/", grpcweb.New(local))
+		}
+		if cfg.HTTP.UI {
+			mux.Handle("GET "+console.Prefix, console.New())
+			mux.Handle("GET /{$}", http.RedirectHandler(console.Prefix, http.StatusFound))
+		}
+		var handler http.Handler = mux
+		if len(cfg.HTTP.CORSOrigins) > 0 {
+			handler = cors.New(cfg.HTTP.CORSOrigins, mux)
+		}
+		httpServer = &http.Server{
+			Handler:           handler,
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: httpReadHeaderTimeout,
+		}
 	}
 
-	s := grpc.NewServer()
-	pb.RegisterRocksDBServiceServer(s, &server{dbManager: dbManager})
+	// Report NOT_SERVING until the configured databases are open
+	hs := health.NewServer()
+	setServing(hs, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	defer stopHealth()
+	go watchHealth(healthCtx, hs, dbManager, time.Duration(cfg.HealthCheckInterval))
 
-	// Handle graceful shutdown
+	// Handle graceful shutdown, and reload on SIGHUP
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 
-	go func() {
-		log.Printf("Server listening at %v", lis.Addr())
-		if err := s.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve: %v", err)
-		}
-	}()
+	for _, lis := range listeners {
+		go func() {
+			log.Printf("Server listening at %v", lis.Addr())
+			if err := s.Serve(lis); err != nil {
+				log.Fatalf("Failed to serve: %v", err)
+			}
+		}()
+	}
 
-	<-stop
+	if respServer != nil {
+		go func() {
+			log.Printf("RESP server listening at %v", respLis.Addr())
+			if err := respServer.Serve(respLis); err != nil {
+				log.Fatalf("Failed to serve RESP: %v", err)
+			}
+		}()
+	}
+
+	if httpServer != nil {
+		go func() {
+			log.Printf("HTTP server listening at %v", httpLis.Addr())
+			var err error
+			if httpServer.TLSConfig != nil {
+				err = httpServer.ServeTLS(httpLis, "", "")
+			} else {
+				err = httpServer.Serve(httpLis)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Failed to serve HTTP: %v", err)
+			}
+		}()
+	}
+
+	for running := true; running; {
+		select {
+		case <-hup:
+			r.reload()
+		case <-stop:
+			running = false
+		}
+	}
 	log.Println("Shutting down server...")
+	stopHealth()
+	hs.Shutdown()
+	if respServer != nil {
+		// Close first so that Serve sees the closed listener as a shutdown
+		respServer.Close()
+		respLis.Close()
+	}
+	if httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("Failed to shut down HTTP server: %v", err)
+		}
+		cancel()
+	}
 	s.GracefulStop()
+	if local != nil {
+		local.GracefulStop()
+	}
+	if trusted != nil {
+		trusted.GracefulStop()
+	}
 }
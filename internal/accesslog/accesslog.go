@@ -0,0 +1,312 @@
+// Package accesslog writes a structured JSON log of RPCs, and a separate
+// log of slow requests carrying the RocksDB PerfContext counters of their
+// database operations.
+package accesslog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/tap"
+	"google.golang.org/protobuf/proto"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/auth"
+	"rocksdb-service/internal/db"
+)
+
+// serviceMethodPrefix selects the RPCs that are logged
+const serviceMethodPrefix = "/rocksdb.RocksDBService/"
+
+// clientAddressKey is the metadata key carrying the address of the client
+// of a front-end, such as the REST gateway, calling on its behalf
+const clientAddressKey = "x-client-address"
+
+// WithClientAddress forwards addr as the client address of calls made with
+// ctx, to be logged instead of the front-end's own address
+func WithClientAddress(ctx context.Context, addr string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, clientAddressKey, addr)
+}
+
+type forwardedKey struct{}
+
+// TrustClientAddress is a tap handle, set with grpc.InTapHandle, for
+// servers only reachable by front-ends. Client addresses forwarded with
+// WithClientAddress are only logged on such servers, as any other client
+// could forge them.
+func TrustClientAddress(ctx context.Context, info *tap.Info) (context.Context, error) {
+	return context.WithValue(ctx, forwardedKey{}, true), nil
+}
+
+// clientAddress returns the address of the client of a request
+func clientAddress(ctx context.Context) (string, bool) {
+	if trusted, _ := ctx.Value(forwardedKey{}).(bool); trusted {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(clientAddressKey); len(values) > 0 {
+			return values[0], true
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String(), true
+	}
+	return "", false
+}
+
+// Options configures a Logger
+type Options struct {
+	// Access receives a line per RPC; nil disables the access log
+	Access io.Writer
+	// Slow receives a line per RPC taking at least SlowThreshold; nil
+	// disables the slow query log
+	Slow          io.Writer
+	SlowThreshold time.Duration
+	// RedactKeys replaces keys and prefixes with a short hash, which still
+	// correlates requests for the same key
+	RedactKeys bool
+	// Level is the minimum level logged. Requests are logged at INFO, slow
+	// requests at WARN and requests failing with server errors at ERROR.
+	// Nil logs everything from INFO.
+	Level slog.Leveler
+}
+
+// Logger logs RPCs through gRPC interceptors
+type Logger struct {
+	access    *slog.Logger
+	slow      *slog.Logger
+	threshold time.Duration
+	redact    bool
+}
+
+// New returns a Logger writing JSON lines to the configured outputs
+func New(opts Options) *Logger {
+	l := &Logger{threshold: opts.SlowThreshold, redact: opts.RedactKeys}
+	if opts.Access != nil {
+		l.access = slog.New(slog.NewJSONHandler(opts.Access, &slog.HandlerOptions{Level: opts.Level}))
+	}
+	if opts.Slow != nil {
+		l.slow = slog.New(slog.NewJSONHandler(opts.Slow, &slog.HandlerOptions{Level: opts.Level}))
+	}
+	return l
+}
+
+// Open opens a log output: "stdout", "stderr" or a file appended to. Writes
+// are unbuffered, so the output needs no flushing on exit.
+func Open(output string) (io.Writer, error) {
+	switch output {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return f, nil
+}
+
+// entry is the record of one RPC
+type entry struct {
+	method    string
+	start     time.Time
+	principal func() string
+	perf      *db.PerfStats
+
+	mu            sync.Mutex
+	attrs         []slog.Attr
+	requests      int
+	responses     int
+	requestBytes  int
+	responseBytes int
+}
+
+// begin starts the record of an RPC, returning the context to handle it in
+func (l *Logger) begin(ctx context.Context, method string) (context.Context, *entry) {
+	e := &entry{method: method, start: time.Now()}
+	ctx, e.principal = auth.RecordPrincipal(ctx)
+	if l.slow != nil {
+		ctx, e.perf = db.WithPerfStats(ctx)
+	}
+	if addr, ok := clientAddress(ctx); ok {
+		e.attrs = append(e.attrs, slog.String("peer", addr))
+	}
+	return ctx, e
+}
+
+// received records a request message, describing the request from the
+// first one
+func (l *Logger) received(e *entry, req any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	if m, ok := req.(proto.Message); ok {
+		e.requestBytes += proto.Size(m)
+	}
+	if e.requests == 1 {
+		e.attrs = append(e.attrs, l.describe(req)...)
+	}
+}
+
+// sent records a response message
+func (e *entry) sent(resp any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.responses++
+	if m, ok := resp.(proto.Message); ok {
+		e.responseBytes += proto.Size(m)
+	}
+}
+
+// describe returns the database and keys a request addresses
+func (l *Logger) describe(req any) []slog.Attr {
+	var attrs []slog.Attr
+	if r, ok := req.(interface{ GetDatabaseName() string }); ok && r.GetDatabaseName() != "" {
+		attrs = append(attrs, slog.String("database", r.GetDatabaseName()))
+	}
+	if r, ok := req.(interface{ GetKey() string }); ok && r.GetKey() != "" {
+		attrs = append(attrs, slog.String("key", l.redactKey(r.GetKey())))
+	}
+	switch r := req.(type) {
+	case *pb.StreamGetRequest:
+		switch q := r.Query.(type) {
+		case *pb.StreamGetRequest_Prefix:
+			attrs = append(attrs, slog.String("prefix", l.redactKey(q.Prefix)))
+		case *pb.StreamGetRequest_Keys:
+			attrs = append(attrs, slog.Int("keys", len(q.Keys.GetKeys())))
+		}
+	case *pb.ScanRequest:
+		if r.Prefix != "" {
+			attrs = append(attrs, slog.String("prefix", l.redactKey(r.Prefix)))
+		}
+	case *pb.SwapAliasRequest:
+		attrs = append(attrs, slog.String("alias", r.Alias))
+	case *pb.IncrementManyRequest:
+		attrs = append(attrs, slog.Int("keys", len(r.Increments)))
+	}
+	return attrs
+}
+
+func (l *Logger) redactKey(key string) string {
+	if !l.redact {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// finish logs a completed RPC
+func (l *Logger) finish(ctx context.Context, e *entry, stream bool, err error) {
+	latency := time.Since(e.start)
+
+	e.mu.Lock()
+	attrs := append([]slog.Attr{
+		slog.String("method", strings.TrimPrefix(e.method, serviceMethodPrefix)),
+	}, e.attrs...)
+	if principal := e.principal(); principal != "" {
+		attrs = append(attrs, slog.String("principal", principal))
+	}
+	attrs = append(attrs,
+		slog.String("code", status.Code(err).String()),
+		slog.Int("request_bytes", e.requestBytes),
+		slog.Int("response_bytes", e.responseBytes),
+	)
+	if stream {
+		attrs = append(attrs,
+			slog.Int("requests", e.requests),
+			slog.Int("responses", e.responses),
+		)
+	}
+	e.mu.Unlock()
+	attrs = append(attrs, slog.Float64("latency_ms", float64(latency.Microseconds())/1000))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+
+	if l.access != nil {
+		level := slog.LevelInfo
+		switch status.Code(err) {
+		case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable:
+			level = slog.LevelError
+		}
+		l.access.LogAttrs(ctx, level, "rpc", attrs...)
+	}
+	if l.slow != nil && latency >= l.threshold {
+		attrs = append(attrs, slog.Any("perf", e.perf.Counters()))
+		l.slow.LogAttrs(ctx, slog.LevelWarn, "slow rpc", attrs...)
+	}
+}
+
+// Unary returns an interceptor logging unary RPCs. It should run before
+// other interceptors so rejected requests are logged too.
+func (l *Logger) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, serviceMethodPrefix) {
+			return handler(ctx, req)
+		}
+
+		ctx, e := l.begin(ctx, info.FullMethod)
+		l.received(e, req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			e.sent(resp)
+		}
+		l.finish(ctx, e, false, err)
+		return resp, err
+	}
+}
+
+// Stream returns an interceptor logging streaming RPCs once they end
+func (l *Logger) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasPrefix(info.FullMethod, serviceMethodPrefix) {
+			return handler(srv, ss)
+		}
+
+		ctx, e := l.begin(ss.Context(), info.FullMethod)
+		err := handler(srv, &loggedStream{ServerStream: ss, ctx: ctx, logger: l, entry: e})
+		l.finish(ctx, e, true, err)
+		return err
+	}
+}
+
+// loggedStream records the messages of a streaming RPC
+type loggedStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	logger *Logger
+	entry  *entry
+}
+
+func (s *loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.logger.received(s.entry, m)
+	return nil
+}
+
+func (s *loggedStream) SendMsg(m any) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.entry.sent(m)
+	return nil
+}
@@ -0,0 +1,70 @@
+package accesslog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/accesslog"
+)
+
+// syncBuffer is a buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// peerLogged makes a call forwarding a client address to a server with the
+// given options and returns the peer it logged
+func peerLogged(t *testing.T, serverOpts ...grpc.ServerOption) string {
+	t.Helper()
+	var out syncBuffer
+	logger := accesslog.New(accesslog.Options{Access: &out})
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(logger.Unary()),
+		grpc.ChainStreamInterceptor(logger.Stream()),
+	)
+	srv, err := embedded.NewServer(embedded.Options{}, serverOpts...)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+	cc, err := srv.Dial()
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer cc.Close()
+
+	ctx := accesslog.WithClientAddress(context.Background(), "192.0.2.1:4321")
+	if _, err := pb.NewRocksDBServiceClient(cc).Get(ctx, &pb.GetRequest{DatabaseName: "db", Key: "k"}); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	var line struct{ Peer string }
+	if err := json.Unmarshal(out.buf.Bytes(), &line); err != nil {
+		t.Fatalf("parsing the access log %q: %v", out.buf.String(), err)
+	}
+	return line.Peer
+}
+
+func TestClientAddress(t *testing.T) {
+	if got := peerLogged(t, grpc.InTapHandle(accesslog.TrustClientAddress)); got != "192.0.2.1:4321" {
+		t.Errorf("peer on a server trusting client addresses = %q, want the forwarded address", got)
+	}
+	if got := peerLogged(t); got == "192.0.2.1:4321" || got == "" {
+		t.Errorf("peer on other servers = %q, want the connection's address", got)
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"rocksdb-service/internal/filewatch"
+)
+
+// Rule grants a principal access to databases, optionally restricted to
+// key prefixes
+type Rule struct {
+	// Principal is the principal the rule applies to, "*" for any
+	// authenticated principal
+	Principal string `json:"principal"`
+	// Databases are database names, where a trailing "*" matches any
+	// suffix and "*" alone matches every database
+	Databases []string `json:"databases"`
+	// Prefixes restrict the rule to keys with one of these prefixes. Empty
+	// means all keys.
+	Prefixes []string `json:"prefixes,omitempty"`
+	// Access is one of "read", "write" or "admin"
+	Access string `json:"access"`
+
+	access Access
+}
+
+func (r *Rule) matches(principal, database string, need Access) bool {
+	if r.access < need || (r.Principal != "*" && r.Principal != principal) {
+		return false
+	}
+	for _, pattern := range r.Databases {
+		if matchName(pattern, database) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) allowsKey(key string) bool {
+	if len(r.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range r.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchName(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return pattern == name
+}
+
+// ACL is a set of rules, usually loaded from a JSON file:
+//
+//	{"rules": [
+//	  {"principal": "etl", "databases": ["catalog*"], "access": "write"},
+//	  {"principal": "web", "databases": ["users"], "prefixes": ["public/"], "access": "read"}
+//	]}
+//
+// Anything not granted by a rule is denied. The file is reloaded when it
+// changes.
+type ACL struct {
+	path    string
+	watcher *filewatch.Watcher
+
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// LoadACL reads the ACL file at path
+func LoadACL(path string) (*ACL, error) {
+	a := &ACL{path: path}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	watcher, err := filewatch.New(reloadCheckInterval, path)
+	if err != nil {
+		return nil, err
+	}
+	a.watcher = watcher
+	return a, nil
+}
+
+// NewACL returns an ACL with the given rules
+func NewACL(rules []Rule) (*ACL, error) {
+	a := &ACL{}
+	if err := a.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetRules validates and replaces the rules of the ACL
+func (a *ACL) SetRules(rules []Rule) error {
+	rules = slices.Clone(rules)
+	for i := range rules {
+		r := &rules[i]
+		if r.Principal == "" || len(r.Databases) == 0 {
+			return fmt.Errorf("ACL rule %d: principal and databases are required", i)
+		}
+		var err error
+		if r.access, err = ParseAccess(r.Access); err != nil {
+			return fmt.Errorf("ACL rule %d: %w", i, err)
+		}
+	}
+
+	a.mu.Lock()
+	a.rules = rules
+	a.mu.Unlock()
+	return nil
+}
+
+// Reload re-reads the ACL file, if the ACL was loaded from one
+func (a *ACL) Reload() error {
+	if a.path == "" {
+		return nil
+	}
+	return a.load()
+}
+
+func (a *ACL) load() error {
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to read ACL file: %w", err)
+	}
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse ACL file: %w", err)
+	}
+	return a.SetRules(file.Rules)
+}
+
+// Allowed reports whether principal has need access to database. If keys
+// is non-empty every key must be covered by a matching rule; otherwise,
+// including for requests naming no keys, the rule must cover all keys of
+// the database.
+func (a *ACL) Allowed(principal, database string, keys []string, need Access) bool {
+	if a.watcher != nil && a.watcher.Changed() {
+		if err := a.load(); err != nil {
+			log.Printf("Failed to reload ACL file: %v", err)
+		}
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if len(keys) == 0 {
+		for i := range a.rules {
+			if r := &a.rules[i]; r.matches(principal, database, need) && len(r.Prefixes) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, key := range keys {
+		allowed := false
+		for i := range a.rules {
+			if r := &a.rules[i]; r.matches(principal, database, need) && r.allowsKey(key) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
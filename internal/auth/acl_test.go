@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"testing"
+)
+
+func newTestACL(t *testing.T) *ACL {
+	t.Helper()
+	acl, err := NewACL([]Rule{
+		{Principal: "etl", Databases: []string{"catalog*"}, Access: "write"},
+		{Principal: "web", Databases: []string{"users"}, Prefixes: []string{"public/"}, Access: "read"},
+		{Principal: "ops", Databases: []string{"*"}, Access: "admin"},
+		{Principal: "*", Databases: []string{"shared"}, Access: "read"},
+	})
+	if err != nil {
+		t.Fatalf("NewACL: %v", err)
+	}
+	return acl
+}
+
+func TestACLAllowed(t *testing.T) {
+	acl := newTestACL(t)
+
+	tests := []struct {
+		name      string
+		principal string
+		database  string
+		keys      []string
+		need      Access
+		want      bool
+	}{
+		{"pattern match", "etl", "catalog-2024", []string{"k"}, AccessWrite, true},
+		{"lower access included", "etl", "catalog", nil, AccessRead, true},
+		{"higher access denied", "etl", "catalog", nil, AccessAdmin, false},
+		{"pattern mismatch", "etl", "users", []string{"k"}, AccessRead, false},
+		{"prefix allowed", "web", "users", []string{"public/a", "public/b"}, AccessRead, true},
+		{"one key outside prefix", "web", "users", []string{"public/a", "private/b"}, AccessRead, false},
+		{"prefix rule without keys", "web", "users", nil, AccessRead, false},
+		{"prefix rule with empty keys", "web", "users", []string{}, AccessRead, false},
+		{"wildcard database", "ops", "anything", nil, AccessAdmin, true},
+		{"wildcard principal", "someone", "shared", []string{"k"}, AccessRead, true},
+		{"wildcard principal write", "someone", "shared", []string{"k"}, AccessWrite, false},
+		{"unknown principal", "someone", "users", []string{"public/a"}, AccessRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.Allowed(tt.principal, tt.database, tt.keys, tt.need); got != tt.want {
+				t.Errorf("Allowed(%q, %q, %q, %v) = %v, want %v", tt.principal, tt.database, tt.keys, tt.need, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACLRejectsInvalidRules(t *testing.T) {
+	for _, rule := range []Rule{
+		{Databases: []string{"db"}, Access: "read"},
+		{Principal: "p", Access: "read"},
+		{Principal: "p", Databases: []string{"db"}, Access: "owner"},
+	} {
+		if _, err := NewACL([]Rule{rule}); err == nil {
+			t.Errorf("NewACL(%+v) succeeded, want an error", rule)
+		}
+	}
+}
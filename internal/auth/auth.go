@@ -0,0 +1,77 @@
+// Package auth authenticates requests with bearer tokens and authorizes
+// them against per-database access control lists.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// reloadCheckInterval bounds how often token and ACL files are checked for
+// changes
+const reloadCheckInterval = time.Second
+
+// Access is a level of access to a database. Each level includes the ones
+// below it.
+type Access int
+
+const (
+	AccessNone Access = iota
+	AccessRead
+	AccessWrite
+	AccessAdmin
+)
+
+// ParseAccess parses an access level name
+func ParseAccess(s string) (Access, error) {
+	switch s {
+	case "read":
+		return AccessRead, nil
+	case "write":
+		return AccessWrite, nil
+	case "admin":
+		return AccessAdmin, nil
+	}
+	return AccessNone, fmt.Errorf("unknown access level %q", s)
+}
+
+func (a Access) String() string {
+	switch a {
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	case AccessAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+type (
+	principalKey       struct{}
+	principalRecordKey struct{}
+)
+
+// WithPrincipal returns a context carrying the authenticated principal
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	if recorded, ok := ctx.Value(principalRecordKey{}).(*string); ok {
+		*recorded = principal
+	}
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// RecordPrincipal returns a context recording the principal authenticated
+// further down the interceptor chain, and a function returning it once the
+// request is done, so outer interceptors can report it
+func RecordPrincipal(ctx context.Context) (context.Context, func() string) {
+	var recorded string
+	ctx = context.WithValue(ctx, principalRecordKey{}, &recorded)
+	return ctx, func() string { return recorded }
+}
+
+// PrincipalFromContext returns the authenticated principal of a request
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+)
+
+// serviceMethodPrefix selects the RPCs subject to authentication. Other
+// services, such as health checks, are left open.
+const serviceMethodPrefix = "/rocksdb.RocksDBService/"
+
+// Interceptor authenticates RPCs with bearer tokens and authorizes each
+// request message against an ACL before it reaches the handler
+type Interceptor struct {
+	verifiers []TokenVerifier
+	acl       *ACL
+}
+
+// NewInterceptor returns an interceptor accepting tokens recognized by any
+// of verifiers. If acl is nil, every authenticated principal has full
+// access.
+func NewInterceptor(acl *ACL, verifiers ...TokenVerifier) *Interceptor {
+	return &Interceptor{verifiers: verifiers, acl: acl}
+}
+
+// Unary returns the unary server interceptor
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, serviceMethodPrefix) {
+			return handler(ctx, req)
+		}
+		principal, err := i.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := i.authorize(principal, req, ""); err != nil {
+			return nil, err
+		}
+		return handler(WithPrincipal(ctx, principal), req)
+	}
+}
+
+// Stream returns the stream server interceptor. Every message received on
+// the stream is authorized.
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasPrefix(info.FullMethod, serviceMethodPrefix) {
+			return handler(srv, ss)
+		}
+		principal, err := i.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{
+			ServerStream: ss,
+			interceptor:  i,
+			principal:    principal,
+			ctx:          WithPrincipal(ss.Context(), principal),
+		})
+	}
+}
+
+// authenticate resolves the bearer token of a request to its principal
+func (i *Interceptor) authenticate(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization is not a bearer token")
+	}
+
+	principal, err := i.Verify(token)
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	return principal, nil
+}
+
+// Verify resolves token to its principal with the first verifier that
+// recognizes it, for protocols that authenticate outside of gRPC
+func (i *Interceptor) Verify(token string) (string, error) {
+	for _, v := range i.verifiers {
+		if principal, err := v.Verify(token); err == nil {
+			return principal, nil
+		}
+	}
+	return "", ErrInvalidToken
+}
+
+// access describes the access a request needs to one database
+type access struct {
+	database string
+	keys     []string
+	need     Access
+}
+
+// requiredAccess returns the access needed by a request message. Streams
+// may omit the database name after the first message, in which case
+// streamDB is used.
+func requiredAccess(req any, streamDB string) []access {
+	switch r := req.(type) {
+	case *pb.GetRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessRead}}
+	case *pb.StreamGetRequest:
+		switch q := r.Query.(type) {
+		case *pb.StreamGetRequest_Prefix:
+			return []access{{r.DatabaseName, []string{q.Prefix}, AccessRead}}
+		case *pb.StreamGetRequest_Keys:
+			return []access{{r.DatabaseName, q.Keys.GetKeys(), AccessRead}}
+		}
+		return []access{{r.DatabaseName, nil, AccessRead}}
+	case *pb.ScanRequest:
+		if r.Prefix != "" {
+			return []access{{r.DatabaseName, []string{r.Prefix}, AccessRead}}
+		}
+		return []access{{r.DatabaseName, nil, AccessRead}}
+	case *pb.ListDatabasesRequest:
+		// Listing reveals every database name, so needs read access to all
+		return []access{{"*", nil, AccessRead}}
+	case *pb.GetAsOfRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessRead}}
+	case *pb.GetHistoryRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessRead}}
+	case *pb.PutRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessWrite}}
+	case *pb.DeleteRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessWrite}}
+	case *pb.CompareAndSwapRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessWrite}}
+	case *pb.PutIfAbsentRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessWrite}}
+	case *pb.DeleteIfEqualsRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessWrite}}
+	case *pb.IncrementRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessWrite}}
+	case *pb.IncrementManyRequest:
+		keys := make([]string, len(r.Increments))
+		for i, inc := range r.Increments {
+			keys[i] = inc.Key
+		}
+		return []access{{r.DatabaseName, keys, AccessWrite}}
+	case *pb.GetCounterRequest:
+		return []access{{r.DatabaseName, []string{r.Key}, AccessRead}}
+	case *pb.GetStatsRequest:
+		return []access{{r.DatabaseName, nil, AccessRead}}
+	case *pb.StreamWriteRequest:
+		database := r.DatabaseName
+		if database == "" {
+			database = streamDB
+		}
+		return []access{{database, []string{r.Key}, AccessWrite}}
+	case *pb.SwapAliasRequest:
+		return []access{
+			{r.Alias, nil, AccessAdmin},
+			{r.DatabaseName, nil, AccessAdmin},
+		}
+	case *pb.GetUsageRequest:
+		// Usage of principals is only visible to global admins
+		if r.DatabaseName == "" || r.Principal != "" {
+			return []access{{"", nil, AccessAdmin}}
+		}
+		return []access{{r.DatabaseName, nil, AccessAdmin}}
+	case *pb.RotateKeyRequest:
+		return []access{{r.DatabaseName, nil, AccessAdmin}}
+	case *pb.ListJobsRequest:
+		if r.DatabaseName == "" {
+			return []access{{"", nil, AccessAdmin}}
+		}
+		return []access{{r.DatabaseName, nil, AccessAdmin}}
+	}
+	// Requests without a known mapping need admin access to everything
+	return []access{{"", nil, AccessAdmin}}
+}
+
+func (i *Interceptor) authorize(principal string, req any, streamDB string) error {
+	if i.acl == nil {
+		return nil
+	}
+	for _, a := range requiredAccess(req, streamDB) {
+		if a.database == "" && a.need != AccessAdmin {
+			// Let the handler reject the missing database name
+			continue
+		}
+		if !i.acl.Allowed(principal, a.database, a.keys, a.need) {
+			return status.Errorf(codes.PermissionDenied, "%s has no %s access to database %q", principal, a.need, a.database)
+		}
+	}
+	return nil
+}
+
+// authorizedStream authorizes every message received from the client
+type authorizedStream struct {
+	grpc.ServerStream
+	interceptor *Interceptor
+	principal   string
+	ctx         context.Context
+	database    string
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if err := s.interceptor.authorize(s.principal, m, s.database); err != nil {
+		return err
+	}
+	if r, ok := m.(interface{ GetDatabaseName() string }); ok && s.database == "" {
+		s.database = r.GetDatabaseName()
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+)
+
+// tokenMap is a TokenVerifier backed by a map
+type tokenMap map[string]string
+
+func (m tokenMap) Verify(token string) (string, error) {
+	if principal, ok := m[token]; ok {
+		return principal, nil
+	}
+	return "", ErrInvalidToken
+}
+
+// callUnary runs req through the unary interceptor with token, returning
+// the principal seen by the handler
+func callUnary(t *testing.T, i *Interceptor, token string, req any) (string, error) {
+	t.Helper()
+	ctx := context.Background()
+	if token != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: serviceMethodPrefix + "Test"}
+	var principal string
+	_, err := i.Unary()(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		principal, _ = PrincipalFromContext(ctx)
+		return nil, nil
+	})
+	return principal, err
+}
+
+func TestInterceptorAuthenticates(t *testing.T) {
+	i := NewInterceptor(nil, tokenMap{"tok-etl": "etl"})
+	req := &pb.GetRequest{DatabaseName: "db", Key: "k"}
+
+	if principal, err := callUnary(t, i, "tok-etl", req); err != nil || principal != "etl" {
+		t.Errorf("valid token: principal %q, err %v; want etl", principal, err)
+	}
+	if _, err := callUnary(t, i, "", req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("missing token: %v, want Unauthenticated", err)
+	}
+	if _, err := callUnary(t, i, "tok-unknown", req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("unknown token: %v, want Unauthenticated", err)
+	}
+}
+
+func TestInterceptorAuthorizes(t *testing.T) {
+	i := NewInterceptor(newTestACL(t), tokenMap{"tok-etl": "etl", "tok-web": "web"})
+
+	tests := []struct {
+		name  string
+		token string
+		req   any
+		want  codes.Code
+	}{
+		{"read within prefix", "tok-web", &pb.GetRequest{DatabaseName: "users", Key: "public/a"}, codes.OK},
+		{"read outside prefix", "tok-web", &pb.GetRequest{DatabaseName: "users", Key: "private/a"}, codes.PermissionDenied},
+		{"write with read access", "tok-web", &pb.PutRequest{DatabaseName: "users", Key: "public/a"}, codes.PermissionDenied},
+		{"write", "tok-etl", &pb.PutRequest{DatabaseName: "catalog", Key: "k"}, codes.OK},
+		{"increment many with read access", "tok-web", &pb.IncrementManyRequest{DatabaseName: "users", Increments: []*pb.CounterIncrement{{Key: "public/a"}}}, codes.PermissionDenied},
+		{"empty increment many with key-restricted rule", "tok-web", &pb.IncrementManyRequest{DatabaseName: "users"}, codes.PermissionDenied},
+		{"empty increment many on other database", "tok-web", &pb.IncrementManyRequest{DatabaseName: "created-by-increment"}, codes.PermissionDenied},
+		{"empty increment many with database access", "tok-etl", &pb.IncrementManyRequest{DatabaseName: "catalog"}, codes.OK},
+		{"scan without prefix under prefix rule", "tok-web", &pb.ScanRequest{DatabaseName: "users"}, codes.PermissionDenied},
+		{"scan within prefix", "tok-web", &pb.ScanRequest{DatabaseName: "users", Prefix: "public/"}, codes.OK},
+		{"swap alias needs admin", "tok-etl", &pb.SwapAliasRequest{Alias: "catalog", DatabaseName: "catalog-v2"}, codes.PermissionDenied},
+		{"list databases needs access to all", "tok-etl", &pb.ListDatabasesRequest{}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := callUnary(t, i, tt.token, tt.req); status.Code(err) != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptorSkipsOtherServices(t *testing.T) {
+	i := NewInterceptor(newTestACL(t), tokenMap{})
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	_, err := i.Unary()(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Errorf("health check without a token: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"rocksdb-service/internal/filewatch"
+)
+
+// ErrInvalidToken is returned for tokens that cannot be verified
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenVerifier resolves a bearer token to the principal it was issued to
+type TokenVerifier interface {
+	Verify(token string) (string, error)
+}
+
+// StaticTokens verifies tokens listed in a JSON file mapping each token to
+// its principal:
+//
+//	{"s3cr3t-token": "etl", "other-token": "dashboard"}
+//
+// The file is reloaded when it changes.
+type StaticTokens struct {
+	path    string
+	watcher *filewatch.Watcher
+
+	mu     sync.RWMutex
+	tokens []staticToken
+}
+
+// staticToken is a token of the file, kept as its SHA-256 digest so that
+// comparisons take the same time whatever the length of the tokens
+type staticToken struct {
+	digest    [sha256.Size]byte
+	principal string
+}
+
+// LoadStaticTokens reads the token file at path
+func LoadStaticTokens(path string) (*StaticTokens, error) {
+	s := &StaticTokens{path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	watcher, err := filewatch.New(reloadCheckInterval, path)
+	if err != nil {
+		return nil, err
+	}
+	s.watcher = watcher
+	return s, nil
+}
+
+func (s *StaticTokens) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read token file: %w", err)
+	}
+	var tokens map[string]string
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return fmt.Errorf("failed to parse token file: %w", err)
+	}
+
+	digests := make([]staticToken, 0, len(tokens))
+	for token, principal := range tokens {
+		digests = append(digests, staticToken{digest: sha256.Sum256([]byte(token)), principal: principal})
+	}
+
+	s.mu.Lock()
+	s.tokens = digests
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *StaticTokens) Verify(token string) (string, error) {
+	if s.watcher.Changed() {
+		if err := s.load(); err != nil {
+			log.Printf("Failed to reload token file: %v", err)
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Every token is compared in constant time, so the time taken reveals
+	// neither how much of a token matched nor which one did
+	digest := sha256.Sum256([]byte(token))
+	principal, found := "", false
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(digest[:], t.digest[:]) == 1 {
+			principal, found = t.principal, true
+		}
+	}
+	if !found {
+		return "", ErrInvalidToken
+	}
+	return principal, nil
+}
+
+// JWTVerifier verifies HS256-signed JSON Web Tokens against a shared secret.
+// The principal is taken from the "sub" claim, and "exp" and "nbf" are
+// enforced when present.
+type JWTVerifier struct {
+	secret []byte
+}
+
+// LoadJWTSecret reads the HMAC secret from the file at path
+func LoadJWTSecret(path string) (*JWTVerifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT secret: %w", err)
+	}
+	secret := []byte(strings.TrimSpace(string(data)))
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("JWT secret file %s is empty", path)
+	}
+	return &JWTVerifier{secret: secret}, nil
+}
+
+func (v *JWTVerifier) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return "", ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", ErrInvalidToken
+	}
+
+	var claims struct {
+		Sub string `json:"sub"`
+		Exp *int64 `json:"exp"`
+		Nbf *int64 `json:"nbf"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil || claims.Sub == "" {
+		return "", ErrInvalidToken
+	}
+	now := time.Now().Unix()
+	if claims.Exp != nil && now >= *claims.Exp {
+		return "", fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if claims.Nbf != nil && now < *claims.Nbf {
+		return "", fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+	return claims.Sub, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signJWT returns an HS256 token for claims
+func signJWT(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func writeTestFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTVerifier(t *testing.T) {
+	v, err := LoadJWTSecret(writeTestFile(t, "secret", "s3cr3t\n"))
+	if err != nil {
+		t.Fatalf("LoadJWTSecret: %v", err)
+	}
+	now := time.Now().Unix()
+
+	principal, err := v.Verify(signJWT(t, "s3cr3t", map[string]any{"sub": "etl", "exp": now + 60}))
+	if err != nil || principal != "etl" {
+		t.Errorf("Verify(valid token) = %q, %v; want etl", principal, err)
+	}
+
+	for name, token := range map[string]string{
+		"wrong secret": signJWT(t, "other", map[string]any{"sub": "etl"}),
+		"expired":      signJWT(t, "s3cr3t", map[string]any{"sub": "etl", "exp": now - 1}),
+		"not yet":      signJWT(t, "s3cr3t", map[string]any{"sub": "etl", "nbf": now + 60}),
+		"no subject":   signJWT(t, "s3cr3t", map[string]any{"exp": now + 60}),
+		"malformed":    "not.a-token",
+	} {
+		if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(%s) = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestStaticTokens(t *testing.T) {
+	s, err := LoadStaticTokens(writeTestFile(t, "tokens.json", `{"tok-etl": "etl", "tok-dash": "dashboard"}`))
+	if err != nil {
+		t.Fatalf("LoadStaticTokens: %v", err)
+	}
+	for token, want := range map[string]string{"tok-etl": "etl", "tok-dash": "dashboard"} {
+		if principal, err := s.Verify(token); err != nil || principal != want {
+			t.Errorf("Verify(%s) = %q, %v; want %s", token, principal, err, want)
+		}
+	}
+	for _, token := range []string{"tok-unknown", "tok-et", "tok-etl2", ""} {
+		if _, err := s.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(%q) = %v, want ErrInvalidToken", token, err)
+		}
+	}
+}
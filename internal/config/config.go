@@ -0,0 +1,312 @@
+// Package config loads the server configuration from a YAML, TOML or JSON
+// file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"rocksdb-service/internal/auth"
+	"rocksdb-service/internal/quota"
+)
+
+// Config is the server configuration. Every format uses the JSON field
+// names.
+type Config struct {
+	// Listen holds the addresses to serve on: "host:port", or
+	// "unix:///path" for a Unix socket
+	Listen []string `json:"listen"`
+	// DataDir is the directory holding the databases
+	DataDir string `json:"data_dir"`
+	// Backend stores the databases: "rocksdb" in DataDir, or "memory" in
+	// process, losing them on exit
+	Backend string `json:"backend"`
+	// AliasRetention is the number of previous alias targets kept for
+	// rollback
+	AliasRetention int `json:"alias_retention"`
+	// HealthCheckInterval is the interval at which open databases are
+	// checked for background errors
+	HealthCheckInterval Duration `json:"health_check_interval"`
+
+	StreamWrite StreamWrite         `json:"stream_write"`
+	TLS         TLS                 `json:"tls"`
+	Auth        Auth                `json:"auth"`
+	Quotas      Quotas              `json:"quotas"`
+	Encryption  Encryption          `json:"encryption"`
+	Memory      Memory              `json:"memory"`
+	Databases   map[string]Database `json:"databases"`
+	Logging     Logging             `json:"logging"`
+	Tracing     Tracing             `json:"tracing"`
+	RESP        RESP                `json:"resp"`
+	HTTP        HTTP                `json:"http"`
+}
+
+// StreamWrite configures StreamWrite batching
+type StreamWrite struct {
+	BatchSize     int      `json:"batch_size"`
+	FlushInterval Duration `json:"flush_interval"`
+}
+
+// TLS configures TLS. Setting ClientCA enables mutual TLS.
+type TLS struct {
+	Cert     string `json:"cert"`
+	Key      string `json:"key"`
+	ClientCA string `json:"client_ca"`
+}
+
+// Auth configures authentication and authorization. Rules may be given
+// inline or in ACLFile, not both.
+type Auth struct {
+	TokensFile    string      `json:"tokens_file"`
+	JWTSecretFile string      `json:"jwt_secret_file"`
+	ACLFile       string      `json:"acl_file"`
+	ACL           []auth.Rule `json:"acl"`
+}
+
+// Enabled reports whether authentication is configured
+func (a *Auth) Enabled() bool {
+	return a.TokensFile != "" || a.JWTSecretFile != ""
+}
+
+// Quotas configures quotas, either inline or in File, not both
+type Quotas struct {
+	File       string                  `json:"file"`
+	Principals map[string]quota.Limits `json:"principals"`
+	Databases  map[string]quota.Limits `json:"databases"`
+}
+
+// Enabled reports whether quotas are configured
+func (q *Quotas) Enabled() bool {
+	return q.File != "" || q.Principals != nil || q.Databases != nil
+}
+
+// Config returns the inline quota configuration
+func (q *Quotas) Config() quota.Config {
+	return quota.Config{Principals: q.Principals, Databases: q.Databases}
+}
+
+// Encryption configures encryption at rest
+type Encryption struct {
+	KeysFile string `json:"keys_file"`
+}
+
+// Memory configures memory budgets shared by all databases
+type Memory struct {
+	// BlockCache is the size of the shared block cache
+	BlockCache ByteSize `json:"block_cache"`
+	// WriteBuffers bounds the memory of all memtables
+	WriteBuffers ByteSize `json:"write_buffers"`
+}
+
+// Database configures one database. The "*" entry applies to databases
+// without their own entry.
+type Database struct {
+	// HistoryRetention enables history mode
+	HistoryRetention Duration `json:"history_retention"`
+	// Encrypt encrypts new values
+	Encrypt bool `json:"encrypt"`
+	// RocksDB holds RocksDB options by name, e.g. "write_buffer_size".
+	// Mutable options are applied on reload.
+	RocksDB map[string]string `json:"rocksdb"`
+}
+
+// Logging configures the access and slow-query logs
+type Logging struct {
+	// Level is the minimum level of access and slow-query log entries:
+	// "info" logs every request, "warn" slow requests and "error" requests
+	// failing with server errors
+	Level         string   `json:"level"`
+	AccessLog     string   `json:"access_log"`
+	RedactKeys    bool     `json:"redact_keys"`
+	SlowLog       string   `json:"slow_log"`
+	SlowThreshold Duration `json:"slow_threshold"`
+}
+
+// Tracing configures OpenTelemetry tracing
+type Tracing struct {
+	Output      string  `json:"output"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// RESP configures the Redis protocol front-end
+type RESP struct {
+	// Listen is the address to serve RESP on, like the entries of
+	// Config.Listen. Empty disables RESP.
+	Listen string `json:"listen"`
+	// Databases maps the database indexes of SELECT to database names.
+	// Index 0 is selected on connecting, "default" if none are given.
+	Databases []string `json:"databases"`
+}
+
+// HTTP configures the REST gateway and gRPC-Web
+type HTTP struct {
+	// Listen is the address to serve HTTP on, like the entries of
+	// Config.Listen. It uses the TLS settings of the gRPC listeners. Empty
+	// disables HTTP.
+	Listen string `json:"listen"`
+	// GRPCWeb serves the gRPC service to browsers with gRPC-Web
+	GRPCWeb bool `json:"grpc_web"`
+	// CORSOrigins are the origins of pages allowed to call the HTTP
+	// endpoints, "*" for any
+	CORSOrigins []string `json:"cors_origins"`
+	// UI serves the admin console under /ui/
+	UI bool `json:"ui"`
+}
+
+// Default returns the configuration used for settings missing from a file
+func Default() *Config {
+	return &Config{
+		Listen:              []string{":50051"},
+		DataDir:             "/data/rocksdb",
+		Backend:             "rocksdb",
+		AliasRetention:      2,
+		HealthCheckInterval: Duration(5 * time.Second),
+		StreamWrite: StreamWrite{
+			BatchSize:     1000,
+			FlushInterval: Duration(100 * time.Millisecond),
+		},
+		Logging: Logging{
+			Level:         "info",
+			SlowThreshold: Duration(500 * time.Millisecond),
+		},
+		Tracing: Tracing{SampleRatio: 1},
+	}
+}
+
+// Load reads the configuration file at path over the defaults. The format
+// is chosen by the extension: .yaml, .yml, .toml or .json.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// YAML and TOML are converted to JSON, so every format is decoded and
+	// checked for unknown fields the same way
+	var doc any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		var table map[string]any
+		err = toml.Unmarshal(data, &table)
+		doc = table
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q: use .yaml, .yml, .toml or .json", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	cfg := Default()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration, reporting every problem found
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, field, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(len(c.Listen) > 0, "listen", "at least one address is required")
+	address := func(field, addr string) {
+		if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+			check(path != "", field, "unix socket path is required")
+			return
+		}
+		_, _, err := net.SplitHostPort(addr)
+		check(err == nil, field, "invalid address %q: use host:port or unix:///path", addr)
+	}
+	for i, addr := range c.Listen {
+		address(fmt.Sprintf("listen[%d]", i), addr)
+	}
+	check(c.Backend == "rocksdb" || c.Backend == "memory", "backend", "unknown backend %q: use rocksdb or memory", c.Backend)
+	check(c.DataDir != "" || c.Backend == "memory", "data_dir", "is required")
+	check(c.AliasRetention >= 0, "alias_retention", "must not be negative")
+	check(c.HealthCheckInterval > 0, "health_check_interval", "must be positive")
+	check(c.StreamWrite.BatchSize > 0, "stream_write.batch_size", "must be positive")
+	check(c.StreamWrite.FlushInterval > 0, "stream_write.flush_interval", "must be positive")
+
+	check((c.TLS.Cert == "") == (c.TLS.Key == ""), "tls", "cert and key must be set together")
+	check(c.TLS.ClientCA == "" || c.TLS.Cert != "", "tls.client_ca", "requires cert and key")
+
+	check(c.Auth.ACLFile == "" || c.Auth.ACL == nil, "auth", "acl and acl_file are mutually exclusive")
+	check(c.Auth.Enabled() || (c.Auth.ACLFile == "" && c.Auth.ACL == nil), "auth.acl", "requires tokens_file or jwt_secret_file")
+	if c.Auth.ACL != nil {
+		_, err := auth.NewACL(c.Auth.ACL)
+		check(err == nil, "auth.acl", "%v", err)
+	}
+
+	check(c.Quotas.File == "" || (c.Quotas.Principals == nil && c.Quotas.Databases == nil), "quotas", "file and inline limits are mutually exclusive")
+	for kind, limits := range map[string]map[string]quota.Limits{"principals": c.Quotas.Principals, "databases": c.Quotas.Databases} {
+		for _, name := range slices.Sorted(maps.Keys(limits)) {
+			l := limits[name]
+			check(l.RequestsPerSecond >= 0 && l.ReadBytesPerSecond >= 0 && l.WriteBytesPerSecond >= 0 && l.MaxStreams >= 0 && l.MaxStorageBytes >= 0,
+				fmt.Sprintf("quotas.%s.%s", kind, name), "limits must not be negative")
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Databases)) {
+		d := c.Databases[name]
+		field := "databases." + name
+		check(name != "", field, "database name is required")
+		check(d.HistoryRetention >= 0, field+".history_retention", "must not be negative")
+		check(!d.Encrypt || c.Encryption.KeysFile != "", field+".encrypt", "requires encryption.keys_file")
+		for _, option := range slices.Sorted(maps.Keys(d.RocksDB)) {
+			value := d.RocksDB[option]
+			check(option != "" && !strings.ContainsAny(option, "=;{}") && !strings.ContainsAny(value, ";{}"),
+				fmt.Sprintf("%s.rocksdb.%s", field, option), "invalid option %q", option+"="+value)
+		}
+	}
+
+	if c.RESP.Listen != "" {
+		address("resp.listen", c.RESP.Listen)
+	}
+	if c.HTTP.Listen != "" {
+		address("http.listen", c.HTTP.Listen)
+	}
+	check(c.HTTP.Listen != "" || (!c.HTTP.GRPCWeb && !c.HTTP.UI && c.HTTP.CORSOrigins == nil), "http", "grpc_web, ui and cors_origins require listen")
+	for i, origin := range c.HTTP.CORSOrigins {
+		check(origin == "*" || strings.Contains(origin, "://"), fmt.Sprintf("http.cors_origins[%d]", i), "invalid origin %q: use scheme://host[:port] or *", origin)
+	}
+	for i, name := range c.RESP.Databases {
+		check(name != "", fmt.Sprintf("resp.databases[%d]", i), "database name is required")
+	}
+
+	_, err := ParseLevel(c.Logging.Level)
+	check(err == nil, "logging.level", "%v", err)
+	check(c.Logging.SlowThreshold >= 0, "logging.slow_threshold", "must not be negative")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
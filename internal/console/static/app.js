@@ -0,0 +1,537 @@
+// Admin console of the RocksDB service. Everything it shows or changes goes
+// through the REST API under /v1/ with the token entered at sign-in, so the
+// server's authentication and ACLs apply as to any other client.
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+const state = {
+  token: sessionStorage.getItem("token") || "",
+  database: "",
+  prefix: "",
+  pageSize: 50,
+  // starts holds the first key of each page browsed so far
+  starts: [""],
+  page: 0,
+  // key is the key open in the editor, null for a new key
+  key: null,
+  version: 0,
+  value: new Uint8Array(),
+  format: "text",
+};
+
+let jobsTimer;
+
+class APIError extends Error {
+  constructor(status, body) {
+    super(body.error || `HTTP ${status}`);
+    this.status = status;
+    this.code = body.code || "";
+    this.condition = body.condition;
+  }
+}
+
+// api calls the REST API, throwing an APIError for error responses
+async function api(method, path, { body, headers = {} } = {}) {
+  headers = { Accept: "application/json", ...headers };
+  if (state.token) {
+    headers.Authorization = "Bearer " + state.token;
+  }
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+    body = JSON.stringify(body);
+  }
+  const resp = await fetch(path, { method, headers, body });
+  if (resp.ok) {
+    return resp;
+  }
+  let error = {};
+  try {
+    error = await resp.json();
+  } catch {
+    // Not a gateway error, e.g. from a proxy
+  }
+  throw new APIError(resp.status, error);
+}
+
+async function apiJSON(method, path, options) {
+  const resp = await api(method, path, options);
+  return resp.status === 204 ? null : resp.json();
+}
+
+function dbPath(database, ...rest) {
+  return "/v1/db/" + encodeURIComponent(database) + "/" + rest.join("/");
+}
+
+function keyPath(key) {
+  return dbPath(state.database, "keys", encodeURIComponent(key));
+}
+
+function show(message, isError = false) {
+  $("status").textContent = message;
+  $("status").className = isError ? "error" : "";
+}
+
+function fail(err) {
+  if (err.status === 401) {
+    show("Sign in with a bearer token: " + err.message, true);
+    $("token").focus();
+    return;
+  }
+  show(err.message, true);
+}
+
+function el(tag, className, text) {
+  const e = document.createElement(tag);
+  if (className) {
+    e.className = className;
+  }
+  if (text !== undefined) {
+    e.textContent = text;
+  }
+  return e;
+}
+
+function row(...cells) {
+  const tr = el("tr");
+  for (const cell of cells) {
+    const td = el("td");
+    td.append(cell ?? "");
+    tr.append(td);
+  }
+  return tr;
+}
+
+// Values
+
+const utf8 = new TextDecoder("utf-8", { fatal: true });
+
+function decodeBase64(s) {
+  return Uint8Array.from(atob(s || ""), (c) => c.charCodeAt(0));
+}
+
+function encodeBase64(bytes) {
+  let bin = "";
+  for (const b of bytes) {
+    bin += String.fromCharCode(b);
+  }
+  return btoa(bin);
+}
+
+// guessFormat picks the format a value is shown in
+function guessFormat(bytes) {
+  let text;
+  try {
+    text = utf8.decode(bytes);
+  } catch {
+    return "hex";
+  }
+  if (/^\s*[[{]/.test(text)) {
+    try {
+      JSON.parse(text);
+      return "json";
+    } catch {
+      // Text that only looks like JSON
+    }
+  }
+  return "text";
+}
+
+function formatValue(bytes, format) {
+  switch (format) {
+    case "text":
+      return utf8.decode(bytes);
+    case "json":
+      return JSON.stringify(JSON.parse(utf8.decode(bytes)), null, 2);
+    case "hex":
+      return Array.from(bytes, (b) => b.toString(16).padStart(2, "0")).join(" ");
+    case "base64":
+      return encodeBase64(bytes);
+  }
+}
+
+// parseValue returns the bytes of a value edited in format. JSON is
+// validated and stored as typed.
+function parseValue(text, format) {
+  switch (format) {
+    case "text":
+      return new TextEncoder().encode(text);
+    case "json":
+      JSON.parse(text);
+      return new TextEncoder().encode(text);
+    case "hex": {
+      const digits = text.replace(/\s+/g, "");
+      if (!/^([0-9a-fA-F]{2})*$/.test(digits)) {
+        throw new Error("expected pairs of hex digits");
+      }
+      return Uint8Array.from(digits.match(/../g) || [], (h) => parseInt(h, 16));
+    }
+    case "base64":
+      return decodeBase64(text.replace(/\s+/g, ""));
+  }
+}
+
+function formatBytes(n) {
+  const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+  let i = 0;
+  for (n = Number(n); n >= 1024 && i < units.length - 1; i++) {
+    n /= 1024;
+  }
+  return (i === 0 ? n : n.toFixed(1)) + " " + units[i];
+}
+
+// Databases
+
+async function loadDatabases() {
+  try {
+    const resp = await apiJSON("GET", "/v1/databases");
+    const list = $("databases");
+    list.replaceChildren();
+    for (const db of resp.databases) {
+      const a = el("a", db.name === state.database ? "active" : "", db.name);
+      a.href = "#db/" + encodeURIComponent(db.name);
+      const li = el("li");
+      li.append(a);
+      if (db.alias_target) {
+        li.append(el("span", "note", "→ " + db.alias_target));
+      } else if (!db.open) {
+        li.append(el("span", "note", "closed"));
+      }
+      list.append(li);
+    }
+  } catch (err) {
+    if (err.status === 403) {
+      // Listing needs read access to every database; others can still be
+      // opened by name
+      $("databases").replaceChildren(el("li", "note", "Not allowed to list databases"));
+      return;
+    }
+    fail(err);
+  }
+}
+
+function openDatabase(name) {
+  if (name !== state.database) {
+    state.database = name;
+    state.prefix = "";
+    $("prefix").value = "";
+    $("editor").hidden = true;
+  }
+  $("db-title").textContent = name;
+  for (const a of $("databases").querySelectorAll("a")) {
+    a.classList.toggle("active", a.textContent === name);
+  }
+  showSection("database");
+  selectTab("keys");
+  browse();
+}
+
+function selectTab(tab) {
+  for (const button of document.querySelectorAll(".tabs button")) {
+    button.classList.toggle("active", button.dataset.tab === tab);
+  }
+  $("tab-keys").hidden = tab !== "keys";
+  $("tab-stats").hidden = tab !== "stats";
+  if (tab === "stats") {
+    loadStats();
+  }
+}
+
+// Keys
+
+function browse() {
+  state.prefix = $("prefix").value;
+  state.pageSize = Number($("page-size").value);
+  state.starts = [""];
+  state.page = 0;
+  loadPage();
+}
+
+// loadPage lists the keys of the current page, asking for one more key
+// than fits to learn where the next page starts
+async function loadPage() {
+  const params = new URLSearchParams({ keys_only: "true", limit: String(state.pageSize + 1) });
+  if (state.prefix) {
+    params.set("prefix", state.prefix);
+  }
+  if (state.starts[state.page]) {
+    params.set("start", state.starts[state.page]);
+  }
+  try {
+    const resp = await api("GET", dbPath(state.database, "keys") + "?" + params);
+    const lines = (await resp.text()).split("\n").filter(Boolean).map((line) => JSON.parse(line));
+    const failed = lines.find((line) => "error" in line);
+    if (failed) {
+      throw new APIError(500, failed);
+    }
+
+    const items = lines.slice(0, state.pageSize);
+    const body = $("keys").tBodies[0];
+    body.replaceChildren();
+    for (const item of items) {
+      const a = el("a", item.key === state.key ? "active" : "", item.key);
+      a.href = "#";
+      a.addEventListener("click", (event) => {
+        event.preventDefault();
+        openKey(item.key);
+      });
+      body.append(row(a, String(item.version)));
+    }
+    if (items.length === 0) {
+      body.append(row(el("span", "note", "No keys")));
+    }
+
+    state.starts.length = state.page + 1;
+    if (lines.length > state.pageSize) {
+      state.starts.push(lines[state.pageSize].key);
+    }
+    $("prev").disabled = state.page === 0;
+    $("next").disabled = state.starts.length === state.page + 1;
+    $("page").textContent = `Page ${state.page + 1}`;
+    show("");
+  } catch (err) {
+    fail(err);
+  }
+}
+
+async function openKey(key) {
+  try {
+    const resp = await apiJSON("GET", keyPath(key));
+    state.key = key;
+    state.version = resp.version;
+    state.value = decodeBase64(resp.value);
+    state.format = guessFormat(state.value);
+    renderEditor();
+    for (const a of $("keys").querySelectorAll("a")) {
+      a.classList.toggle("active", a.textContent === key);
+    }
+    show("");
+  } catch (err) {
+    if (err.status === 404) {
+      show(`Key ${key} no longer exists`, true);
+      loadPage();
+      return;
+    }
+    fail(err);
+  }
+}
+
+function newKey() {
+  state.key = null;
+  state.version = 0;
+  state.value = new Uint8Array();
+  state.format = "text";
+  renderEditor();
+  $("key").focus();
+}
+
+function renderEditor() {
+  const isNew = state.key === null;
+  $("editor").hidden = false;
+  $("key").value = isNew ? "" : state.key;
+  $("key").readOnly = !isNew;
+  $("version").textContent = isNew ? "New key" : `Version ${state.version}, ${formatBytes(state.value.length)}`;
+  $("format").value = state.format;
+  $("value").value = formatValue(state.value, state.format);
+  $("reload").disabled = isNew;
+  $("delete").disabled = isNew;
+}
+
+function changeFormat() {
+  const format = $("format").value;
+  try {
+    const bytes = parseValue($("value").value, state.format);
+    $("value").value = formatValue(bytes, format);
+    state.format = format;
+  } catch (err) {
+    show(`Cannot show the value as ${format}: ${err.message}`, true);
+    $("format").value = state.format;
+  }
+}
+
+// save writes the edited value, on the condition that the key still has
+// the version loaded so concurrent changes are not overwritten
+async function save(event) {
+  event.preventDefault();
+  let value;
+  try {
+    value = parseValue($("value").value, state.format);
+  } catch (err) {
+    show(`Invalid ${state.format} value: ${err.message}`, true);
+    return;
+  }
+  const isNew = state.key === null;
+  const key = isNew ? $("key").value : state.key;
+  const headers = isNew ? { "If-None-Match": "*" } : { "If-Match": `"${state.version}"` };
+  try {
+    const resp = await apiJSON("PUT", keyPath(key), { body: { value: encodeBase64(value) }, headers });
+    state.key = key;
+    state.version = resp.version;
+    state.value = value;
+    renderEditor();
+    show(`Saved ${key} at version ${resp.version}`);
+    if (isNew) {
+      loadPage();
+    }
+  } catch (err) {
+    if (err.status === 412) {
+      if (isNew) {
+        show(`${key} already exists`, true);
+      } else if (err.condition?.found) {
+        show(`${key} was changed meanwhile, now at version ${err.condition.current_version}; reload to edit the current value`, true);
+      } else {
+        show(`${key} was deleted meanwhile`, true);
+      }
+      return;
+    }
+    fail(err);
+  }
+}
+
+async function deleteKey() {
+  const key = state.key;
+  if (!confirm(`Delete ${key} from ${state.database}?`)) {
+    return;
+  }
+  try {
+    await api("DELETE", keyPath(key));
+    state.key = null;
+    $("editor").hidden = true;
+    show(`Deleted ${key}`);
+    loadPage();
+  } catch (err) {
+    fail(err);
+  }
+}
+
+// Statistics
+
+// textProperties are shown as preformatted reports rather than in the table
+const textProperties = ["rocksdb.stats", "rocksdb.levelstats"];
+
+async function loadStats() {
+  try {
+    const resp = await apiJSON("GET", dbPath(state.database, "stats"));
+    const body = $("properties").tBodies[0];
+    body.replaceChildren();
+    const text = $("stats-text");
+    text.replaceChildren();
+    for (const name of Object.keys(resp.properties).sort()) {
+      const value = resp.properties[name];
+      if (textProperties.includes(name)) {
+        text.append(el("h3", "", name), el("pre", "", value));
+      } else if (/(size|usage|bytes|mem-tables)$/.test(name)) {
+        body.append(row(name, `${formatBytes(value)} (${value})`));
+      } else {
+        body.append(row(name, value));
+      }
+    }
+    show("");
+  } catch (err) {
+    fail(err);
+  }
+}
+
+// Jobs
+
+async function loadJobs() {
+  try {
+    const resp = await apiJSON("GET", "/v1/jobs");
+    const body = $("job-list").tBodies[0];
+    body.replaceChildren();
+    for (const job of resp.jobs) {
+      const started = job.started ? new Date(job.started).toLocaleString() : "";
+      const finished = job.finished ? new Date(job.finished).toLocaleString() : "";
+      body.append(row(job.id, job.kind, job.database_name, el("span", "state " + job.state, job.state), String(job.processed), started, finished, job.error));
+    }
+    if (resp.jobs.length === 0) {
+      body.append(row(el("span", "note", "No jobs")));
+    }
+    show("");
+  } catch (err) {
+    fail(err);
+  }
+}
+
+// Navigation
+
+function showSection(id) {
+  for (const section of ["empty", "database", "jobs"]) {
+    $(section).hidden = section !== id;
+  }
+}
+
+function route() {
+  clearInterval(jobsTimer);
+  const hash = decodeURIComponent(location.hash.slice(1));
+  if (hash === "jobs") {
+    showSection("jobs");
+    loadJobs();
+    jobsTimer = setInterval(loadJobs, 5000);
+  } else if (hash.startsWith("db/")) {
+    openDatabase(hash.slice(3));
+  } else {
+    showSection("empty");
+  }
+}
+
+function renderLogin() {
+  $("token").hidden = !!state.token;
+  $("login").querySelector("button[type=submit]").hidden = !!state.token;
+  $("logout").hidden = !state.token;
+}
+
+function signIn(event) {
+  event.preventDefault();
+  state.token = $("token").value.trim();
+  $("token").value = "";
+  sessionStorage.setItem("token", state.token);
+  renderLogin();
+  show("");
+  loadDatabases();
+  route();
+}
+
+function signOut() {
+  state.token = "";
+  sessionStorage.removeItem("token");
+  renderLogin();
+  location.hash = "";
+  $("databases").replaceChildren();
+  show("Signed out");
+}
+
+$("login").addEventListener("submit", signIn);
+$("logout").addEventListener("click", signOut);
+$("refresh-dbs").addEventListener("click", loadDatabases);
+$("open-db").addEventListener("submit", (event) => {
+  event.preventDefault();
+  location.hash = "db/" + encodeURIComponent($("db-name").value);
+});
+$("refresh-jobs").addEventListener("click", loadJobs);
+$("refresh-stats").addEventListener("click", loadStats);
+$("browse").addEventListener("submit", (event) => {
+  event.preventDefault();
+  browse();
+});
+$("prev").addEventListener("click", () => {
+  state.page--;
+  loadPage();
+});
+$("next").addEventListener("click", () => {
+  state.page++;
+  loadPage();
+});
+$("new-key").addEventListener("click", newKey);
+$("editor").addEventListener("submit", save);
+$("reload").addEventListener("click", () => openKey(state.key));
+$("delete").addEventListener("click", deleteKey);
+$("format").addEventListener("change", changeFormat);
+for (const button of document.querySelectorAll(".tabs button")) {
+  button.addEventListener("click", () => selectTab(button.dataset.tab));
+}
+window.addEventListener("hashchange", route);
+
+renderLogin();
+loadDatabases();
+route();
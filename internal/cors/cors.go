@@ -0,0 +1,67 @@
+// Package cors lets browser pages from other origins call the HTTP
+// endpoints of the server
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	// allowedMethods are the methods of the REST API and gRPC-Web
+	allowedMethods = "GET, PUT, POST, DELETE"
+	// exposedHeaders are the response headers scripts may read
+	exposedHeaders = "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin, ETag, Retry-After"
+	// maxAge is how long browsers may cache a preflight response, in
+	// seconds
+	maxAge = "600"
+)
+
+// Handler wraps an http.Handler, answering preflight requests and allowing
+// cross-origin requests from the given origins, e.g.
+// "https://console.example.com". The origin "*" allows any origin.
+type Handler struct {
+	origins []string
+	next    http.Handler
+}
+
+// New returns a handler allowing origins to call next
+func New(origins []string, next http.Handler) *Handler {
+	return &Handler{origins: origins, next: next}
+}
+
+// allowed reports whether requests from origin are allowed
+func (h *Handler) allowed(origin string) bool {
+	return slices.Contains(h.origins, "*") || slices.ContainsFunc(h.origins, func(o string) bool {
+		return strings.EqualFold(o, origin)
+	})
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	preflight := origin != "" && r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if origin == "" || !h.allowed(origin) {
+		if preflight {
+			// Without the allow headers the browser refuses the request
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if preflight {
+		w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		w.Header().Set("Access-Control-Max-Age", maxAge)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+	h.next.ServeHTTP(w, r)
+}
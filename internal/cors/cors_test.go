@@ -0,0 +1,78 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rocksdb-service/internal/cors"
+)
+
+func TestPreflight(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("preflight request for %s reached the handler", r.Header.Get("Origin"))
+	})
+	h := cors.New([]string{"https://console.example.com"}, next)
+
+	tests := []struct {
+		origin      string
+		wantAllowed bool
+	}{
+		{"https://console.example.com", true},
+		{"https://CONSOLE.example.com", true},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodOptions, "/v1/db/db/keys/k", nil)
+		r.Header.Set("Origin", tt.origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		r.Header.Set("Access-Control-Request-Headers", "authorization, if-match")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: status %d, want %d", tt.origin, w.Code, http.StatusNoContent)
+		}
+		header := w.Header()
+		if !tt.wantAllowed {
+			if got := header.Get("Access-Control-Allow-Origin"); got != "" {
+				t.Errorf("%s: Access-Control-Allow-Origin = %q, want none", tt.origin, got)
+			}
+			continue
+		}
+		want := map[string]string{
+			"Access-Control-Allow-Origin":  tt.origin,
+			"Access-Control-Allow-Methods": "GET, PUT, POST, DELETE",
+			"Access-Control-Allow-Headers": "authorization, if-match",
+			"Access-Control-Max-Age":       "600",
+			"Vary":                         "Origin",
+		}
+		for name, value := range want {
+			if got := header.Get(name); got != value {
+				t.Errorf("%s: %s = %q, want %q", tt.origin, name, got, value)
+			}
+		}
+	}
+}
+
+func TestCrossOriginRequest(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := cors.New([]string{"*"}, next)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/db/db/keys/k", nil)
+	r.Header.Set("Origin", "https://any.example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://any.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the origin", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got == "" {
+		t.Error("no Access-Control-Expose-Headers")
+	}
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// aliasFile is the file under the base directory persisting alias targets
+const aliasFile = ".aliases.json"
+
+// alias is a stable database name pointing at a physical database
+type alias struct {
+	Target string `json:"target"`
+	// History holds previous targets, most recent first
+	History []string `json:"history,omitempty"`
+}
+
+// resolve returns the physical database name for name. Must be called with
+// m.mu held.
+func (m *DBManager) resolve(name string) string {
+	if a, exists := m.aliases[name]; exists {
+		return a.Target
+	}
+	return name
+}
+
+// SwapAlias atomically points name at the physical database target and
+// returns the previous target, if any. The previous instance is closed once
+// its in-flight requests finish and is retained for rollback;
+// versions beyond the configured retention are destroyed.
+func (m *DBManager) SwapAlias(ctx context.Context, name, target string) (string, error) {
+	if name == "" || target == "" {
+		return "", fmt.Errorf("alias and target names cannot be empty")
+	}
+	if name == target {
+		return "", fmt.Errorf("alias %s cannot point to itself", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.aliases[target]; exists {
+		return "", fmt.Errorf("target %s is an alias", target)
+	}
+	if _, exists := m.dbs[name]; exists {
+		return "", fmt.Errorf("alias %s conflicts with an open database", name)
+	}
+	if m.backend.exists(name) {
+		return "", fmt.Errorf("alias %s conflicts with an existing database", name)
+	}
+
+	// Open the new target up front so a bad target leaves the alias intact
+	e, exists := m.dbs[target]
+	if !exists {
+		var err error
+		if e, err = m.open(ctx, target); err != nil {
+			return "", err
+		}
+	}
+	e.retired = false
+	e.destroy = false
+
+	a, exists := m.aliases[name]
+	if !exists {
+		a = &alias{}
+	}
+	prev := a.Target
+	if prev == target {
+		return prev, nil
+	}
+
+	next := &alias{Target: target}
+	if prev != "" {
+		next.History = append(next.History, prev)
+	}
+	for _, h := range a.History {
+		if h != target {
+			next.History = append(next.History, h)
+		}
+	}
+	var pruned []string
+	if len(next.History) > m.opts.AliasRetention {
+		pruned = next.History[m.opts.AliasRetention:]
+		next.History = next.History[:m.opts.AliasRetention]
+	}
+
+	m.aliases[name] = next
+	if err := m.saveAliases(); err != nil {
+		m.aliases[name] = a
+		if !exists {
+			delete(m.aliases, name)
+		}
+		return "", err
+	}
+
+	if prev != "" && !m.isTarget(prev) {
+		m.retire(prev, false)
+	}
+	for _, p := range pruned {
+		if !m.isTarget(p) && !m.isRetained(p) {
+			m.retire(p, true)
+		}
+	}
+	return prev, nil
+}
+
+// isTarget reports whether any alias currently points at name. Must be
+// called with m.mu held.
+func (m *DBManager) isTarget(name string) bool {
+	for _, a := range m.aliases {
+		if a.Target == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetained reports whether any alias keeps name for rollback. Must be
+// called with m.mu held.
+func (m *DBManager) isRetained(name string) bool {
+	for _, a := range m.aliases {
+		for _, h := range a.History {
+			if h == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadAliases reads persisted aliases from the base directory. Aliases of
+// in-memory databases are not persisted, as the databases are lost with
+// the process.
+func (m *DBManager) loadAliases() error {
+	if m.opts.Backend == BackendMemory {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(m.baseDir, aliasFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read aliases: %w", err)
+	}
+	if err := json.Unmarshal(data, &m.aliases); err != nil {
+		return fmt.Errorf("failed to parse aliases: %w", err)
+	}
+	return nil
+}
+
+// saveAliases persists aliases to the base directory. Must be called with
+// m.mu held.
+func (m *DBManager) saveAliases() error {
+	if m.opts.Backend == BackendMemory {
+		return nil
+	}
+	data, err := json.MarshalIndent(m.aliases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode aliases: %w", err)
+	}
+	if err := os.MkdirAll(m.baseDir, 0755); err != nil {
+		return fmt.Errorf("failed to create base directory: %w", err)
+	}
+
+	// Write to a temporary file and rename so a crash never leaves a
+	// partially written alias file behind
+	path := filepath.Join(m.baseDir, aliasFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+	return nil
+}
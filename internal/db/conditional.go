@@ -0,0 +1,82 @@
+//go:build cgo
+
+package db
+
+import (
+	"bytes"
+	"context"
+)
+
+// CompareAndSwap replaces the value of key with value if its current value
+// equals expected, and returns the new version
+func (r *RocksDB) CompareAndSwap(ctx context.Context, key string, expected, value []byte) (uint64, error) {
+	unlock := r.locks.lock(key)
+	defer unlock()
+
+	current, version, exists, err := r.GetVersioned(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if !exists || !bytes.Equal(current, expected) {
+		return 0, &ConditionError{Value: current, Version: version, Found: exists}
+	}
+	return r.put(ctx, key, value)
+}
+
+// PutIfAbsent stores value under key if the key does not exist, and returns
+// the new version
+func (r *RocksDB) PutIfAbsent(ctx context.Context, key string, value []byte) (uint64, error) {
+	unlock := r.locks.lock(key)
+	defer unlock()
+
+	current, version, exists, err := r.GetVersioned(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, &ConditionError{Value: current, Version: version, Found: true}
+	}
+	return r.put(ctx, key, value)
+}
+
+// DeleteIfEquals deletes key if its current value equals expected
+func (r *RocksDB) DeleteIfEquals(ctx context.Context, key string, expected []byte) error {
+	unlock := r.locks.lock(key)
+	defer unlock()
+
+	current, version, exists, err := r.GetVersioned(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists || !bytes.Equal(current, expected) {
+		return &ConditionError{Value: current, Version: version, Found: exists}
+	}
+	_, err = r.commit(ctx, []Mutation{{Key: key, Delete: true}})
+	return err
+}
+
+// Increment applies increments to counters in a single write batch and
+// returns their new values. Either all increments are applied or none.
+func (r *RocksDB) Increment(ctx context.Context, increments []Increment) ([]Counter, error) {
+	keys := make([]string, len(increments))
+	for i, inc := range increments {
+		keys[i] = inc.Key
+	}
+	unlock := r.locks.lock(keys...)
+	defer unlock()
+
+	mutations, counters, err := applyIncrements(increments, func(key string) ([]byte, uint64, bool, error) {
+		return r.GetVersioned(ctx, key)
+	})
+	if err != nil || len(mutations) == 0 {
+		return counters, err
+	}
+	versions, err := r.commit(ctx, mutations)
+	if err != nil {
+		return nil, err
+	}
+	for i := range counters {
+		counters[i].Version = versions[i]
+	}
+	return counters, nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// counterSize is the size of a stored counter, a big-endian two's
+// complement int64
+const counterSize = 8
+
+// ErrNotCounter is returned when incrementing or reading a key as a counter
+// whose value is not 8 bytes long
+var ErrNotCounter = errors.New("value is not a counter")
+
+// Increment adds Delta to the counter Key. Min and Max, if set, bound the
+// new value.
+type Increment struct {
+	Key      string
+	Delta    int64
+	Min, Max *int64
+}
+
+// Counter is the value of a counter after an increment
+type Counter struct {
+	Key     string
+	Value   int64
+	Version uint64
+}
+
+// CounterRangeError is returned by increments that would take a counter
+// out of the int64 range or its bounds. It carries the value the increment
+// was applied to.
+type CounterRangeError struct {
+	Key     string
+	Delta   int64
+	Value   int64
+	Version uint64
+	Found   bool
+}
+
+func (e *CounterRangeError) Error() string {
+	return fmt.Sprintf("counter %q out of range: cannot add %d to %d", e.Key, e.Delta, e.Value)
+}
+
+// EncodeCounter returns the stored form of a counter value
+func EncodeCounter(value int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(value))
+}
+
+// DecodeCounter returns the value of a stored counter
+func DecodeCounter(value []byte) (int64, error) {
+	if len(value) != counterSize {
+		return 0, ErrNotCounter
+	}
+	return int64(binary.BigEndian.Uint64(value)), nil
+}
+
+// applyIncrements returns the mutations storing the counters after
+// increments, and their new values, reading current values with get.
+// Increments of a key see the earlier increments of the same key. Versions
+// are left for the caller to fill in once the mutations are committed.
+func applyIncrements(increments []Increment, get func(key string) ([]byte, uint64, bool, error)) ([]Mutation, []Counter, error) {
+	type state struct {
+		value   int64
+		version uint64
+		found   bool
+	}
+	pending := make(map[string]state, len(increments))
+
+	mutations := make([]Mutation, len(increments))
+	counters := make([]Counter, len(increments))
+	for i, inc := range increments {
+		cur, ok := pending[inc.Key]
+		if !ok {
+			raw, version, found, err := get(inc.Key)
+			if err != nil {
+				return nil, nil, err
+			}
+			cur = state{version: version, found: found}
+			if found {
+				if cur.value, err = DecodeCounter(raw); err != nil {
+					return nil, nil, fmt.Errorf("key %q: %w", inc.Key, err)
+				}
+			}
+		}
+
+		next := cur.value + inc.Delta
+		overflow := (inc.Delta > 0 && next < cur.value) || (inc.Delta < 0 && next > cur.value)
+		if overflow || (inc.Min != nil && next < *inc.Min) || (inc.Max != nil && next > *inc.Max) {
+			return nil, nil, &CounterRangeError{
+				Key:     inc.Key,
+				Delta:   inc.Delta,
+				Value:   cur.value,
+				Version: cur.version,
+				Found:   cur.found,
+			}
+		}
+
+		pending[inc.Key] = state{value: next, version: cur.version, found: true}
+		mutations[i] = Mutation{Key: inc.Key, Value: EncodeCounter(next)}
+		counters[i] = Counter{Key: inc.Key, Value: next}
+	}
+	return mutations, counters, nil
+}
@@ -1,36 +1,127 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"log"
+	"sort"
 	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"rocksdb-service/internal/tracing"
 )
 
-// DBManager manages multiple RocksDB instances
+var tracer = otel.Tracer("rocksdb-service/internal/db")
+
+// ErrDatabaseNotFound is returned by OpenDB for databases that do not exist
+var ErrDatabaseNotFound = errors.New("database not found")
+
+// Options configures a DBManager
+type Options struct {
+	// Backend is the storage backend of all databases, BackendRocksDB if
+	// empty
+	Backend string
+
+	// AliasRetention is the number of previous alias targets kept on disk
+	// for rollback. Older versions are destroyed when an alias is swapped.
+	AliasRetention int
+
+	// Databases holds per-database options keyed by physical database name.
+	// The "*" entry applies to databases without their own entry, and its
+	// RocksDB options to entries without RocksDB options.
+	Databases map[string]DBOptions
+
+	// Keys decrypts encrypted values in every database
+	Keys KeyProvider
+
+	// BlockCacheSize is the size of the block cache shared by all RocksDB
+	// databases, zero for RocksDB's default per-database cache
+	BlockCacheSize uint64
+	// WriteBufferSize bounds the memory of the memtables of all databases,
+	// stalling writes while it is exceeded. Zero is unlimited.
+	WriteBufferSize uint64
+}
+
+// dbEntry tracks an open database and the requests currently using it
+type dbEntry struct {
+	db   Store
+	refs int32
+
+	// retired entries are closed once the last in-flight request releases
+	// them; destroy additionally removes the database files afterwards.
+	retired bool
+	destroy bool
+}
+
+// backend opens and destroys the physical databases of a DBManager
+type backend interface {
+	// open opens the database name, creating it if it does not exist
+	open(name string, opts DBOptions) (Store, error)
+	// destroy removes the closed database name
+	destroy(name string) error
+	// list returns the names of all databases, open or not
+	list() ([]string, error)
+	exists(name string) bool
+	setBlockCacheSize(size uint64) error
+	close()
+}
+
+// DBManager manages multiple databases
 type DBManager struct {
 	baseDir string
-	dbs     map[string]*RocksDB
+	opts    Options
+	backend backend
+	dbs     map[string]*dbEntry
+	aliases map[string]*alias
+	jobs    *jobs
 	mu      sync.RWMutex
 }
 
 // NewDBManager creates a new database manager
-func NewDBManager(baseDir string) *DBManager {
-	return &DBManager{
+func NewDBManager(baseDir string, opts Options) (*DBManager, error) {
+	m := &DBManager{
 		baseDir: baseDir,
-		dbs:     make(map[string]*RocksDB),
+		opts:    opts,
+		dbs:     make(map[string]*dbEntry),
+		aliases: make(map[string]*alias),
+		jobs:    newJobs(),
+	}
+	if err := m.loadAliases(); err != nil {
+		return nil, err
 	}
+
+	var err error
+	switch opts.Backend {
+	case "", BackendRocksDB:
+		m.backend, err = newRocksDBBackend(baseDir, opts)
+	case BackendMemory:
+		m.backend = newMemoryBackend()
+	default:
+		err = fmt.Errorf("unknown backend %q", opts.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-// GetDB returns an existing database or creates a new one
-func (m *DBManager) GetDB(name string) (*RocksDB, error) {
+// GetDB returns an existing database or creates a new one. Aliases are
+// resolved to their current target. The returned release function must be
+// called once the caller is done with the database.
+func (m *DBManager) GetDB(ctx context.Context, name string) (Store, func(), error) {
 	if name == "" {
-		return nil, fmt.Errorf("database name cannot be empty")
+		return nil, nil, fmt.Errorf("database name cannot be empty")
 	}
 
 	m.mu.RLock()
-	if db, exists := m.dbs[name]; exists {
+	if e, exists := m.dbs[m.resolve(name)]; exists {
+		atomic.AddInt32(&e.refs, 1)
 		m.mu.RUnlock()
-		return db, nil
+		return e.db, m.releaseFunc(e), nil
 	}
 	m.mu.RUnlock()
 
@@ -39,28 +130,268 @@ func (m *DBManager) GetDB(name string) (*RocksDB, error) {
 	defer m.mu.Unlock()
 
 	// Double-check if another goroutine created the database
-	if db, exists := m.dbs[name]; exists {
-		return db, nil
+	target := m.resolve(name)
+	if e, exists := m.dbs[target]; exists {
+		atomic.AddInt32(&e.refs, 1)
+		return e.db, m.releaseFunc(e), nil
 	}
 
-	// Create new database directory
-	dbPath := filepath.Join(m.baseDir, name)
-	db, err := NewRocksDB(dbPath)
+	e, err := m.open(ctx, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	atomic.AddInt32(&e.refs, 1)
+	return e.db, m.releaseFunc(e), nil
+}
+
+// LookupDB returns the database name if it is already open, without
+// opening or creating it. Aliases are resolved to their current target.
+// The returned release function must be called once the caller is done
+// with the database.
+func (m *DBManager) LookupDB(name string) (Store, func(), bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, exists := m.dbs[m.resolve(name)]
+	if !exists {
+		return nil, nil, false
+	}
+	atomic.AddInt32(&e.refs, 1)
+	return e.db, m.releaseFunc(e), true
+}
+
+// OpenDB returns an existing database like GetDB, opening it if needed,
+// but fails with ErrDatabaseNotFound rather than creating it
+func (m *DBManager) OpenDB(ctx context.Context, name string) (Store, func(), error) {
+	if db, release, ok := m.LookupDB(name); ok {
+		return db, release, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target := m.resolve(name)
+	e, exists := m.dbs[target]
+	if !exists {
+		if !m.backend.exists(target) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, name)
+		}
+		var err error
+		if e, err = m.open(ctx, target); err != nil {
+			return nil, nil, err
+		}
+	}
+	atomic.AddInt32(&e.refs, 1)
+	return e.db, m.releaseFunc(e), nil
+}
+
+// open opens the physical database name. Must be called with m.mu held.
+func (m *DBManager) open(ctx context.Context, name string) (_ *dbEntry, err error) {
+	_, span := tracer.Start(ctx, "db.Open", trace.WithAttributes(attribute.String("db.name", name)))
+	defer func() { tracing.End(span, err) }()
+
+	opts := m.dbOptions(name)
+	opts.Keys = m.opts.Keys
+	db, err := m.backend.open(name, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database %s: %w", name, err)
 	}
 
-	m.dbs[name] = db
-	return db, nil
+	e := &dbEntry{db: db}
+	m.dbs[name] = e
+	return e, nil
+}
+
+// dbOptions returns the options of the physical database name. Must be
+// called with m.mu held.
+func (m *DBManager) dbOptions(name string) DBOptions {
+	defaults := m.opts.Databases["*"]
+	opts, ok := m.opts.Databases[name]
+	if !ok {
+		return defaults
+	}
+	if opts.RocksDB == nil {
+		opts.RocksDB = defaults.RocksDB
+	}
+	return opts
+}
+
+// SetRocksDBOptions replaces the RocksDB options of databases, keyed like
+// Options.Databases, and applies them to open databases with SetOptions.
+// Only mutable options can be changed this way; other options take effect
+// when a database is next opened. Options of databases that fail to apply
+// are still used from then on.
+func (m *DBManager) SetRocksDBOptions(options map[string]map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	databases := make(map[string]DBOptions, len(m.opts.Databases))
+	for name, opts := range m.opts.Databases {
+		opts.RocksDB = nil
+		databases[name] = opts
+	}
+	for name, rocksdb := range options {
+		opts := databases[name]
+		opts.RocksDB = rocksdb
+		databases[name] = opts
+	}
+	m.opts.Databases = databases
+
+	var errs []error
+	for name, e := range m.dbs {
+		if err := e.db.SetOptions(m.dbOptions(name).RocksDB); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetBlockCacheSize changes the size of the shared block cache
+func (m *DBManager) SetBlockCacheSize(size uint64) error {
+	return m.backend.setBlockCacheSize(size)
+}
+
+// releaseFunc returns a function dropping one reference to e, closing it
+// if it has been retired in the meantime
+func (m *DBManager) releaseFunc(e *dbEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if atomic.AddInt32(&e.refs, -1) > 0 {
+				return
+			}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.closeIfIdle(e)
+		})
+	}
 }
 
-// Close closes all database instances
+// retire marks the physical database name for closing once idle, and for
+// deletion if destroy is set. Must be called with m.mu held.
+func (m *DBManager) retire(name string, destroy bool) {
+	e, exists := m.dbs[name]
+	if !exists {
+		if destroy {
+			m.destroy(name)
+		}
+		return
+	}
+	e.retired = true
+	e.destroy = e.destroy || destroy
+	m.closeIfIdle(e)
+}
+
+// closeIfIdle closes a retired entry with no in-flight requests. Must be
+// called with m.mu held.
+func (m *DBManager) closeIfIdle(e *dbEntry) {
+	if !e.retired || atomic.LoadInt32(&e.refs) > 0 {
+		return
+	}
+	for name, cur := range m.dbs {
+		if cur != e {
+			continue
+		}
+		delete(m.dbs, name)
+		e.db.Close()
+		if e.destroy {
+			m.destroy(name)
+		}
+		return
+	}
+}
+
+// destroy removes the physical database name. Must be called with m.mu
+// held and the database closed.
+func (m *DBManager) destroy(name string) {
+	if err := m.backend.destroy(name); err != nil {
+		log.Printf("Failed to destroy database %s: %v", name, err)
+	}
+}
+
+// Preload opens the databases with per-database options and the current
+// targets of all aliases, so they are ready before the first request
+func (m *DBManager) Preload(ctx context.Context) error {
+	m.mu.RLock()
+	names := make([]string, 0, len(m.opts.Databases)+len(m.aliases))
+	for name := range m.opts.Databases {
+		if name != "*" {
+			names = append(names, m.resolve(name))
+		}
+	}
+	for _, a := range m.aliases {
+		names = append(names, a.Target)
+	}
+	m.mu.RUnlock()
+
+	for _, name := range names {
+		_, release, err := m.GetDB(ctx, name)
+		if err != nil {
+			return err
+		}
+		release()
+	}
+	return nil
+}
+
+// Failing returns the open databases that have hit a background error,
+// such as a flush or compaction failing on a full disk or corruption. A
+// database stays failing until it is reopened.
+func (m *DBManager) Failing() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var failing []string
+	for name, e := range m.dbs {
+		if e.db.BackgroundErrors() > 0 {
+			failing = append(failing, name)
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// DatabaseInfo describes a database or alias
+type DatabaseInfo struct {
+	Name string
+	// AliasTarget is the database an alias points at, empty for databases
+	AliasTarget string
+	Open        bool
+}
+
+// Databases lists all databases and aliases, sorted by name
+func (m *DBManager) Databases() ([]DatabaseInfo, error) {
+	names, err := m.backend.list()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var infos []DatabaseInfo
+	for _, name := range names {
+		_, open := m.dbs[name]
+		infos = append(infos, DatabaseInfo{Name: name, Open: open})
+	}
+	for name, a := range m.aliases {
+		_, open := m.dbs[a.Target]
+		infos = append(infos, DatabaseInfo{Name: name, AliasTarget: a.Target, Open: open})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// Close stops background jobs and closes all database instances
 func (m *DBManager) Close() {
+	m.jobs.stop()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, db := range m.dbs {
-		db.Close()
+	for _, e := range m.dbs {
+		e.db.Close()
 	}
-	m.dbs = make(map[string]*RocksDB)
+	m.dbs = make(map[string]*dbEntry)
+	m.backend.close()
 }
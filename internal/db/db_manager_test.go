@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newTestDBManager(t *testing.T) *DBManager {
+	t.Helper()
+	m, err := NewDBManager(t.TempDir(), Options{Backend: BackendMemory})
+	if err != nil {
+		t.Fatalf("NewDBManager: %v", err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestLookupDBDoesNotCreate(t *testing.T) {
+	m := newTestDBManager(t)
+
+	if _, _, ok := m.LookupDB("missing"); ok {
+		t.Fatal("LookupDB found a database that was never opened")
+	}
+	infos, err := m.Databases()
+	if err != nil {
+		t.Fatalf("Databases: %v", err)
+	}
+	if len(infos) != 0 {
+		t.Fatalf("LookupDB created databases: %+v", infos)
+	}
+
+	opened, release, err := m.GetDB(context.Background(), "db")
+	if err != nil {
+		t.Fatalf("GetDB: %v", err)
+	}
+	release()
+	found, release, ok := m.LookupDB("db")
+	if !ok || found != opened {
+		t.Fatal("LookupDB did not return the open database")
+	}
+	release()
+}
+
+func TestOpenDBDoesNotCreate(t *testing.T) {
+	m := newTestDBManager(t)
+	ctx := context.Background()
+
+	if _, _, err := m.OpenDB(ctx, "missing"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("OpenDB of a missing database: %v, want ErrDatabaseNotFound", err)
+	}
+	if infos, _ := m.Databases(); len(infos) != 0 {
+		t.Fatalf("OpenDB created databases: %+v", infos)
+	}
+
+	opened, release, err := m.GetDB(ctx, "db")
+	if err != nil {
+		t.Fatalf("GetDB: %v", err)
+	}
+	release()
+	found, release, err := m.OpenDB(ctx, "db")
+	if err != nil || found != opened {
+		t.Fatalf("OpenDB = %v, %v; want the existing database", found, err)
+	}
+	release()
+}
+
+func TestAliasRetention(t *testing.T) {
+	m, err := NewDBManager(t.TempDir(), Options{Backend: BackendMemory, AliasRetention: 1})
+	if err != nil {
+		t.Fatalf("NewDBManager: %v", err)
+	}
+	defer m.Close()
+	ctx := context.Background()
+	swap := func(target string) {
+		t.Helper()
+		if _, err := m.SwapAlias(ctx, "alias", target); err != nil {
+			t.Fatalf("SwapAlias(%s): %v", target, err)
+		}
+	}
+	// state reports whether name is open and whether it still exists
+	state := func(name string) (open, exists bool) {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		_, open = m.dbs[name]
+		return open, m.backend.exists(name)
+	}
+
+	swap("v1")
+	db, release, err := m.GetDB(ctx, "alias")
+	if err != nil {
+		t.Fatalf("GetDB: %v", err)
+	}
+	if _, err := db.Put(ctx, "k", []byte("v")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// v1 is retained for rollback and stays open while in use
+	swap("v2")
+	if open, exists := state("v1"); !open || !exists {
+		t.Errorf("v1 after one swap: open %v, exists %v; want both", open, exists)
+	}
+	// v1 is beyond the retention, but is only destroyed once released
+	swap("v3")
+	if open, exists := state("v1"); !open || !exists {
+		t.Errorf("pruned v1 in use: open %v, exists %v; want both", open, exists)
+	}
+	if _, found, err := db.Get(ctx, "k"); err != nil || !found {
+		t.Errorf("Get from a pruned database in use = %v, %v; want the key", found, err)
+	}
+	release()
+	if open, exists := state("v1"); open || exists {
+		t.Errorf("pruned v1 after its release: open %v, exists %v; want neither", open, exists)
+	}
+
+	// v2, retained and idle, is closed but kept until pruned
+	if open, exists := state("v2"); open || !exists {
+		t.Errorf("retained v2: open %v, exists %v; want closed but existing", open, exists)
+	}
+	swap("v4")
+	if _, exists := state("v2"); exists {
+		t.Error("pruned idle v2 still exists")
+	}
+	if open, exists := state("v3"); open || !exists {
+		t.Errorf("retained v3: open %v, exists %v; want closed but existing", open, exists)
+	}
+}
@@ -0,0 +1,320 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/linxGnu/grocksdb"
+)
+
+// valueCipher encrypts values with AES-GCM. The key and envelope header are
+// authenticated, so a value cannot be moved to another key or version.
+type valueCipher struct {
+	keys KeyProvider
+
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD
+}
+
+func newValueCipher(keys KeyProvider) *valueCipher {
+	return &valueCipher{keys: keys, aeads: make(map[string]cipher.AEAD)}
+}
+
+// aead returns the cipher for a key ID, caching it since keys never change
+// under an ID
+func (c *valueCipher) aead(id string, key []byte) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if aead, ok := c.aeads[id]; ok {
+		return aead, nil
+	}
+	if key == nil {
+		var err error
+		if key, err = c.keys.Key(id); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.aeads[id] = aead
+	return aead, nil
+}
+
+func additionalData(key string, version uint64, timestamp int64) []byte {
+	ad := binary.BigEndian.AppendUint64(nil, version)
+	ad = binary.BigEndian.AppendUint64(ad, uint64(timestamp))
+	return append(ad, key...)
+}
+
+// seal encrypts value with the current key and returns its envelope
+func (c *valueCipher) seal(key string, version uint64, timestamp int64, value []byte) ([]byte, error) {
+	id, k, err := c.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(id, k)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, value, additionalData(key, version, timestamp))
+	return encodeSealed(version, timestamp, id, sealed), nil
+}
+
+// open decrypts the value of an encrypted record
+func (c *valueCipher) open(key string, rec record) ([]byte, error) {
+	aead, err := c.aead(rec.keyID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(rec.value) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted value of %q is truncated", key)
+	}
+	nonce, sealed := rec.value[:aead.NonceSize()], rec.value[aead.NonceSize():]
+	value, err := aead.Open(nil, nonce, sealed, additionalData(key, rec.version, rec.timestamp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value of %q: %w", key, err)
+	}
+	return value, nil
+}
+
+// seal returns the envelope of value, encrypted if the database encrypts
+// new values
+func (r *RocksDB) seal(key string, version uint64, timestamp int64, value []byte) ([]byte, error) {
+	if !r.encrypt {
+		return encodeValue(version, timestamp, value), nil
+	}
+	return r.cipher.seal(key, version, timestamp, value)
+}
+
+// open returns the plaintext value of a record
+func (r *RocksDB) open(key string, rec record) ([]byte, error) {
+	if rec.keyID == "" {
+		return rec.value, nil
+	}
+	if r.cipher == nil {
+		return nil, ErrNoKeys
+	}
+	return r.cipher.open(key, rec)
+}
+
+// rotateBatchSize is the number of keys re-encrypted per write batch
+const rotateBatchSize = 100
+
+// RotateKey re-encrypts every value, including history, that is not
+// encrypted with the current key, reporting the number of keys examined
+// to progress. Values keep their version and commit timestamp.
+func (r *RocksDB) RotateKey(ctx context.Context, progress func(uint64)) error {
+	if !r.encrypt {
+		return fmt.Errorf("database is not encrypted")
+	}
+
+	snap := r.db.NewSnapshot()
+	defer r.db.ReleaseSnapshot(snap)
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snap)
+
+	var processed uint64
+	var batch []string
+	it := r.db.NewIterator(ro)
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := it.Key()
+		batch = append(batch, string(key.Data()))
+		key.Free()
+
+		if len(batch) == rotateBatchSize {
+			if err := r.rotateBatch(batch); err != nil {
+				return err
+			}
+			processed += uint64(len(batch))
+			progress(processed)
+			batch = batch[:0]
+		}
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	if err := r.rotateBatch(batch); err != nil {
+		return err
+	}
+	processed += uint64(len(batch))
+	progress(processed)
+
+	if r.history == nil {
+		return nil
+	}
+	return r.rotateHistory(ctx, ro, processed, progress)
+}
+
+// rotateBatch re-encrypts the current values of keys, holding their locks
+// so concurrent writes are not overwritten
+func (r *RocksDB) rotateBatch(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	unlock := r.locks.lock(keys...)
+	defer unlock()
+
+	currentID, _, err := r.cipher.keys.CurrentKey()
+	if err != nil {
+		return err
+	}
+
+	wb := grocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	for _, key := range keys {
+		raw, exists, err := r.getRaw(r.ro, key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		sealed, ok, err := r.reseal(key, raw, currentID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			wb.Put([]byte(key), sealed)
+		}
+	}
+
+	if wb.Count() == 0 {
+		return nil
+	}
+	if err := r.db.Write(r.wo, wb); err != nil {
+		return fmt.Errorf("failed to write batch: %w", err)
+	}
+	return nil
+}
+
+// rotateHistory re-encrypts history entries. Entries are not modified once
+// written, but the compaction filter drops them once they leave the
+// retention window, possibly after the snapshot iterated was taken. Entries
+// out of the window are skipped, and others are only rewritten if they
+// still exist; one dropped in between is dropped again by the next
+// compaction and is never read, as reads before the window are rejected.
+func (r *RocksDB) rotateHistory(ctx context.Context, ro *grocksdb.ReadOptions, processed uint64, progress func(uint64)) error {
+	currentID, _, err := r.cipher.keys.CurrentKey()
+	if err != nil {
+		return err
+	}
+
+	wb := grocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	flush := func() error {
+		if wb.Count() == 0 {
+			return nil
+		}
+		if err := r.db.Write(r.wo, wb); err != nil {
+			return fmt.Errorf("failed to write batch: %w", err)
+		}
+		wb.Clear()
+		return nil
+	}
+
+	cutoff := time.Now().Add(-r.historyRetention).UnixNano()
+	it := r.db.NewIteratorCF(ro, r.history)
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hkey := it.Key()
+		hkeyData := append([]byte(nil), hkey.Data()...)
+		hkey.Free()
+		value := it.Value()
+		raw := append([]byte(nil), value.Data()...)
+		value.Free()
+
+		key, ok := historyUserKey(hkeyData)
+		if !ok {
+			continue
+		}
+		if historySupersededAt(hkeyData) < cutoff {
+			continue
+		}
+		sealed, ok, err := r.reseal(key, raw, currentID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			exists, err := r.historyExists(hkeyData)
+			if err != nil {
+				return err
+			}
+			if exists {
+				wb.PutCF(r.history, hkeyData, sealed)
+			}
+		}
+
+		processed++
+		if processed%rotateBatchSize == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			progress(processed)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	progress(processed)
+	return nil
+}
+
+// historyExists reports whether the history entry hkey has not been
+// dropped, reading the latest state rather than a snapshot
+func (r *RocksDB) historyExists(hkey []byte) (bool, error) {
+	slice, err := r.db.GetCF(r.ro, r.history, hkey)
+	if err != nil {
+		return false, fmt.Errorf("failed to get history entry: %w", err)
+	}
+	defer slice.Free()
+	return slice.Exists(), nil
+}
+
+// reseal re-encrypts a stored envelope with the current key, reporting
+// false if it already uses currentID
+func (r *RocksDB) reseal(key string, raw []byte, currentID string) ([]byte, bool, error) {
+	rec := decodeValue(raw)
+	if rec.keyID == currentID {
+		return nil, false, nil
+	}
+	plain, err := r.open(key, rec)
+	if err != nil {
+		return nil, false, err
+	}
+	sealed, err := r.cipher.seal(key, rec.version, rec.timestamp, plain)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encrypt value: %w", err)
+	}
+	return sealed, true, nil
+}
@@ -0,0 +1,145 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// testKeyProvider is a KeyProvider whose keys can be changed by tests
+type testKeyProvider struct {
+	mu      sync.Mutex
+	current string
+	keys    map[string][]byte
+}
+
+// add adds a random key and makes it current
+func (p *testKeyProvider) add(t *testing.T, id string) {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys == nil {
+		p.keys = make(map[string][]byte)
+	}
+	p.keys[id] = key
+	p.current = id
+}
+
+func (p *testKeyProvider) remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.keys, id)
+}
+
+func (p *testKeyProvider) CurrentKey() (string, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *testKeyProvider) Key(id string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// keyIDOf returns the ID of the key the stored value of key is encrypted
+// with
+func keyIDOf(t *testing.T, r *RocksDB, key string) string {
+	t.Helper()
+	raw, exists, err := r.getRaw(r.ro, key)
+	if err != nil || !exists {
+		t.Fatalf("getRaw(%s) = %v, %v", key, exists, err)
+	}
+	return decodeValue(raw).keyID
+}
+
+func TestRotateKey(t *testing.T) {
+	ctx := context.Background()
+	keys := &testKeyProvider{}
+	keys.add(t, "old")
+
+	r, err := NewRocksDB(t.TempDir(), DBOptions{Keys: keys, Encrypt: true})
+	if err != nil {
+		t.Fatalf("NewRocksDB: %v", err)
+	}
+	defer r.Close()
+
+	const n = rotateBatchSize + 10
+	for i := range n {
+		if _, err := r.Put(ctx, fmt.Sprintf("key%03d", i), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	_, versionBefore, _, _ := r.GetVersioned(ctx, "key000")
+	if id := keyIDOf(t, r, "key000"); id != "old" {
+		t.Fatalf("value encrypted with %q, want old", id)
+	}
+
+	keys.add(t, "new")
+	var processed uint64
+	if err := r.RotateKey(ctx, func(p uint64) { processed = p }); err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+	if processed != n {
+		t.Errorf("RotateKey reported %d keys processed, want %d", processed, n)
+	}
+
+	// Values no longer need the old key and keep their versions
+	keys.remove("old")
+	for i := range n {
+		key := fmt.Sprintf("key%03d", i)
+		if id := keyIDOf(t, r, key); id != "new" {
+			t.Fatalf("%s still encrypted with %q after rotation", key, id)
+		}
+		value, version, _, err := r.GetVersioned(ctx, key)
+		if err != nil || string(value) != fmt.Sprint(i) {
+			t.Fatalf("Get(%s) = %q, %v after rotation", key, value, err)
+		}
+		if i == 0 && version != versionBefore {
+			t.Errorf("rotation changed the version of %s from %d to %d", key, versionBefore, version)
+		}
+	}
+}
+
+func TestEncryptedValueNeedsKeys(t *testing.T) {
+	ctx := context.Background()
+	keys := &testKeyProvider{}
+	keys.add(t, "k1")
+	dir := t.TempDir()
+
+	r, err := NewRocksDB(dir, DBOptions{Keys: keys, Encrypt: true})
+	if err != nil {
+		t.Fatalf("NewRocksDB: %v", err)
+	}
+	if _, err := r.Put(ctx, "k", []byte("secret")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	raw, _, _ := r.getRaw(r.ro, "k")
+	r.Close()
+	if string(decodeValue(raw).value) == "secret" {
+		t.Fatal("value is stored in plaintext")
+	}
+
+	r, err = NewRocksDB(dir, DBOptions{})
+	if err != nil {
+		t.Fatalf("NewRocksDB: %v", err)
+	}
+	defer r.Close()
+	if _, _, err := r.Get(ctx, "k"); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Get without keys: %v, want ErrNoKeys", err)
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Values are stored in an envelope carrying the key's version and the
+// commit timestamp in Unix nanoseconds:
+//
+//	magic (5 bytes) | version (8 bytes) | timestamp (8 bytes) | value
+//
+// Format 3 holds an encrypted value, prefixed with the ID of its key:
+//
+//	magic (5 bytes) | version | timestamp | key ID length (1 byte) | key ID | sealed value
+//
+// Integers are big endian. Values written before versioning have no
+// envelope and read as version 0 with timestamp 0.
+const (
+	envelopeMagicV2 = "\x00rkv\x02"
+	envelopeMagicV3 = "\x00rkv\x03"
+	envelopeMagic   = len(envelopeMagicV2)
+	envelopeHeader  = envelopeMagic + 16
+)
+
+// record is a decoded value envelope
+type record struct {
+	value     []byte
+	version   uint64
+	timestamp int64
+	// keyID is the ID of the key value is encrypted with, empty for
+	// plaintext values
+	keyID string
+}
+
+// encodeValue wraps value in an envelope with the given version and commit
+// timestamp
+func encodeValue(version uint64, timestamp int64, value []byte) []byte {
+	buf := make([]byte, envelopeHeader+len(value))
+	copy(buf, envelopeMagicV2)
+	binary.BigEndian.PutUint64(buf[envelopeMagic:], version)
+	binary.BigEndian.PutUint64(buf[envelopeMagic+8:], uint64(timestamp))
+	copy(buf[envelopeHeader:], value)
+	return buf
+}
+
+// encodeSealed wraps an encrypted value in an envelope
+func encodeSealed(version uint64, timestamp int64, keyID string, sealed []byte) []byte {
+	buf := make([]byte, 0, envelopeHeader+1+len(keyID)+len(sealed))
+	buf = append(buf, envelopeMagicV3...)
+	buf = binary.BigEndian.AppendUint64(buf, version)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(timestamp))
+	buf = append(buf, byte(len(keyID)))
+	buf = append(buf, keyID...)
+	return append(buf, sealed...)
+}
+
+// decodeValue returns the record stored in raw. The returned value aliases
+// raw.
+func decodeValue(raw []byte) record {
+	switch {
+	case len(raw) > envelopeHeader && bytes.HasPrefix(raw, []byte(envelopeMagicV3)):
+		idLen := int(raw[envelopeHeader])
+		if len(raw) < envelopeHeader+1+idLen {
+			return record{value: raw}
+		}
+		return record{
+			value:     raw[envelopeHeader+1+idLen:],
+			version:   binary.BigEndian.Uint64(raw[envelopeMagic:]),
+			timestamp: int64(binary.BigEndian.Uint64(raw[envelopeMagic+8:])),
+			keyID:     string(raw[envelopeHeader+1 : envelopeHeader+1+idLen]),
+		}
+	case len(raw) >= envelopeHeader && bytes.HasPrefix(raw, []byte(envelopeMagicV2)):
+		return record{
+			value:     raw[envelopeHeader:],
+			version:   binary.BigEndian.Uint64(raw[envelopeMagic:]),
+			timestamp: int64(binary.BigEndian.Uint64(raw[envelopeMagic+8:])),
+		}
+	default:
+		return record{value: raw}
+	}
+}
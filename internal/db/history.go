@@ -0,0 +1,221 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/linxGnu/grocksdb"
+	"rocksdb-service/internal/tracing"
+)
+
+// historyCF is the column family holding superseded values. Entries are
+// keyed by
+//
+//	uvarint(len(key)) | key | superseded at (8 bytes) | version (8 bytes)
+//
+// so the versions of a key are adjacent and ordered by the time they were
+// replaced. Values are the original envelopes, carrying the commit time.
+const historyCF = "history"
+
+func historyPrefix(key string) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(key)))
+	return append(buf, key...)
+}
+
+func historyKey(key string, supersededAt int64, version uint64) []byte {
+	buf := historyPrefix(key)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(supersededAt))
+	return binary.BigEndian.AppendUint64(buf, version)
+}
+
+// historyUserKey extracts the user key from a history key
+func historyUserKey(hkey []byte) (string, bool) {
+	n, size := binary.Uvarint(hkey)
+	if size <= 0 || uint64(len(hkey)-size) < n+16 {
+		return "", false
+	}
+	return string(hkey[size : size+int(n)]), true
+}
+
+// historySupersededAt extracts the supersession time from a history key
+func historySupersededAt(hkey []byte) int64 {
+	return int64(binary.BigEndian.Uint64(hkey[len(hkey)-16:]))
+}
+
+// historyFilter drops history entries superseded before the retention
+// window during compaction, as no read within the window can see them
+type historyFilter struct {
+	retention time.Duration
+}
+
+func (f *historyFilter) Name() string { return "rocksdb-service.history" }
+
+func (f *historyFilter) Filter(level int, key, val []byte) (bool, []byte) {
+	if len(key) < 16 {
+		return false, nil
+	}
+	cutoff := time.Now().Add(-f.retention).UnixNano()
+	return historySupersededAt(key) < cutoff, nil
+}
+
+func (f *historyFilter) SetIgnoreSnapshots(value bool) {}
+
+func (f *historyFilter) Destroy() {}
+
+// checkHistory validates that a time-travel read at t is possible
+func (r *RocksDB) checkHistory(t time.Time) error {
+	if r.history == nil {
+		return ErrHistoryDisabled
+	}
+	if t.Before(time.Now().Add(-r.historyRetention)) {
+		return ErrBeforeRetention
+	}
+	return nil
+}
+
+// historyEntry decodes the stored envelope of a value key held
+func (r *RocksDB) historyEntry(key string, raw []byte, supersededAt int64) HistoryEntry {
+	rec := decodeValue(raw)
+	value, err := r.open(key, rec)
+	if err != nil {
+		return HistoryEntry{Err: err}
+	}
+	e := HistoryEntry{
+		Value:   value,
+		Version: rec.version,
+	}
+	if rec.timestamp != 0 {
+		e.Timestamp = time.Unix(0, rec.timestamp)
+	}
+	if supersededAt != 0 {
+		e.SupersededAt = time.Unix(0, supersededAt)
+	}
+	return e
+}
+
+// GetAsOf returns the value key held at time t
+func (r *RocksDB) GetAsOf(ctx context.Context, key string, t time.Time) (HistoryEntry, bool, error) {
+	if err := r.checkHistory(t); err != nil {
+		return HistoryEntry{}, false, err
+	}
+	at := t.UnixNano()
+	defer measure(ctx)()
+
+	// Read the current value and history from one snapshot so a concurrent
+	// write cannot move the value in between
+	snap := r.db.NewSnapshot()
+	defer r.db.ReleaseSnapshot(snap)
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snap)
+
+	raw, exists, err := r.getRaw(ro, key)
+	if err != nil {
+		return HistoryEntry{}, false, err
+	}
+	if exists && decodeValue(raw).timestamp <= at {
+		e := r.historyEntry(key, raw, 0)
+		return e, true, e.Err
+	}
+
+	// The value visible at t is the first one superseded after t, provided
+	// it had been written by then
+	it := r.db.NewIteratorCF(ro, r.history)
+	defer it.Close()
+
+	prefix := historyPrefix(key)
+	seek := int64(math.MaxInt64)
+	if at < math.MaxInt64 {
+		seek = at + 1
+	}
+	r.seek(ctx, it, historyKey(key, seek, 0))
+	if !it.ValidForPrefix(prefix) {
+		return HistoryEntry{}, false, it.Err()
+	}
+
+	hkey := it.Key()
+	supersededAt := historySupersededAt(hkey.Data())
+	hkey.Free()
+	value := it.Value()
+	raw = make([]byte, value.Size())
+	copy(raw, value.Data())
+	value.Free()
+
+	if decodeValue(raw).timestamp > at {
+		return HistoryEntry{}, false, nil
+	}
+	e := r.historyEntry(key, raw, supersededAt)
+	return e, true, e.Err
+}
+
+// GetHistory streams the current value of key followed by its retained
+// previous values, newest first
+func (r *RocksDB) GetHistory(ctx context.Context, key string) chan HistoryEntry {
+	ch := make(chan HistoryEntry)
+
+	go func() {
+		defer close(ch)
+
+		send := func(e HistoryEntry) bool {
+			select {
+			case ch <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if r.history == nil {
+			send(HistoryEntry{Err: ErrHistoryDisabled})
+			return
+		}
+		defer measure(ctx)()
+
+		snap := r.db.NewSnapshot()
+		defer r.db.ReleaseSnapshot(snap)
+		ro := grocksdb.NewDefaultReadOptions()
+		defer ro.Destroy()
+		ro.SetSnapshot(snap)
+
+		raw, exists, err := r.getRaw(ro, key)
+		if err != nil {
+			send(HistoryEntry{Err: err})
+			return
+		}
+		if exists && !send(r.historyEntry(key, raw, 0)) {
+			return
+		}
+
+		it := r.db.NewIteratorCF(ro, r.history)
+		defer it.Close()
+
+		prefix := historyPrefix(key)
+		_, span := r.startSpan(ctx, "rocksdb.SeekForPrev")
+		it.SeekForPrev(historyKey(key, math.MaxInt64, math.MaxUint64))
+		tracing.End(span, it.Err())
+		for ; it.ValidForPrefix(prefix); it.Prev() {
+			hkey := it.Key()
+			supersededAt := historySupersededAt(hkey.Data())
+			hkey.Free()
+			value := it.Value()
+			raw := make([]byte, value.Size())
+			copy(raw, value.Data())
+			value.Free()
+
+			if !send(r.historyEntry(key, raw, supersededAt)) {
+				return
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			send(HistoryEntry{Err: fmt.Errorf("iterator error: %w", err)})
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxFinishedJobs is the number of finished jobs kept for inspection
+const maxFinishedJobs = 100
+
+// JobState is the state of a background job
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+// Job is a snapshot of a background job
+type Job struct {
+	ID   string
+	Kind string
+	// Database is the physical database, aliases being resolved
+	Database string
+	State    JobState
+	// Processed counts the keys the job has processed so far
+	Processed uint64
+	Started   time.Time
+	Finished  time.Time
+	Err       string
+}
+
+// jobs tracks the background jobs of a DBManager
+type jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	next int
+	jobs map[string]*Job
+}
+
+func newJobs() *jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobs{ctx: ctx, cancel: cancel, jobs: make(map[string]*Job)}
+}
+
+// stop cancels all running jobs and waits for them to return
+func (j *jobs) stop() {
+	j.cancel()
+	j.wg.Wait()
+}
+
+// startJob runs a job of the given kind against a database in the
+// background. Only one job of a kind may run per database at a time;
+// aliases are resolved first, so a job started through an alias conflicts
+// with one started on its target. Unknown databases are not created.
+func (m *DBManager) startJob(kind, name string, run func(ctx context.Context, db Store, progress func(uint64)) error) (string, error) {
+	m.mu.RLock()
+	target := m.resolve(name)
+	m.mu.RUnlock()
+
+	// The job is reserved before the database is opened, so that
+	// concurrent calls cannot both start one
+	j := m.jobs
+	j.mu.Lock()
+	for _, job := range j.jobs {
+		if job.Kind == kind && job.Database == target && job.State == JobRunning {
+			j.mu.Unlock()
+			return "", fmt.Errorf("%s job %s is already running on %s", kind, job.ID, target)
+		}
+	}
+	j.next++
+	job := &Job{
+		ID:       fmt.Sprintf("%s-%d", kind, j.next),
+		Kind:     kind,
+		Database: target,
+		State:    JobRunning,
+		Started:  time.Now(),
+	}
+	j.jobs[job.ID] = job
+	j.mu.Unlock()
+
+	database, release, err := m.OpenDB(context.Background(), target)
+	if err != nil {
+		j.mu.Lock()
+		delete(j.jobs, job.ID)
+		j.mu.Unlock()
+		return "", err
+	}
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		defer release()
+
+		err := run(j.ctx, database, func(processed uint64) {
+			j.mu.Lock()
+			job.Processed = processed
+			j.mu.Unlock()
+		})
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		job.Finished = time.Now()
+		job.State = JobSucceeded
+		if err != nil {
+			job.State = JobFailed
+			job.Err = err.Error()
+		}
+		j.prune()
+	}()
+
+	return job.ID, nil
+}
+
+// prune drops the oldest finished jobs beyond maxFinishedJobs. Must be
+// called with j.mu held.
+func (j *jobs) prune() {
+	var finished []*Job
+	for _, job := range j.jobs {
+		if job.State != JobRunning {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].Finished.Before(finished[b].Finished) })
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(j.jobs, job.ID)
+	}
+}
+
+// Jobs returns running and recently finished background jobs, oldest first
+func (m *DBManager) Jobs() []Job {
+	j := m.jobs
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := make([]Job, 0, len(j.jobs))
+	for _, job := range j.jobs {
+		out = append(out, *job)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Started.Before(out[b].Started) })
+	return out
+}
+
+// RotateKey starts a background job re-encrypting a database with the
+// current encryption key and returns its ID
+func (m *DBManager) RotateKey(name string) (string, error) {
+	return m.startJob("rotate-key", name, func(ctx context.Context, db Store, progress func(uint64)) error {
+		return db.RotateKey(ctx, progress)
+	})
+}
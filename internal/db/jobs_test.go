@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestStartJob(t *testing.T) {
+	m := newTestDBManager(t)
+	ctx := context.Background()
+	if _, err := m.SwapAlias(ctx, "alias", "target"); err != nil {
+		t.Fatalf("SwapAlias: %v", err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	run := func(ctx context.Context, db Store, progress func(uint64)) error {
+		<-release
+		return nil
+	}
+
+	if _, err := m.startJob("test", "missing", run); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Errorf("job on a missing database: %v, want ErrDatabaseNotFound", err)
+	}
+	if _, _, ok := m.LookupDB("missing"); ok {
+		t.Error("starting a job created the database")
+	}
+
+	// Of concurrent jobs on an alias and its target, only one starts
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		started int
+	)
+	for _, name := range []string{"alias", "target", "alias", "target"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.startJob("test", name, run); err == nil {
+				mu.Lock()
+				started++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if started != 1 {
+		t.Errorf("%d concurrent jobs started, want 1", started)
+	}
+	jobs := m.Jobs()
+	if len(jobs) != 1 || jobs[0].Database != "target" {
+		t.Errorf("Jobs = %+v, want one job on target", jobs)
+	}
+}
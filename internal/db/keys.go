@@ -0,0 +1,121 @@
+package db
+
+import (
+	"crypto/aes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"rocksdb-service/internal/filewatch"
+)
+
+// ErrNoKeys is returned when reading an encrypted value without a key
+// provider
+var ErrNoKeys = errors.New("value is encrypted but no encryption keys are configured")
+
+// KeyProvider supplies the AES keys values are encrypted with. Keys are
+// identified by an ID stored with each value, so values encrypted with a
+// previous key stay readable after rotation.
+type KeyProvider interface {
+	// CurrentKey returns the ID and key new values are encrypted with
+	CurrentKey() (string, []byte, error)
+	// Key returns the key with the given ID
+	Key(id string) ([]byte, error)
+}
+
+// KeyFile is a KeyProvider reading base64-encoded AES-128, AES-192 or
+// AES-256 keys from a JSON file, reloaded when it changes:
+//
+//	{"current": "2026-10", "keys": {"2026-09": "...", "2026-10": "..."}}
+//
+// Keys must never be removed while values encrypted with them remain.
+type KeyFile struct {
+	path    string
+	watcher *filewatch.Watcher
+
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// LoadKeyFile reads the key file at path
+func LoadKeyFile(path string) (*KeyFile, error) {
+	f := &KeyFile{path: path}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	watcher, err := filewatch.New(time.Second, path)
+	if err != nil {
+		return nil, err
+	}
+	f.watcher = watcher
+	return f, nil
+}
+
+func (f *KeyFile) load() error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+	var file struct {
+		Current string            `json:"current"`
+		Keys    map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		if id == "" || len(id) > 255 {
+			return fmt.Errorf("key ID %q must be 1 to 255 bytes", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("key %s is not valid base64: %w", id, err)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return fmt.Errorf("key %s: %w", id, err)
+		}
+		keys[id] = key
+	}
+	if _, ok := keys[file.Current]; !ok {
+		return fmt.Errorf("current key %q is not in the key file", file.Current)
+	}
+
+	f.mu.Lock()
+	f.current, f.keys = file.Current, keys
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *KeyFile) reload() {
+	if f.watcher.Changed() {
+		if err := f.load(); err != nil {
+			log.Printf("Failed to reload key file: %v", err)
+		}
+	}
+}
+
+func (f *KeyFile) CurrentKey() (string, []byte, error) {
+	f.reload()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.current, f.keys[f.current], nil
+}
+
+func (f *KeyFile) Key(id string) ([]byte, error) {
+	f.reload()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	key, ok := f.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
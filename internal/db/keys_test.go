@@ -0,0 +1,55 @@
+package db
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadKeyFile(t *testing.T) {
+	key16 := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	key32 := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"valid", `{"current": "b", "keys": {"a": "` + key16 + `", "b": "` + key32 + `"}}`, ""},
+		{"missing current", `{"current": "c", "keys": {"a": "` + key16 + `"}}`, "not in the key file"},
+		{"bad base64", `{"current": "a", "keys": {"a": "%%%"}}`, "not valid base64"},
+		{"bad key size", `{"current": "a", "keys": {"a": "` + base64.StdEncoding.EncodeToString(make([]byte, 10)) + `"}}`, "invalid key size"},
+		{"empty ID", `{"current": "", "keys": {"": "` + key16 + `"}}`, "must be 1 to 255 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			f, err := LoadKeyFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadKeyFile: %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeyFile: %v", err)
+			}
+
+			id, key, err := f.CurrentKey()
+			if err != nil || id != "b" || len(key) != 32 {
+				t.Errorf("CurrentKey = %q, %d bytes, %v; want b, 32 bytes", id, len(key), err)
+			}
+			if key, err := f.Key("a"); err != nil || len(key) != 16 {
+				t.Errorf("Key(a) = %d bytes, %v; want 16 bytes", len(key), err)
+			}
+			if _, err := f.Key("missing"); err == nil {
+				t.Error("Key of an unknown ID succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// numKeyLocks is the number of lock stripes per database
+const numKeyLocks = 256
+
+// keyLocks serializes writers of the same key using a fixed set of striped
+// mutexes, so conditional writes can read and write a key atomically
+type keyLocks struct {
+	stripes [numKeyLocks]sync.Mutex
+}
+
+func stripe(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % numKeyLocks)
+}
+
+// lock locks the stripes of all keys and returns a function unlocking them.
+// Stripes are always acquired in ascending order to avoid deadlocks.
+func (l *keyLocks) lock(keys ...string) func() {
+	idx := make([]int, 0, len(keys))
+	seen := make(map[int]bool, len(keys))
+	for _, key := range keys {
+		i := stripe(key)
+		if !seen[i] {
+			seen[i] = true
+			idx = append(idx, i)
+		}
+	}
+	sort.Ints(idx)
+
+	for _, i := range idx {
+		l.stripes[i].Lock()
+	}
+	return func() {
+		for j := len(idx) - 1; j >= 0; j-- {
+			l.stripes[idx[j]].Unlock()
+		}
+	}
+}
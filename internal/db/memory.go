@@ -0,0 +1,547 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/btree"
+)
+
+// memoryBTreeDegree is the degree of the B-trees holding in-memory databases
+const memoryBTreeDegree = 32
+
+// memoryItem is a value stored in a MemoryStore. Items are immutable once
+// stored, so snapshots can share them.
+type memoryItem struct {
+	key       string
+	value     []byte
+	version   uint64
+	timestamp int64
+	// supersededAt is when a history item was replaced or deleted
+	supersededAt int64
+}
+
+func (it memoryItem) size() uint64 {
+	return uint64(len(it.key) + len(it.value))
+}
+
+func memoryItemLess(a, b memoryItem) bool {
+	return a.key < b.key
+}
+
+// MemoryStore is a Store keeping a database in an ordered in-memory map. It
+// needs no cgo, so services can test against it and embed the server in
+// process. Values are held in plaintext, even in encrypted databases, as
+// they never leave the process.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	items *btree.BTreeG[memoryItem]
+	// history holds superseded values per key, oldest first, nil unless
+	// history mode is enabled
+	history          map[string][]memoryItem
+	historyRetention time.Duration
+	encrypt          bool
+	// version is the last version assigned to a write
+	version uint64
+	// size is the total size of keys and values, including history
+	size uint64
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty in-memory database
+func NewMemoryStore(opts DBOptions) (*MemoryStore, error) {
+	s := &MemoryStore{items: btree.NewG(memoryBTreeDegree, memoryItemLess)}
+	if err := s.configure(opts); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// configure applies opts to the store. Disabling history mode drops the
+// retained history.
+func (s *MemoryStore) configure(opts DBOptions) error {
+	if opts.Encrypt && opts.Keys == nil {
+		return fmt.Errorf("encryption requires encryption keys")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.encrypt = opts.Encrypt
+	s.historyRetention = opts.HistoryRetention
+	switch {
+	case opts.HistoryRetention > 0 && s.history == nil:
+		s.history = make(map[string][]memoryItem)
+	case opts.HistoryRetention == 0:
+		for _, items := range s.history {
+			for _, it := range items {
+				s.size -= it.size()
+			}
+		}
+		s.history = nil
+	}
+	return nil
+}
+
+// Close does nothing; the data is kept until the store is garbage
+// collected
+func (s *MemoryStore) Close() {}
+
+// Put stores value under key and returns its new version
+func (s *MemoryStore) Put(ctx context.Context, key string, value []byte) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commit([]Mutation{{Key: key, Value: value}})[0], nil
+}
+
+// PutIfVersion stores value under key if the key's current version equals
+// version, and returns the new version. Version 0 requires the key to be
+// missing.
+func (s *MemoryStore) PutIfVersion(ctx context.Context, key string, value []byte, version uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.items.Get(memoryItem{key: key})
+	if current.version != version || (version == 0 && exists) {
+		return 0, s.conditionError(current, exists)
+	}
+	return s.commit([]Mutation{{Key: key, Value: value}})[0], nil
+}
+
+// PutIfAbsent stores value under key if the key does not exist, and returns
+// the new version
+func (s *MemoryStore) PutIfAbsent(ctx context.Context, key string, value []byte) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, exists := s.items.Get(memoryItem{key: key}); exists {
+		return 0, s.conditionError(current, true)
+	}
+	return s.commit([]Mutation{{Key: key, Value: value}})[0], nil
+}
+
+// CompareAndSwap replaces the value of key with value if its current value
+// equals expected, and returns the new version
+func (s *MemoryStore) CompareAndSwap(ctx context.Context, key string, expected, value []byte) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.items.Get(memoryItem{key: key})
+	if !exists || !bytes.Equal(current.value, expected) {
+		return 0, s.conditionError(current, exists)
+	}
+	return s.commit([]Mutation{{Key: key, Value: value}})[0], nil
+}
+
+// DeleteIfEquals deletes key if its current value equals expected
+func (s *MemoryStore) DeleteIfEquals(ctx context.Context, key string, expected []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.items.Get(memoryItem{key: key})
+	if !exists || !bytes.Equal(current.value, expected) {
+		return s.conditionError(current, exists)
+	}
+	s.commit([]Mutation{{Key: key, Delete: true}})
+	return nil
+}
+
+// Increment applies increments to counters and returns their new values.
+// Either all increments are applied or none.
+func (s *MemoryStore) Increment(ctx context.Context, increments []Increment) ([]Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mutations, counters, err := applyIncrements(increments, func(key string) ([]byte, uint64, bool, error) {
+		it, exists := s.items.Get(memoryItem{key: key})
+		return it.value, it.version, exists, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	versions := s.commit(mutations)
+	for i := range counters {
+		counters[i].Version = versions[i]
+	}
+	return counters, nil
+}
+
+func (s *MemoryStore) conditionError(current memoryItem, exists bool) error {
+	if !exists {
+		return &ConditionError{}
+	}
+	return &ConditionError{Value: bytes.Clone(current.value), Version: current.version, Found: true}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, _, exists, err := s.GetVersioned(ctx, key)
+	return value, exists, err
+}
+
+// GetVersioned returns the value of key along with its version
+func (s *MemoryStore) GetVersioned(ctx context.Context, key string) ([]byte, uint64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	it, exists := s.items.Get(memoryItem{key: key})
+	if !exists {
+		return nil, 0, false, nil
+	}
+	return bytes.Clone(it.value), it.version, true, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commit([]Mutation{{Key: key, Delete: true}})
+	return nil
+}
+
+// Write applies mutations atomically
+func (s *MemoryStore) Write(ctx context.Context, mutations []Mutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commit(mutations)
+	return nil
+}
+
+// commit applies mutations and returns the version assigned to each put. In
+// history mode, the values being replaced are moved to the history. Must be
+// called with s.mu held.
+func (s *MemoryStore) commit(mutations []Mutation) []uint64 {
+	versions := make([]uint64, len(mutations))
+	now := time.Now().UnixNano()
+
+	for i, m := range mutations {
+		timestamp := now
+		prev, exists := s.items.Get(memoryItem{key: m.Key})
+		if exists && s.history != nil {
+			// Keep commit timestamps of a key monotonic if the clock steps
+			// back
+			timestamp = max(timestamp, prev.timestamp)
+			s.supersede(prev, timestamp)
+		}
+		if exists {
+			s.size -= prev.size()
+		}
+
+		if m.Delete {
+			s.items.Delete(memoryItem{key: m.Key})
+			continue
+		}
+		s.version++
+		versions[i] = s.version
+		it := memoryItem{
+			key:       m.Key,
+			value:     bytes.Clone(m.Value),
+			version:   s.version,
+			timestamp: timestamp,
+		}
+		s.items.ReplaceOrInsert(it)
+		s.size += it.size()
+	}
+	return versions
+}
+
+// supersede moves the current value of a key to its history, dropping
+// history superseded before the retention window. Must be called with s.mu
+// held.
+func (s *MemoryStore) supersede(prev memoryItem, at int64) {
+	prev.supersededAt = at
+	items := append(s.history[prev.key], prev)
+	s.size += prev.size()
+
+	cutoff := time.Now().Add(-s.historyRetention).UnixNano()
+	expired := 0
+	for expired < len(items) && items[expired].supersededAt < cutoff {
+		s.size -= items[expired].size()
+		expired++
+	}
+	s.history[prev.key] = slices.Clone(items[expired:])
+}
+
+// snapshot returns a copy-on-write copy of the items, unaffected by later
+// writes
+func (s *MemoryStore) snapshot() *btree.BTreeG[memoryItem] {
+	// Cloning marks the nodes of the original tree as shared, so it needs
+	// the write lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.items.Clone()
+}
+
+func (s *MemoryStore) GetByPrefix(ctx context.Context, prefix string) chan KeyValuePair {
+	return s.Scan(ctx, ScanOptions{Prefix: prefix})
+}
+
+func (s *MemoryStore) GetMultiple(ctx context.Context, keys []string) chan KeyValuePair {
+	ch := make(chan KeyValuePair)
+
+	go func() {
+		defer close(ch)
+
+		for _, key := range keys {
+			value, version, exists, _ := s.GetVersioned(ctx, key)
+			if !exists {
+				continue
+			}
+			select {
+			case ch <- KeyValuePair{Key: key, Value: value, Version: version}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Scan streams key-value pairs in key order from a snapshot taken when the
+// scan starts. The channel is closed when the scan completes or ctx is
+// done.
+func (s *MemoryStore) Scan(ctx context.Context, opts ScanOptions) chan KeyValuePair {
+	ch := make(chan KeyValuePair)
+	items := s.snapshot()
+
+	go func() {
+		defer close(ch)
+
+		start := opts.Start
+		if start < opts.Prefix {
+			start = opts.Prefix
+		}
+
+		var n uint64
+		items.AscendGreaterOrEqual(memoryItem{key: start}, func(it memoryItem) bool {
+			if opts.Limit > 0 && n >= opts.Limit {
+				return false
+			}
+			if !strings.HasPrefix(it.key, opts.Prefix) {
+				return false
+			}
+			if opts.End != "" && it.key >= opts.End {
+				return false
+			}
+
+			pair := KeyValuePair{Key: it.key, Version: it.version}
+			if !opts.KeysOnly {
+				pair.Value = bytes.Clone(it.value)
+			}
+			select {
+			case ch <- pair:
+			case <-ctx.Done():
+				return false
+			}
+			n++
+			return true
+		})
+	}()
+
+	return ch
+}
+
+// checkHistory validates that a time-travel read at t is possible. Must be
+// called with s.mu held.
+func (s *MemoryStore) checkHistory(t time.Time) error {
+	if s.history == nil {
+		return ErrHistoryDisabled
+	}
+	if t.Before(time.Now().Add(-s.historyRetention)) {
+		return ErrBeforeRetention
+	}
+	return nil
+}
+
+// historyEntry converts a stored item
+func (it memoryItem) historyEntry() HistoryEntry {
+	e := HistoryEntry{
+		Value:   bytes.Clone(it.value),
+		Version: it.version,
+	}
+	if it.timestamp != 0 {
+		e.Timestamp = time.Unix(0, it.timestamp)
+	}
+	if it.supersededAt != 0 {
+		e.SupersededAt = time.Unix(0, it.supersededAt)
+	}
+	return e
+}
+
+// GetAsOf returns the value key held at time t
+func (s *MemoryStore) GetAsOf(ctx context.Context, key string, t time.Time) (HistoryEntry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkHistory(t); err != nil {
+		return HistoryEntry{}, false, err
+	}
+	at := t.UnixNano()
+
+	if it, exists := s.items.Get(memoryItem{key: key}); exists && it.timestamp <= at {
+		return it.historyEntry(), true, nil
+	}
+
+	// The value visible at t is the first one superseded after t, provided
+	// it had been written by then
+	items := s.history[key]
+	i := sort.Search(len(items), func(i int) bool { return items[i].supersededAt > at })
+	if i == len(items) || items[i].timestamp > at {
+		return HistoryEntry{}, false, nil
+	}
+	return items[i].historyEntry(), true, nil
+}
+
+// GetHistory streams the current value of key followed by its retained
+// previous values, newest first
+func (s *MemoryStore) GetHistory(ctx context.Context, key string) chan HistoryEntry {
+	ch := make(chan HistoryEntry)
+
+	s.mu.RLock()
+	var entries []HistoryEntry
+	err := s.checkHistory(time.Now())
+	if err == nil {
+		if it, exists := s.items.Get(memoryItem{key: key}); exists {
+			entries = append(entries, it.historyEntry())
+		}
+		cutoff := time.Now().Add(-s.historyRetention).UnixNano()
+		items := s.history[key]
+		for i := len(items) - 1; i >= 0 && items[i].supersededAt >= cutoff; i-- {
+			entries = append(entries, items[i].historyEntry())
+		}
+	}
+	s.mu.RUnlock()
+
+	go func() {
+		defer close(ch)
+
+		if err != nil {
+			entries = []HistoryEntry{{Err: err}}
+		}
+		for _, e := range entries {
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// RotateKey reports every key as processed. Values of in-memory databases
+// are never encrypted, so there is nothing to re-encrypt.
+func (s *MemoryStore) RotateKey(ctx context.Context, progress func(uint64)) error {
+	s.mu.RLock()
+	encrypt, n := s.encrypt, uint64(s.items.Len())
+	s.mu.RUnlock()
+
+	if !encrypt {
+		return fmt.Errorf("database is not encrypted")
+	}
+	progress(n)
+	return nil
+}
+
+// SSTSize returns the total size of keys and values held, including
+// history
+func (s *MemoryStore) SSTSize() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size
+}
+
+// SetOptions accepts and ignores RocksDB options
+func (s *MemoryStore) SetOptions(options map[string]string) error {
+	return nil
+}
+
+// BackgroundErrors returns 0, as in-memory databases have no background
+// work that can fail
+func (s *MemoryStore) BackgroundErrors() uint64 {
+	return 0
+}
+
+// Stats returns the number of keys and the size of the database under the
+// names of the matching RocksDB properties
+func (s *MemoryStore) Stats() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]string{
+		"rocksdb.estimate-num-keys":    strconv.Itoa(s.items.Len()),
+		"rocksdb.total-sst-files-size": strconv.FormatUint(s.size, 10),
+	}
+}
+
+// memoryBackend keeps databases in MemoryStores. Closed databases keep
+// their data until destroyed, like RocksDB databases on disk.
+type memoryBackend struct {
+	mu     sync.Mutex
+	stores map[string]*MemoryStore
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{stores: make(map[string]*MemoryStore)}
+}
+
+func (b *memoryBackend) open(name string, opts DBOptions) (Store, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s, exists := b.stores[name]; exists {
+		if err := s.configure(opts); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	s, err := NewMemoryStore(opts)
+	if err != nil {
+		return nil, err
+	}
+	b.stores[name] = s
+	return s, nil
+}
+
+func (b *memoryBackend) destroy(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.stores, name)
+	return nil
+}
+
+func (b *memoryBackend) list() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.stores))
+	for name := range b.stores {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (b *memoryBackend) exists(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, exists := b.stores[name]
+	return exists
+}
+
+func (b *memoryBackend) setBlockCacheSize(size uint64) error {
+	return fmt.Errorf("the %s backend has no block cache", BackendMemory)
+}
+
+func (b *memoryBackend) close() {}
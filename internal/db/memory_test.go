@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+const testKeys = 100
+
+func newTestMemoryStore(t *testing.T, opts DBOptions) *MemoryStore {
+	t.Helper()
+	s, err := NewMemoryStore(opts)
+	if err != nil {
+		t.Fatalf("NewMemoryStore: %v", err)
+	}
+	for i := range testKeys {
+		if _, err := s.Put(context.Background(), fmt.Sprintf("key%03d", i), []byte("value")); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	return s
+}
+
+// waitClosed fails the test unless the producer behind ch, which has at
+// least n elements left, stops early and closes ch
+func waitClosed[T any](t *testing.T, ch <-chan T, n int) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	received := 0
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				if received >= n {
+					t.Fatal("producer kept sending after the context was canceled")
+				}
+				return
+			}
+			received++
+		case <-timeout:
+			t.Fatal("producer did not stop after the context was canceled")
+		}
+	}
+}
+
+func TestProducersStopOnCancel(t *testing.T) {
+	s := newTestMemoryStore(t, DBOptions{HistoryRetention: time.Hour})
+	for i := range testKeys {
+		if _, err := s.Put(context.Background(), "key000", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	t.Run("GetByPrefix", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := s.GetByPrefix(ctx, "key")
+		<-ch
+		cancel()
+		waitClosed(t, ch, testKeys-1)
+	})
+	t.Run("GetMultiple", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		keys := make([]string, testKeys)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("key%03d", i)
+		}
+		ch := s.GetMultiple(ctx, keys)
+		<-ch
+		cancel()
+		waitClosed(t, ch, testKeys-1)
+	})
+	t.Run("GetHistory", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := s.GetHistory(ctx, "key000")
+		<-ch
+		cancel()
+		waitClosed(t, ch, testKeys)
+	})
+}
+
+func TestPutIfVersion(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryStore(t, DBOptions{})
+
+	var condErr *ConditionError
+	if _, err := s.PutIfVersion(ctx, "key000", []byte("new"), 0); !errors.As(err, &condErr) || !condErr.Found {
+		t.Fatalf("PutIfVersion(0) on an existing key: got %v, want a ConditionError", err)
+	}
+	_, version, _, _ := s.GetVersioned(ctx, "key000")
+	if _, err := s.PutIfVersion(ctx, "key000", []byte("new"), version+1); !errors.As(err, &condErr) {
+		t.Fatalf("PutIfVersion with a stale version: got %v, want a ConditionError", err)
+	}
+	if _, err := s.PutIfVersion(ctx, "key000", []byte("new"), version); err != nil {
+		t.Fatalf("PutIfVersion with the current version: %v", err)
+	}
+	if _, err := s.PutIfVersion(ctx, "missing", []byte("new"), 0); err != nil {
+		t.Fatalf("PutIfVersion(0) on a missing key: %v", err)
+	}
+}
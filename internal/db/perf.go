@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+	"sync"
+)
+
+// PerfStats accumulates RocksDB PerfContext counters over the operations of
+// a request
+type PerfStats struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+type perfStatsKey struct{}
+
+// WithPerfStats returns a context under which database operations record
+// their PerfContext counters into the returned stats. Measured operations
+// pin their goroutine to an OS thread, since PerfContext is thread-local.
+func WithPerfStats(ctx context.Context) (context.Context, *PerfStats) {
+	stats := &PerfStats{counters: make(map[string]uint64)}
+	return context.WithValue(ctx, perfStatsKey{}, stats), stats
+}
+
+// Counters returns the non-zero counters recorded so far
+func (s *PerfStats) Counters() map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]uint64, len(s.counters))
+	for name, v := range s.counters {
+		out[name] = v
+	}
+	return out
+}
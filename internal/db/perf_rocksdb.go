@@ -0,0 +1,65 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/linxGnu/grocksdb"
+)
+
+// PerfContext metric IDs, see grocksdb.PerfContext.Metric
+const (
+	perfUserKeyComparisons  = 0
+	perfBlockCacheHits      = 1
+	perfBlockReads          = 2
+	perfBlockReadBytes      = 3
+	perfInternalKeysSkipped = 10
+	perfInternalDelsSkipped = 11
+	perfMemtableGets        = 16
+	perfMemtableSeeks       = 20
+	perfChildSeeks          = 24
+)
+
+func (s *PerfStats) add(pc *grocksdb.PerfContext) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, id := range map[string]int{
+		"user_key_comparisons":     perfUserKeyComparisons,
+		"block_cache_hits":         perfBlockCacheHits,
+		"block_reads":              perfBlockReads,
+		"block_read_bytes":         perfBlockReadBytes,
+		"internal_keys_skipped":    perfInternalKeysSkipped,
+		"internal_deletes_skipped": perfInternalDelsSkipped,
+		"memtable_gets":            perfMemtableGets,
+		"memtable_seeks":           perfMemtableSeeks,
+		"child_seeks":              perfChildSeeks,
+	} {
+		if v := pc.Metric(id); v > 0 {
+			s.counters[name] += v
+		}
+	}
+}
+
+// measure starts collecting PerfContext counters for the stats in ctx, if
+// any, on the current thread. The returned function stops collecting and
+// must be called on the same goroutine.
+func measure(ctx context.Context) func() {
+	stats, ok := ctx.Value(perfStatsKey{}).(*PerfStats)
+	if !ok {
+		return func() {}
+	}
+
+	runtime.LockOSThread()
+	grocksdb.SetPerfLevel(grocksdb.KEnableCount)
+	pc := grocksdb.NewPerfContext()
+	pc.Reset()
+	return func() {
+		stats.add(pc)
+		pc.Destroy()
+		grocksdb.SetPerfLevel(grocksdb.KDisable)
+		runtime.UnlockOSThread()
+	}
+}
@@ -1,79 +1,486 @@
+//go:build cgo
+
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"github.com/linxGnu/grocksdb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"rocksdb-service/internal/tracing"
 )
 
-// KeyValuePair represents a key-value pair with optional error
-type KeyValuePair struct {
-	Key   string
-	Value []byte
-	Err   error
+// optionsString returns options in RocksDB's "name=value;..." format
+func optionsString(options map[string]string) string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s;", name, options[name])
+	}
+	return b.String()
+}
+
+// rocksdbBackend stores databases in RocksDB instances under a base
+// directory
+type rocksdbBackend struct {
+	baseDir string
+
+	// blockCache and writeBuffers are shared across the databases to bound
+	// their memory use
+	blockCache   *grocksdb.Cache
+	writeBuffers *grocksdb.WriteBufferManager
+}
+
+func newRocksDBBackend(baseDir string, opts Options) (backend, error) {
+	b := &rocksdbBackend{baseDir: baseDir}
+	if opts.BlockCacheSize > 0 {
+		b.blockCache = grocksdb.NewLRUCache(opts.BlockCacheSize)
+	}
+	if opts.WriteBufferSize > 0 {
+		b.writeBuffers = grocksdb.NewWriteBufferManager(int(opts.WriteBufferSize), true)
+	}
+	return b, nil
+}
+
+func (b *rocksdbBackend) open(name string, opts DBOptions) (Store, error) {
+	return openRocksDB(filepath.Join(b.baseDir, name), opts, b)
+}
+
+func (b *rocksdbBackend) destroy(name string) error {
+	return DestroyRocksDB(filepath.Join(b.baseDir, name))
+}
+
+func (b *rocksdbBackend) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(b.baseDir, name))
+	return err == nil
+}
+
+// list returns the subdirectories of the base directory
+func (b *rocksdbBackend) list() ([]string, error) {
+	entries, err := os.ReadDir(b.baseDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (b *rocksdbBackend) setBlockCacheSize(size uint64) error {
+	if b.blockCache == nil {
+		return fmt.Errorf("no shared block cache is configured")
+	}
+	b.blockCache.SetCapacity(size)
+	return nil
+}
+
+func (b *rocksdbBackend) close() {
+	if b.writeBuffers != nil {
+		b.writeBuffers.Destroy()
+	}
+	if b.blockCache != nil {
+		b.blockCache.Destroy()
+	}
+}
+
+// options returns the RocksDB options of a column family
+func (b *rocksdbBackend) options(o DBOptions) (*grocksdb.Options, error) {
+	opts := grocksdb.NewDefaultOptions()
+	if len(o.RocksDB) > 0 {
+		parsed, err := grocksdb.GetOptionsFromString(opts, optionsString(o.RocksDB))
+		opts.Destroy()
+		if err != nil {
+			return nil, fmt.Errorf("invalid RocksDB options: %w", err)
+		}
+		opts = parsed
+	}
+	if b.blockCache != nil {
+		bbto := grocksdb.NewDefaultBlockBasedTableOptions()
+		bbto.SetBlockCache(b.blockCache)
+		opts.SetBlockBasedTableFactory(bbto)
+	}
+	if b.writeBuffers != nil {
+		opts.SetWriteBufferManager(b.writeBuffers)
+	}
+	return opts, nil
 }
 
 type RocksDB struct {
+	// name is the database directory name, recorded on spans
+	name string
+
 	db *grocksdb.DB
 	ro *grocksdb.ReadOptions
 	wo *grocksdb.WriteOptions
+
+	// cfs holds all open column family handles, released on Close
+	cfs []*grocksdb.ColumnFamilyHandle
+	// history is the column family of superseded values, nil unless
+	// history mode is enabled
+	history          *grocksdb.ColumnFamilyHandle
+	historyRetention time.Duration
+
+	// cipher decrypts values if keys are configured; encrypt is set if new
+	// values are encrypted
+	cipher  *valueCipher
+	encrypt bool
+
+	// locks serializes writers so conditional writes are atomic
+	locks keyLocks
+
+	// version is the last version assigned to a write. It starts at the
+	// latest sequence number, which is never lower than any version
+	// persisted before, since every versioned write consumes a sequence.
+	version atomic.Uint64
 }
 
-func NewRocksDB(path string) (*RocksDB, error) {
-	opts := grocksdb.NewDefaultOptions()
+var _ Store = (*RocksDB)(nil)
+
+// NewRocksDB opens or creates the database at path, with its own block
+// cache and write buffers
+func NewRocksDB(path string, dbOpts DBOptions) (*RocksDB, error) {
+	return openRocksDB(path, dbOpts, &rocksdbBackend{})
+}
+
+// openRocksDB opens the database at path, sharing the block cache and write
+// buffers of b
+func openRocksDB(path string, dbOpts DBOptions, b *rocksdbBackend) (*RocksDB, error) {
+	if dbOpts.Encrypt && dbOpts.Keys == nil {
+		return nil, fmt.Errorf("encryption requires encryption keys")
+	}
+
+	opts, err := b.options(dbOpts)
+	if err != nil {
+		return nil, err
+	}
 	opts.SetCreateIfMissing(true)
+	opts.SetCreateIfMissingColumnFamilies(true)
 
-	db, err := grocksdb.OpenDb(opts, path)
+	// Every existing column family has to be opened, even when history mode
+	// has since been disabled
+	cfNames, err := grocksdb.ListColumnFamilies(opts, path)
+	if err != nil {
+		cfNames = []string{"default"}
+	}
+	if dbOpts.HistoryRetention > 0 && !slices.Contains(cfNames, historyCF) {
+		cfNames = append(cfNames, historyCF)
+	}
+
+	cfOpts := make([]*grocksdb.Options, len(cfNames))
+	for i, name := range cfNames {
+		cfOpts[i] = opts
+		if name == historyCF && dbOpts.HistoryRetention > 0 {
+			if cfOpts[i], err = b.options(dbOpts); err != nil {
+				return nil, err
+			}
+			cfOpts[i].SetCompactionFilter(&historyFilter{retention: dbOpts.HistoryRetention})
+		}
+	}
+
+	db, cfs, err := grocksdb.OpenDbColumnFamilies(opts, path, cfNames, cfOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	return &RocksDB{
-		db: db,
-		ro: grocksdb.NewDefaultReadOptions(),
-		wo: grocksdb.NewDefaultWriteOptions(),
-	}, nil
+	r := &RocksDB{
+		name: filepath.Base(path),
+		db:   db,
+		ro:   grocksdb.NewDefaultReadOptions(),
+		wo:   grocksdb.NewDefaultWriteOptions(),
+		cfs:  cfs,
+	}
+	if dbOpts.Keys != nil {
+		r.cipher = newValueCipher(dbOpts.Keys)
+		r.encrypt = dbOpts.Encrypt
+	}
+	if dbOpts.HistoryRetention > 0 {
+		r.history = cfs[slices.Index(cfNames, historyCF)]
+		r.historyRetention = dbOpts.HistoryRetention
+	}
+	r.version.Store(db.GetLatestSequenceNumber())
+	return r, nil
 }
 
 func (r *RocksDB) Close() {
 	r.ro.Destroy()
 	r.wo.Destroy()
+	for _, cf := range r.cfs {
+		cf.Destroy()
+	}
 	r.db.Close()
 }
 
-func (r *RocksDB) Put(key string, value []byte) error {
-	return r.db.Put(r.wo, []byte(key), value)
+// Put stores value under key and returns its new version
+func (r *RocksDB) Put(ctx context.Context, key string, value []byte) (uint64, error) {
+	unlock := r.locks.lock(key)
+	defer unlock()
+
+	return r.put(ctx, key, value)
 }
 
-func (r *RocksDB) Get(key string) ([]byte, bool, error) {
-	slice, err := r.db.Get(r.ro, []byte(key))
+// PutIfVersion stores value under key if the key's current version equals
+// version, and returns the new version. Version 0 requires the key to be
+// missing, so unversioned values written before versioning never match.
+func (r *RocksDB) PutIfVersion(ctx context.Context, key string, value []byte, version uint64) (uint64, error) {
+	unlock := r.locks.lock(key)
+	defer unlock()
+
+	current, currentVersion, exists, err := r.GetVersioned(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if currentVersion != version || (version == 0 && exists) {
+		return 0, &ConditionError{Value: current, Version: currentVersion, Found: exists}
+	}
+	return r.put(ctx, key, value)
+}
+
+// put writes value under a new version. The caller must hold the key's lock.
+func (r *RocksDB) put(ctx context.Context, key string, value []byte) (uint64, error) {
+	versions, err := r.commit(ctx, []Mutation{{Key: key, Value: value}})
+	if err != nil {
+		return 0, err
+	}
+	return versions[0], nil
+}
+
+func (r *RocksDB) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, _, exists, err := r.GetVersioned(ctx, key)
+	return value, exists, err
+}
+
+// GetVersioned returns the value of key along with its version
+func (r *RocksDB) GetVersioned(ctx context.Context, key string) (_ []byte, _ uint64, _ bool, err error) {
+	_, span := r.startSpan(ctx, "rocksdb.Get")
+	defer func() { tracing.End(span, err) }()
+	defer measure(ctx)()
+
+	raw, exists, err := r.getRaw(r.ro, key)
+	if err != nil || !exists {
+		return nil, 0, exists, err
+	}
+	rec := decodeValue(raw)
+	value, err := r.open(key, rec)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return value, rec.version, true, nil
+}
+
+// getRaw returns the stored envelope of key
+func (r *RocksDB) getRaw(ro *grocksdb.ReadOptions, key string) ([]byte, bool, error) {
+	slice, err := r.db.Get(ro, []byte(key))
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to get key: %w", err)
 	}
 	defer slice.Free()
 
-	if slice.Size() == 0 {
+	if !slice.Exists() {
 		return nil, false, nil
 	}
 
-	value := make([]byte, slice.Size())
-	copy(value, slice.Data())
-	return value, true, nil
+	raw := make([]byte, slice.Size())
+	copy(raw, slice.Data())
+	return raw, true, nil
+}
+
+func (r *RocksDB) Delete(ctx context.Context, key string) error {
+	unlock := r.locks.lock(key)
+	defer unlock()
+
+	_, err := r.commit(ctx, []Mutation{{Key: key, Delete: true}})
+	return err
+}
+
+// Write applies mutations atomically in a single write batch
+func (r *RocksDB) Write(ctx context.Context, mutations []Mutation) error {
+	keys := make([]string, len(mutations))
+	for i, m := range mutations {
+		keys[i] = m.Key
+	}
+	unlock := r.locks.lock(keys...)
+	defer unlock()
+
+	_, err := r.commit(ctx, mutations)
+	return err
+}
+
+// commit applies mutations in a single write batch and returns the version
+// assigned to each put. In history mode, the values being replaced are
+// moved to the history column family in the same batch. The caller must
+// hold the locks of all keys.
+func (r *RocksDB) commit(ctx context.Context, mutations []Mutation) (_ []uint64, err error) {
+	_, span := r.startSpan(ctx, "rocksdb.WriteBatch", attribute.Int("db.rocksdb.mutations", len(mutations)))
+	defer func() { tracing.End(span, err) }()
+	defer measure(ctx)()
+
+	wb := grocksdb.NewWriteBatch()
+	defer wb.Destroy()
+
+	versions := make([]uint64, len(mutations))
+	now := time.Now().UnixNano()
+	// pending tracks values written earlier in this batch, nil for deletes
+	var pending map[string][]byte
+	if r.history != nil {
+		pending = make(map[string][]byte)
+	}
+
+	for i, m := range mutations {
+		timestamp := now
+		if r.history != nil {
+			prev, exists := pending[m.Key]
+			if !exists {
+				var err error
+				if prev, _, err = r.getRaw(r.ro, m.Key); err != nil {
+					return nil, err
+				}
+			}
+			if prev != nil {
+				rec := decodeValue(prev)
+				// Keep commit timestamps of a key monotonic if the clock
+				// steps back
+				timestamp = max(timestamp, rec.timestamp)
+				wb.PutCF(r.history, historyKey(m.Key, timestamp, rec.version), prev)
+			}
+		}
+
+		if m.Delete {
+			wb.Delete([]byte(m.Key))
+			if pending != nil {
+				pending[m.Key] = nil
+			}
+			continue
+		}
+		versions[i] = r.version.Add(1)
+		raw, err := r.seal(m.Key, versions[i], timestamp, m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt value: %w", err)
+		}
+		wb.Put([]byte(m.Key), raw)
+		if pending != nil {
+			pending[m.Key] = raw
+		}
+	}
+
+	if err := r.db.Write(r.wo, wb); err != nil {
+		return nil, fmt.Errorf("failed to write batch: %w", err)
+	}
+	return versions, nil
+}
+
+// SSTSize returns the total size of the database's SST files
+func (r *RocksDB) SSTSize() uint64 {
+	var total uint64
+	for _, cf := range r.cfs {
+		size, _ := strconv.ParseUint(r.db.GetPropertyCF("rocksdb.total-sst-files-size", cf), 10, 64)
+		total += size
+	}
+	return total
+}
+
+// SetOptions changes mutable RocksDB options, such as
+// "write_buffer_size", of all column families of the open database
+func (r *RocksDB) SetOptions(options map[string]string) error {
+	if len(options) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = options[name]
+	}
+
+	for _, cf := range r.cfs {
+		if err := r.db.SetOptionsCF(cf, names, values); err != nil {
+			return fmt.Errorf("failed to set options: %w", err)
+		}
+	}
+	return nil
+}
+
+// BackgroundErrors returns the number of background errors, such as failed
+// flushes or compactions, since the database was opened
+func (r *RocksDB) BackgroundErrors() uint64 {
+	n, _ := r.db.GetIntProperty("rocksdb.background-errors")
+	return n
 }
 
-func (r *RocksDB) Delete(key string) error {
-	return r.db.Delete(r.wo, []byte(key))
+// statsProperties are the properties returned by Stats
+var statsProperties = []string{
+	"rocksdb.estimate-num-keys",
+	"rocksdb.estimate-live-data-size",
+	"rocksdb.total-sst-files-size",
+	"rocksdb.cur-size-all-mem-tables",
+	"rocksdb.num-immutable-mem-table",
+	"rocksdb.estimate-pending-compaction-bytes",
+	"rocksdb.num-running-flushes",
+	"rocksdb.num-running-compactions",
+	"rocksdb.num-snapshots",
+	"rocksdb.background-errors",
+	"rocksdb.block-cache-usage",
+	"rocksdb.block-cache-pinned-usage",
+	"rocksdb.levelstats",
+	"rocksdb.stats",
+}
+
+// Stats returns properties of the default column family, which holds the
+// current values, and of the database as a whole
+func (r *RocksDB) Stats() map[string]string {
+	stats := make(map[string]string, len(statsProperties))
+	for _, name := range statsProperties {
+		if value := r.db.GetProperty(name); value != "" {
+			stats[name] = value
+		}
+	}
+	return stats
 }
-func (r *RocksDB) GetByPrefix(prefix string) chan KeyValuePair {
+
+func (r *RocksDB) GetByPrefix(ctx context.Context, prefix string) chan KeyValuePair {
 	ch := make(chan KeyValuePair)
 
 	go func() {
 		defer close(ch)
+		defer measure(ctx)()
+
+		send := func(pair KeyValuePair) bool {
+			select {
+			case ch <- pair:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
 
 		it := r.db.NewIterator(r.ro)
 		defer it.Close()
 
 		prefixBytes := []byte(prefix)
-		for it.Seek(prefixBytes); it.Valid(); it.Next() {
+		r.seek(ctx, it, prefixBytes)
+		for ; it.Valid(); it.Next() {
 			key := it.Key()
 			if !hasPrefix(key.Data(), prefixBytes) {
 				key.Free()
@@ -82,49 +489,49 @@ func (r *RocksDB) GetByPrefix(prefix string) chan KeyValuePair {
 
 			value := it.Value()
 			keyStr := string(key.Data())
-			valueData := make([]byte, value.Size())
-			copy(valueData, value.Data())
+			raw := make([]byte, value.Size())
+			copy(raw, value.Data())
+			rec := decodeValue(raw)
 
 			key.Free()
 			value.Free()
 
-			ch <- KeyValuePair{
-				Key:   keyStr,
-				Value: valueData,
+			pair := KeyValuePair{Key: keyStr, Version: rec.version}
+			pair.Value, pair.Err = r.open(keyStr, rec)
+			if !send(pair) {
+				return
 			}
 		}
 
 		if err := it.Err(); err != nil {
-			ch <- KeyValuePair{Err: fmt.Errorf("iterator error: %w", err)}
+			send(KeyValuePair{Err: fmt.Errorf("iterator error: %w", err)})
 		}
 	}()
 
 	return ch
 }
 
-func (r *RocksDB) GetMultiple(keys []string) chan KeyValuePair {
+func (r *RocksDB) GetMultiple(ctx context.Context, keys []string) chan KeyValuePair {
 	ch := make(chan KeyValuePair)
 
 	go func() {
 		defer close(ch)
 
 		for _, key := range keys {
-			value, exists, err := r.Get(key)
-			if err != nil {
-				ch <- KeyValuePair{
-					Key: key,
-					Err: err,
-				}
+			value, version, exists, err := r.GetVersioned(ctx, key)
+			if err == nil && !exists {
 				continue
 			}
 
-			if !exists {
-				continue
+			pair := KeyValuePair{Key: key, Err: err}
+			if err == nil {
+				pair.Value = value
+				pair.Version = version
 			}
-
-			ch <- KeyValuePair{
-				Key:   key,
-				Value: value,
+			select {
+			case ch <- pair:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
@@ -132,6 +539,19 @@ func (r *RocksDB) GetMultiple(keys []string) chan KeyValuePair {
 	return ch
 }
 
+// startSpan starts a span for an operation on the database
+func (r *RocksDB) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.name", r.name))
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// seek positions it at the first key at or after target
+func (r *RocksDB) seek(ctx context.Context, it *grocksdb.Iterator, target []byte) {
+	_, span := r.startSpan(ctx, "rocksdb.Seek")
+	it.Seek(target)
+	tracing.End(span, it.Err())
+}
+
 func hasPrefix(s, prefix []byte) bool {
 	if len(s) < len(prefix) {
 		return false
@@ -143,3 +563,15 @@ func hasPrefix(s, prefix []byte) bool {
 	}
 	return true
 }
+
+// DestroyRocksDB removes the database files at path. The database must not
+// be open.
+func DestroyRocksDB(path string) error {
+	opts := grocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+
+	if err := grocksdb.DestroyDb(path, opts); err != nil {
+		return fmt.Errorf("failed to destroy database: %w", err)
+	}
+	return nil
+}
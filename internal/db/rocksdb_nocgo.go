@@ -0,0 +1,11 @@
+//go:build !cgo
+
+package db
+
+import "fmt"
+
+// newRocksDBBackend fails in builds without cgo, which only support the
+// memory backend
+func newRocksDBBackend(baseDir string, opts Options) (backend, error) {
+	return nil, fmt.Errorf("the %s backend requires cgo, use the %s backend", BackendRocksDB, BackendMemory)
+}
@@ -0,0 +1,37 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPutIfVersionZeroRejectsUnversionedValue(t *testing.T) {
+	r, err := NewRocksDB(t.TempDir(), DBOptions{})
+	if err != nil {
+		t.Fatalf("NewRocksDB: %v", err)
+	}
+	defer r.Close()
+
+	// A value written before versioning has no envelope
+	if err := r.db.Put(r.wo, []byte("legacy"), []byte("old")); err != nil {
+		t.Fatalf("raw put: %v", err)
+	}
+
+	ctx := context.Background()
+	_, err = r.PutIfVersion(ctx, "legacy", []byte("new"), 0)
+	var condErr *ConditionError
+	if !errors.As(err, &condErr) || !condErr.Found {
+		t.Fatalf("PutIfVersion(0) on an unversioned value: got %v, want a ConditionError for an existing key", err)
+	}
+	value, _, err := r.Get(ctx, "legacy")
+	if err != nil || string(value) != "old" {
+		t.Fatalf("Get = %q, %v; want the legacy value unchanged", value, err)
+	}
+
+	if _, err := r.PutIfVersion(ctx, "missing", []byte("new"), 0); err != nil {
+		t.Fatalf("PutIfVersion(0) on a missing key: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Scan streams key-value pairs in key order. The channel is closed when the
+// scan completes or ctx is done.
+func (r *RocksDB) Scan(ctx context.Context, opts ScanOptions) chan KeyValuePair {
+	ch := make(chan KeyValuePair)
+
+	go func() {
+		defer close(ch)
+		defer measure(ctx)()
+
+		send := func(pair KeyValuePair) bool {
+			select {
+			case ch <- pair:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		it := r.db.NewIterator(r.ro)
+		defer it.Close()
+
+		prefix := []byte(opts.Prefix)
+		start := opts.Start
+		if start < opts.Prefix {
+			start = opts.Prefix
+		}
+		r.seek(ctx, it, []byte(start))
+
+		var n uint64
+		for ; it.Valid() && (opts.Limit == 0 || n < opts.Limit); it.Next() {
+			key := it.Key()
+			if !hasPrefix(key.Data(), prefix) {
+				key.Free()
+				break
+			}
+			keyStr := string(key.Data())
+			key.Free()
+			if opts.End != "" && keyStr >= opts.End {
+				break
+			}
+
+			value := it.Value()
+			raw := make([]byte, value.Size())
+			copy(raw, value.Data())
+			value.Free()
+			rec := decodeValue(raw)
+
+			pair := KeyValuePair{Key: keyStr, Version: rec.version}
+			if !opts.KeysOnly {
+				plain, err := r.open(keyStr, rec)
+				if err != nil {
+					pair.Err = err
+				}
+				pair.Value = plain
+			}
+			if !send(pair) {
+				return
+			}
+			n++
+		}
+
+		if err := it.Err(); err != nil {
+			send(KeyValuePair{Err: fmt.Errorf("iterator error: %w", err)})
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backends a DBManager can store databases in
+const (
+	// BackendRocksDB stores each database in a RocksDB instance under the
+	// base directory. It requires cgo.
+	BackendRocksDB = "rocksdb"
+	// BackendMemory keeps each database in an ordered in-memory map, lost
+	// when the process exits. It needs neither cgo nor a data directory.
+	BackendMemory = "memory"
+)
+
+// Backends lists the supported backends
+var Backends = []string{BackendRocksDB, BackendMemory}
+
+// Store is a single database. RocksDB and MemoryStore implement it with the
+// same semantics: every put assigns the key a new version, greater than any
+// version assigned before; reads and scans return copies of the stored
+// values in key order; conditional writes fail with a *ConditionError and
+// increments out of range with a *CounterRangeError.
+type Store interface {
+	// Put stores value under key and returns its new version
+	Put(ctx context.Context, key string, value []byte) (uint64, error)
+	// PutIfVersion stores value under key if the key's current version
+	// equals version. Version 0 requires the key to be missing.
+	PutIfVersion(ctx context.Context, key string, value []byte, version uint64) (uint64, error)
+	// PutIfAbsent stores value under key if the key does not exist
+	PutIfAbsent(ctx context.Context, key string, value []byte) (uint64, error)
+	// CompareAndSwap replaces the value of key if it equals expected
+	CompareAndSwap(ctx context.Context, key string, expected, value []byte) (uint64, error)
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// GetVersioned returns the value of key along with its version
+	GetVersioned(ctx context.Context, key string) ([]byte, uint64, bool, error)
+	Delete(ctx context.Context, key string) error
+	// DeleteIfEquals deletes key if its current value equals expected
+	DeleteIfEquals(ctx context.Context, key string, expected []byte) error
+	// Write applies mutations atomically
+	Write(ctx context.Context, mutations []Mutation) error
+	// Increment applies increments to counters atomically and returns their
+	// new values
+	Increment(ctx context.Context, increments []Increment) ([]Counter, error)
+
+	// GetByPrefix streams the pairs whose key has prefix
+	GetByPrefix(ctx context.Context, prefix string) chan KeyValuePair
+	// GetMultiple streams the pairs of keys that exist, in the given order
+	GetMultiple(ctx context.Context, keys []string) chan KeyValuePair
+	// Scan streams pairs in key order until the scan completes or ctx is
+	// done
+	Scan(ctx context.Context, opts ScanOptions) chan KeyValuePair
+
+	// GetAsOf returns the value key held at time t, in history mode
+	GetAsOf(ctx context.Context, key string, t time.Time) (HistoryEntry, bool, error)
+	// GetHistory streams the current and retained previous values of key,
+	// newest first, in history mode
+	GetHistory(ctx context.Context, key string) chan HistoryEntry
+
+	// RotateKey re-encrypts values not encrypted with the current key
+	RotateKey(ctx context.Context, progress func(uint64)) error
+	// SSTSize returns the bytes the database occupies
+	SSTSize() uint64
+	// SetOptions changes mutable RocksDB options of the open database
+	SetOptions(options map[string]string) error
+	// BackgroundErrors returns the number of background errors since the
+	// database was opened
+	BackgroundErrors() uint64
+	// Stats returns properties describing the database by their RocksDB
+	// names, e.g. "rocksdb.estimate-num-keys"
+	Stats() map[string]string
+	Close()
+}
+
+// KeyValuePair represents a key-value pair with optional error
+type KeyValuePair struct {
+	Key     string
+	Value   []byte
+	Version uint64
+	Err     error
+}
+
+// Mutation is a single put or delete applied as part of a batch
+type Mutation struct {
+	Key    string
+	Value  []byte
+	Delete bool
+}
+
+// DBOptions configures a single database
+type DBOptions struct {
+	// HistoryRetention enables history mode, keeping superseded values for
+	// time-travel reads for this long. Zero disables history.
+	HistoryRetention time.Duration
+
+	// Keys decrypts encrypted values, and encrypts new values if Encrypt
+	// is set
+	Keys    KeyProvider
+	Encrypt bool
+
+	// RocksDB holds RocksDB options by name, e.g. "write_buffer_size", as
+	// accepted by RocksDB's options strings
+	RocksDB map[string]string
+}
+
+// ScanOptions selects the key-value pairs returned by Scan
+type ScanOptions struct {
+	// Prefix restricts the scan to keys with this prefix, if set
+	Prefix string
+	// Start is the first key, inclusive, and End the last key, exclusive.
+	// Either may be empty for an open range.
+	Start, End string
+	// Limit is the maximum number of pairs returned, 0 for no limit
+	Limit uint64
+	// KeysOnly skips reading values; versions are still returned
+	KeysOnly bool
+}
+
+// ConditionError is returned by conditional writes whose precondition does
+// not hold. It carries the current value and version of the key.
+type ConditionError struct {
+	Value   []byte
+	Version uint64
+	Found   bool
+}
+
+func (e *ConditionError) Error() string {
+	if !e.Found {
+		return "condition failed: key not found"
+	}
+	return "condition failed: current value does not match"
+}
+
+var (
+	// ErrHistoryDisabled is returned by time-travel reads on databases
+	// without history mode
+	ErrHistoryDisabled = errors.New("history is not enabled for this database")
+	// ErrBeforeRetention is returned by time-travel reads older than the
+	// history retention window
+	ErrBeforeRetention = errors.New("timestamp is before the history retention window")
+)
+
+// HistoryEntry is a value a key held during part of its history
+type HistoryEntry struct {
+	Value   []byte
+	Version uint64
+	// Timestamp is the commit time, zero for values written before commit
+	// times were recorded
+	Timestamp time.Time
+	// SupersededAt is when the value was replaced or deleted, zero for the
+	// current value
+	SupersededAt time.Time
+	Err          error
+}
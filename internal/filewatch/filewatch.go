@@ -0,0 +1,74 @@
+// Package filewatch detects changes to configuration files so they can be
+// reloaded without a restart.
+package filewatch
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Watcher detects modifications of a set of files by comparing their
+// modification times, checking at most once per interval
+type Watcher struct {
+	files    []string
+	interval time.Duration
+
+	mu        sync.Mutex
+	modTimes  []time.Time
+	lastCheck time.Time
+}
+
+// New returns a watcher for files, recording their current modification
+// times
+func New(interval time.Duration, files ...string) (*Watcher, error) {
+	modTimes, err := stat(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		files:     files,
+		interval:  interval,
+		modTimes:  modTimes,
+		lastCheck: time.Now(),
+	}, nil
+}
+
+// Changed reports whether any file was modified since the last change was
+// reported. Files that cannot be read are treated as unchanged, so a file
+// being replaced does not trigger a reload until it is back in place.
+func (w *Watcher) Changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(w.lastCheck) < w.interval {
+		return false
+	}
+	w.lastCheck = now
+
+	modTimes, err := stat(w.files)
+	if err != nil {
+		return false
+	}
+	for i := range modTimes {
+		if !modTimes[i].Equal(w.modTimes[i]) {
+			w.modTimes = modTimes
+			return true
+		}
+	}
+	return false
+}
+
+func stat(files []string) ([]time.Time, error) {
+	modTimes := make([]time.Time, len(files))
+	for i, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", f, err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	pb "rocksdb-service/api/proto"
+)
+
+// aliasBody is the JSON body of an alias swap
+type aliasBody struct {
+	Database string `json:"database"`
+}
+
+func (g *Gateway) listDatabases(w http.ResponseWriter, r *http.Request) {
+	resp, ok := call(w, r, g.client.ListDatabases, &pb.ListDatabasesRequest{})
+	if ok {
+		writeProto(w, resp)
+	}
+}
+
+// swapAlias points an alias at the database given in the body
+func (g *Gateway) swapAlias(w http.ResponseWriter, r *http.Request) {
+	var body aliasBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+		badRequest(w, fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+	resp, ok := call(w, r, g.client.SwapAlias, &pb.SwapAliasRequest{Alias: r.PathValue("alias"), DatabaseName: body.Database})
+	if !ok {
+		return
+	}
+	if !resp.Success {
+		responseError(w, resp.Error)
+		return
+	}
+	writeProto(w, resp)
+}
+
+// usage reports quota usage, filtered by the principal and database query
+// parameters
+func (g *Gateway) usage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	resp, ok := call(w, r, g.client.GetUsage, &pb.GetUsageRequest{
+		Principal:    query.Get("principal"),
+		DatabaseName: query.Get("database"),
+	})
+	if ok {
+		writeProto(w, resp)
+	}
+}
+
+func (g *Gateway) rotateKey(w http.ResponseWriter, r *http.Request) {
+	resp, ok := call(w, r, g.client.RotateKey, &pb.RotateKeyRequest{DatabaseName: r.PathValue("database")})
+	if !ok {
+		return
+	}
+	if !resp.Success {
+		responseError(w, resp.Error)
+		return
+	}
+	writeProto(w, resp)
+}
+
+func (g *Gateway) stats(w http.ResponseWriter, r *http.Request) {
+	resp, ok := call(w, r, g.client.GetStats, &pb.GetStatsRequest{DatabaseName: r.PathValue("database")})
+	if ok {
+		writeProto(w, resp)
+	}
+}
+
+// jobs lists background jobs, of the database query parameter if set
+func (g *Gateway) jobs(w http.ResponseWriter, r *http.Request) {
+	resp, ok := call(w, r, g.client.ListJobs, &pb.ListJobsRequest{DatabaseName: r.URL.Query().Get("database")})
+	if ok {
+		writeProto(w, resp)
+	}
+}
@@ -0,0 +1,217 @@
+// Package gateway serves a REST API over HTTP, translating requests into
+// calls of the gRPC service so authentication, ACLs, quotas and request
+// logging apply to them as to any other client.
+//
+// Key endpoints exchange raw bytes, or JSON with base64 values when the
+// request asks for application/json. Admin endpoints reply with the RPC's
+// response message in protobuf JSON.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/accesslog"
+)
+
+// maxValueSize is the largest value accepted by PUT, the default maximum
+// message size of the gRPC server less room for the rest of the request
+const maxValueSize = 4<<20 - 64<<10
+
+// Gateway is an http.Handler serving the REST API under /v1/
+type Gateway struct {
+	client pb.RocksDBServiceClient
+	mux    *http.ServeMux
+}
+
+// New returns a gateway calling client
+func New(client pb.RocksDBServiceClient) *Gateway {
+	g := &Gateway{client: client, mux: http.NewServeMux()}
+	g.mux.HandleFunc("GET /v1/db/{database}/keys/{key...}", g.getKey)
+	g.mux.HandleFunc("PUT /v1/db/{database}/keys/{key...}", g.putKey)
+	g.mux.HandleFunc("DELETE /v1/db/{database}/keys/{key...}", g.deleteKey)
+	g.mux.HandleFunc("GET /v1/db/{database}/keys", g.scan)
+	g.mux.HandleFunc("POST /v1/db/{database}/rotate-key", g.rotateKey)
+	g.mux.HandleFunc("GET /v1/db/{database}/stats", g.stats)
+	g.mux.HandleFunc("GET /v1/databases", g.listDatabases)
+	g.mux.HandleFunc("PUT /v1/aliases/{alias}", g.swapAlias)
+	g.mux.HandleFunc("GET /v1/usage", g.usage)
+	g.mux.HandleFunc("GET /v1/jobs", g.jobs)
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// outgoing returns the context of calls made for r, forwarding its bearer
+// token and the client's address
+func outgoing(r *http.Request) context.Context {
+	ctx := accesslog.WithClientAddress(r.Context(), r.RemoteAddr)
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+	}
+	return ctx
+}
+
+// errorBody is the JSON body of error responses
+type errorBody struct {
+	Error string `json:"error"`
+	// Code is the name of the gRPC status code, e.g. "NOT_FOUND"
+	Code string `json:"code"`
+	// Condition holds the current state of the key when a conditional
+	// write failed
+	Condition *conditionBody `json:"condition,omitempty"`
+}
+
+type conditionBody struct {
+	Found          bool   `json:"found"`
+	CurrentVersion uint64 `json:"current_version"`
+	// CurrentValue is base64 encoded
+	CurrentValue []byte `json:"current_value,omitempty"`
+}
+
+// httpStatus maps gRPC status codes to HTTP status codes
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError writes err as a JSON error response. trailer is the trailer
+// of the failed call, whose retry-after becomes the Retry-After header.
+func writeError(w http.ResponseWriter, err error, trailer metadata.MD) {
+	st := status.Convert(err)
+	body := errorBody{Error: st.Message(), Code: codeName(st.Code())}
+	for _, detail := range st.Details() {
+		if cond, ok := detail.(*pb.ConditionFailure); ok {
+			body.Condition = &conditionBody{
+				Found:          cond.Found,
+				CurrentVersion: cond.CurrentVersion,
+				CurrentValue:   cond.CurrentValue,
+			}
+		}
+	}
+	if retry := trailer.Get("retry-after"); len(retry) > 0 {
+		w.Header().Set("Retry-After", retry[0])
+	}
+	writeJSON(w, httpStatus(st.Code()), body)
+}
+
+// codeName returns the canonical name of a code, e.g. "NOT_FOUND"
+func codeName(code codes.Code) string {
+	// String returns the camel case name, e.g. "NotFound"
+	name := code.String()
+	out := make([]byte, 0, len(name)+4)
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if ch >= 'A' && ch <= 'Z' {
+			if i > 0 && name[i-1] >= 'a' {
+				out = append(out, '_')
+			}
+		} else {
+			ch -= 'a' - 'A'
+		}
+		out = append(out, ch)
+	}
+	return string(out)
+}
+
+// responseError reports the error field of a response
+func responseError(w http.ResponseWriter, message string) {
+	writeError(w, status.Error(codes.Internal, message), nil)
+}
+
+// badRequest reports an invalid request
+func badRequest(w http.ResponseWriter, message string) {
+	writeError(w, status.Error(codes.InvalidArgument, message), nil)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// protoJSON marshals responses of admin endpoints
+var protoJSON = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+func writeProto(w http.ResponseWriter, m proto.Message) {
+	data, err := protoJSON.Marshal(m)
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	// protojson varies its whitespace between runs; compacting keeps
+	// responses stable
+	var out bytes.Buffer
+	json.Compact(&out, data)
+	out.WriteByte('\n')
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out.Bytes())
+}
+
+// call runs a unary call with the request's token, reporting a failure.
+// It returns false if the call failed.
+func call[Req, Resp any](w http.ResponseWriter, r *http.Request, rpc func(context.Context, Req, ...grpc.CallOption) (Resp, error), req Req) (Resp, bool) {
+	var trailer metadata.MD
+	resp, err := rpc(outgoing(r), req, grpc.Trailer(&trailer))
+	if err != nil {
+		writeError(w, err, trailer)
+		return resp, false
+	}
+	return resp, true
+}
+
+// etag formats a version as an entity tag
+func etag(version uint64) string {
+	return strconv.Quote(strconv.FormatUint(version, 10))
+}
+
+// parseETag parses an entity tag written by etag, also accepting the
+// version without quotes
+func parseETag(tag string) (uint64, error) {
+	if unquoted, err := strconv.Unquote(tag); err == nil {
+		tag = unquoted
+	}
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid entity tag: use the version returned in ETag")
+	}
+	return version, nil
+}
@@ -0,0 +1,236 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+)
+
+// item is the JSON form of a key-value pair. Value is base64 encoded, and
+// null when only keys are scanned.
+type item struct {
+	Key     string `json:"key"`
+	Value   []byte `json:"value"`
+	Version uint64 `json:"version"`
+}
+
+// putResult is the JSON response of a PUT
+type putResult struct {
+	Key     string `json:"key"`
+	Version uint64 `json:"version"`
+}
+
+// putResponse is the response of Put and PutIfAbsent
+type putResponse interface {
+	GetSuccess() bool
+	GetError() string
+	GetVersion() uint64
+}
+
+// putBody is the JSON body of a PUT
+type putBody struct {
+	// Value is base64 encoded
+	Value []byte `json:"value"`
+}
+
+// wantsJSON reports whether r accepts a JSON response rather than raw
+// bytes
+func wantsJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// isJSON reports whether the body of r is JSON
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// getKey replies with the raw value, its version in ETag, or with an item
+// if JSON is accepted
+func (g *Gateway) getKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	resp, ok := call(w, r, g.client.Get, &pb.GetRequest{DatabaseName: r.PathValue("database"), Key: key})
+	if !ok {
+		return
+	}
+	if resp.Error != "" {
+		responseError(w, resp.Error)
+		return
+	}
+	if !resp.Found {
+		writeError(w, status.Errorf(codes.NotFound, "key %q not found", key), nil)
+		return
+	}
+
+	w.Header().Set("ETag", etag(resp.Version))
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, item{Key: key, Value: resp.Value, Version: resp.Version})
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Value)))
+	w.Write(resp.Value)
+}
+
+// putKey stores the raw body, or the base64 value of a JSON body. If-Match
+// makes the write conditional on the version, and "If-None-Match: *" on
+// the key not existing.
+func (g *Gateway) putKey(w http.ResponseWriter, r *http.Request) {
+	limit := int64(maxValueSize)
+	if isJSON(r) {
+		limit = base64Len(maxValueSize) + 4096
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorBody{
+			Error: fmt.Sprintf("value exceeds %d bytes", maxValueSize),
+			Code:  codeName(codes.InvalidArgument),
+		})
+		return
+	}
+	if err != nil {
+		badRequest(w, fmt.Sprintf("failed to read body: %v", err))
+		return
+	}
+
+	value := data
+	if isJSON(r) {
+		var body putBody
+		if err := json.Unmarshal(data, &body); err != nil {
+			badRequest(w, fmt.Sprintf("invalid JSON body: %v", err))
+			return
+		}
+		value = body.Value
+	}
+
+	key := r.PathValue("key")
+	req := &pb.PutRequest{DatabaseName: r.PathValue("database"), Key: key, Value: value}
+	if match := r.Header.Get("If-Match"); match != "" {
+		version, err := parseETag(match)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		req.IfVersion = &version
+	}
+
+	var (
+		resp putResponse
+		ok   bool
+	)
+	if r.Header.Get("If-None-Match") == "*" {
+		if req.IfVersion != nil {
+			badRequest(w, "If-Match and If-None-Match are mutually exclusive")
+			return
+		}
+		resp, ok = call(w, r, g.client.PutIfAbsent, &pb.PutIfAbsentRequest{DatabaseName: req.DatabaseName, Key: key, Value: value})
+	} else {
+		resp, ok = call(w, r, g.client.Put, req)
+	}
+	if !ok {
+		return
+	}
+	if !resp.GetSuccess() {
+		responseError(w, resp.GetError())
+		return
+	}
+	w.Header().Set("ETag", etag(resp.GetVersion()))
+	writeJSON(w, http.StatusOK, putResult{Key: key, Version: resp.GetVersion()})
+}
+
+// base64Len returns the length of n bytes in base64
+func base64Len(n int64) int64 {
+	return (n + 2) / 3 * 4
+}
+
+func (g *Gateway) deleteKey(w http.ResponseWriter, r *http.Request) {
+	resp, ok := call(w, r, g.client.Delete, &pb.DeleteRequest{DatabaseName: r.PathValue("database"), Key: r.PathValue("key")})
+	if !ok {
+		return
+	}
+	if !resp.Success {
+		responseError(w, resp.Error)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scan streams the selected pairs as newline-delimited JSON items, flushing
+// each line so that clients receive pairs as they are read. An error after
+// the response has started is sent as a final line holding an error body.
+func (g *Gateway) scan(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &pb.ScanRequest{
+		DatabaseName: r.PathValue("database"),
+		Prefix:       query.Get("prefix"),
+		StartKey:     query.Get("start"),
+		EndKey:       query.Get("end"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			badRequest(w, fmt.Sprintf("invalid limit %q", limit))
+			return
+		}
+		req.Limit = n
+	}
+	if keysOnly := query.Get("keys_only"); keysOnly != "" {
+		b, err := strconv.ParseBool(keysOnly)
+		if err != nil {
+			badRequest(w, fmt.Sprintf("invalid keys_only %q", keysOnly))
+			return
+		}
+		req.KeysOnly = b
+	}
+
+	stream, err := g.client.Scan(outgoing(r), req)
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	// Errors before the first pair still get an error status
+	resp, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		writeError(w, err, stream.Trailer())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for err != io.EOF {
+		if err != nil {
+			st := status.Convert(err)
+			enc.Encode(errorBody{Error: st.Message(), Code: codeName(st.Code())})
+			return
+		}
+		if resp.Error != "" {
+			enc.Encode(errorBody{Error: resp.Error, Code: codeName(codes.Internal)})
+			return
+		}
+		if err := enc.Encode(item{Key: resp.Key, Value: resp.Value, Version: resp.Version}); err != nil {
+			// The client went away
+			return
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return
+		}
+		resp, err = stream.Recv()
+	}
+}
@@ -0,0 +1,116 @@
+package gateway_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/gateway"
+)
+
+func newTestGateway(t *testing.T) *gateway.Gateway {
+	t.Helper()
+	srv, err := embedded.NewServer(embedded.Options{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	cc, err := srv.Dial()
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return gateway.New(pb.NewRocksDBServiceClient(cc))
+}
+
+// serve sends a request to g and returns the response
+func serve(t *testing.T, g http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	return w
+}
+
+func TestPutConditions(t *testing.T) {
+	g := newTestGateway(t)
+	absent := http.Header{"If-None-Match": {"*"}}
+
+	steps := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"If-None-Match on a missing key", absent, http.StatusOK},
+		{"If-None-Match on an existing key", absent, http.StatusPreconditionFailed},
+		{"If-Match with the current version", http.Header{"If-Match": {`"1"`}}, http.StatusOK},
+		{"If-Match with a stale version", http.Header{"If-Match": {`"1"`}}, http.StatusPreconditionFailed},
+		{"If-Match and If-None-Match", http.Header{"If-Match": {`"2"`}, "If-None-Match": {"*"}}, http.StatusBadRequest},
+	}
+	for _, step := range steps {
+		if w := serve(t, g, http.MethodPut, "/v1/db/db/keys/k", "v", step.header); w.Code != step.want {
+			t.Errorf("%s: status %d, want %d: %s", step.name, w.Code, step.want, w.Body)
+		}
+	}
+
+	w := serve(t, g, http.MethodGet, "/v1/db/db/keys/k", "", nil)
+	if body, _ := io.ReadAll(w.Body); w.Code != http.StatusOK || string(body) != "v" || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("GET = %d %q, ETag %s; want 200 \"v\", ETag \"2\"", w.Code, body, w.Header().Get("ETag"))
+	}
+}
+
+// blockingScanClient returns scans that send one pair and then wait for
+// release to end
+type blockingScanClient struct {
+	pb.RocksDBServiceClient
+	release chan struct{}
+}
+
+func (c blockingScanClient) Scan(ctx context.Context, req *pb.ScanRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.ScanResponse], error) {
+	return &blockingScan{release: c.release}, nil
+}
+
+type blockingScan struct {
+	grpc.ClientStream
+	release chan struct{}
+	sent    bool
+}
+
+func (s *blockingScan) Recv() (*pb.ScanResponse, error) {
+	if !s.sent {
+		s.sent = true
+		return &pb.ScanResponse{Key: "a", Value: []byte("1"), Version: 1}, nil
+	}
+	<-s.release
+	return nil, io.EOF
+}
+
+func TestScanFlushesLines(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(gateway.New(blockingScanClient{release: release}))
+	defer ts.Close()
+	defer close(release)
+
+	hc := &http.Client{Timeout: 5 * time.Second}
+	resp, err := hc.Get(ts.URL + "/v1/db/db/keys")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	// The scan has not ended, so the line is only read if it was flushed
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if want := `{"key":"a","value":"MQ==","version":1}` + "\n"; err != nil || line != want {
+		t.Errorf("first line = %q, %v; want %q", line, err, want)
+	}
+}
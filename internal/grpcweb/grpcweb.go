@@ -0,0 +1,166 @@
+// Package grpcweb serves gRPC-Web requests from browsers with a gRPC
+// server, without a separate proxy. Both the binary format
+// (application/grpc-web) and the base64 text format
+// (application/grpc-web-text) are supported over HTTP/1.1 and HTTP/2, for
+// unary and server-streaming RPCs.
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	contentTypeWeb  = "application/grpc-web"
+	contentTypeText = "application/grpc-web-text"
+
+	// trailerFlag marks the frame holding the trailers
+	trailerFlag = 0x80
+)
+
+// IsGRPCWebRequest reports whether r is a gRPC-Web request
+func IsGRPCWebRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeWeb)
+}
+
+// Handler translates gRPC-Web requests into gRPC requests of a server
+type Handler struct {
+	server *grpc.Server
+}
+
+// New returns a handler serving gRPC-Web requests with server. The server's
+// interceptors apply to them; its transport credentials do not, TLS being
+// up to the HTTP server.
+func New(server *grpc.Server) *Handler {
+	return &Handler{server: server}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !IsGRPCWebRequest(r) {
+		http.Error(w, "gRPC-Web requests must be POSTs with an application/grpc-web content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Present the request as gRPC over HTTP/2, as grpc.Server.ServeHTTP
+	// requires
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, contentTypeText)
+	subtype := "+proto"
+	if i := strings.IndexAny(contentType, "+;"); i >= 0 && contentType[i] == '+' {
+		subtype = contentType[i:]
+		if j := strings.IndexByte(subtype, ';'); j >= 0 {
+			subtype = subtype[:j]
+		}
+	}
+
+	req := r.Clone(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2.0"
+	req.Header.Set("Content-Type", "application/grpc"+subtype)
+	req.Header.Del("Content-Length")
+	if text {
+		req.Body = io.NopCloser(base64.NewDecoder(base64.StdEncoding, r.Body))
+	}
+
+	rw := &responseWriter{
+		w:           w,
+		header:      make(http.Header),
+		contentType: contentTypeWeb + subtype,
+	}
+	if text {
+		rw.contentType = contentTypeText + subtype
+		rw.enc = base64.NewEncoder(base64.StdEncoding, w)
+	}
+	h.server.ServeHTTP(rw, req)
+	rw.finish()
+}
+
+// responseWriter passes the response of the gRPC server on in gRPC-Web
+// framing: trailers are sent as a final frame of the body, and in text
+// mode the body is base64 encoded.
+type responseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	contentType string
+	wroteHeader bool
+
+	// enc encodes the body in text mode. It is closed on every flush, so
+	// the body is a sequence of padded base64 chunks.
+	enc io.WriteCloser
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	h := rw.w.Header()
+	for k, v := range rw.header {
+		if k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		h[k] = v
+	}
+	h.Set("Content-Type", rw.contentType)
+	h.Del("Content-Length")
+	rw.w.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+	if rw.enc != nil {
+		return rw.enc.Write(b)
+	}
+	return rw.w.Write(b)
+}
+
+func (rw *responseWriter) Flush() {
+	rw.WriteHeader(http.StatusOK)
+	if rw.enc != nil {
+		rw.enc.Close()
+		rw.enc = base64.NewEncoder(base64.StdEncoding, rw.w)
+	}
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish writes the trailers set by the server as the trailer frame
+func (rw *responseWriter) finish() {
+	rw.WriteHeader(http.StatusOK)
+
+	declared := make(map[string]bool)
+	for _, v := range rw.header.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			declared[http.CanonicalHeaderKey(strings.TrimSpace(k))] = true
+		}
+	}
+	var trailers bytes.Buffer
+	for k, vv := range rw.header {
+		name, prefixed := strings.CutPrefix(k, http.TrailerPrefix)
+		if !prefixed && !declared[k] {
+			continue
+		}
+		for _, v := range vv {
+			trailers.WriteString(strings.ToLower(name))
+			trailers.WriteString(": ")
+			trailers.WriteString(v)
+			trailers.WriteString("\r\n")
+		}
+	}
+
+	frame := make([]byte, 5, 5+trailers.Len())
+	frame[0] = trailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(trailers.Len()))
+	rw.Write(append(frame, trailers.Bytes()...))
+	rw.Flush()
+}
@@ -0,0 +1,196 @@
+package grpcweb_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+	"rocksdb-service/internal/grpcweb"
+	"rocksdb-service/internal/server"
+)
+
+var contentTypes = []string{"application/grpc-web+proto", "application/grpc-web-text+proto"}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dbManager, err := db.NewDBManager(t.TempDir(), db.Options{Backend: db.BackendMemory})
+	if err != nil {
+		t.Fatalf("NewDBManager: %v", err)
+	}
+	t.Cleanup(dbManager.Close)
+	gs := grpc.NewServer()
+	pb.RegisterRocksDBServiceServer(gs, server.New(dbManager, server.Options{}))
+	ts := httptest.NewServer(grpcweb.New(gs))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// response is a gRPC-Web response split into its frames
+type response struct {
+	messages [][]byte
+	trailers map[string]string
+}
+
+// call sends req to method in the given content type
+func call(t *testing.T, ts *httptest.Server, contentType, method string, req proto.Message) response {
+	t.Helper()
+	msg, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+	body = append(body, msg...)
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	resp, err := http.Post(ts.URL+"/rocksdb.RocksDBService/"+method, contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != contentType {
+		t.Fatalf("%s: status %d, Content-Type %q", method, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading the response: %v", err)
+	}
+	if text {
+		data = decodeChunks(t, data)
+	}
+	return parseFrames(t, data)
+}
+
+// decodeChunks decodes a text body, a sequence of padded base64 chunks.
+// Every chunk is a whole number of 4-byte groups, so groups are decoded
+// one by one.
+func decodeChunks(t *testing.T, data []byte) []byte {
+	t.Helper()
+	if len(data)%4 != 0 {
+		t.Fatalf("text body of %d bytes is not base64", len(data))
+	}
+	var out []byte
+	for ; len(data) > 0; data = data[4:] {
+		group, err := base64.StdEncoding.DecodeString(string(data[:4]))
+		if err != nil {
+			t.Fatalf("decoding the text body: %v", err)
+		}
+		out = append(out, group...)
+	}
+	return out
+}
+
+func parseFrames(t *testing.T, data []byte) response {
+	t.Helper()
+	var resp response
+	for len(data) > 0 {
+		if resp.trailers != nil {
+			t.Fatal("frames after the trailer frame")
+		}
+		if len(data) < 5 {
+			t.Fatalf("truncated frame header %q", data)
+		}
+		flag, n := data[0], binary.BigEndian.Uint32(data[1:5])
+		if uint32(len(data)-5) < n {
+			t.Fatalf("frame of %d bytes with %d left", n, len(data)-5)
+		}
+		payload := data[5 : 5+n]
+		data = data[5+n:]
+
+		if flag&0x80 == 0 {
+			resp.messages = append(resp.messages, payload)
+			continue
+		}
+		resp.trailers = make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSuffix(string(payload), "\r\n"), "\r\n") {
+			name, value, _ := strings.Cut(line, ": ")
+			resp.trailers[name] = value
+		}
+	}
+	if resp.trailers == nil {
+		t.Fatal("no trailer frame")
+	}
+	return resp
+}
+
+func TestUnary(t *testing.T) {
+	ts := newTestServer(t)
+	for _, contentType := range contentTypes {
+		t.Run(contentType, func(t *testing.T) {
+			resp := call(t, ts, contentType, "Put", &pb.PutRequest{DatabaseName: "db", Key: "k", Value: []byte(contentType)})
+			if resp.trailers["grpc-status"] != "0" || len(resp.messages) != 1 {
+				t.Fatalf("Put = %d messages, trailers %v", len(resp.messages), resp.trailers)
+			}
+
+			resp = call(t, ts, contentType, "Get", &pb.GetRequest{DatabaseName: "db", Key: "k"})
+			if resp.trailers["grpc-status"] != "0" || len(resp.messages) != 1 {
+				t.Fatalf("Get = %d messages, trailers %v", len(resp.messages), resp.trailers)
+			}
+			var got pb.GetResponse
+			if err := proto.Unmarshal(resp.messages[0], &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !got.Found || string(got.Value) != contentType {
+				t.Errorf("Get = %v, want %q", &got, contentType)
+			}
+		})
+	}
+}
+
+func TestServerStreaming(t *testing.T) {
+	ts := newTestServer(t)
+	keys := []string{"k1", "k2", "k3"}
+	for _, key := range keys {
+		call(t, ts, contentTypes[0], "Put", &pb.PutRequest{DatabaseName: "db", Key: key, Value: []byte("v")})
+	}
+
+	for _, contentType := range contentTypes {
+		t.Run(contentType, func(t *testing.T) {
+			resp := call(t, ts, contentType, "StreamGet", &pb.StreamGetRequest{
+				DatabaseName: "db",
+				Query:        &pb.StreamGetRequest_Prefix{Prefix: "k"},
+			})
+			if resp.trailers["grpc-status"] != "0" {
+				t.Fatalf("trailers = %v", resp.trailers)
+			}
+			var got []string
+			for _, msg := range resp.messages {
+				var item pb.StreamGetResponse
+				if err := proto.Unmarshal(msg, &item); err != nil {
+					t.Fatalf("Unmarshal: %v", err)
+				}
+				got = append(got, item.Key)
+			}
+			if strings.Join(got, ",") != strings.Join(keys, ",") {
+				t.Errorf("StreamGet = %v, want %v", got, keys)
+			}
+		})
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	ts := newTestServer(t)
+	for _, contentType := range contentTypes {
+		t.Run(contentType, func(t *testing.T) {
+			// Stats of a missing database fail with NotFound
+			resp := call(t, ts, contentType, "GetStats", &pb.GetStatsRequest{DatabaseName: "missing"})
+			if len(resp.messages) != 0 {
+				t.Errorf("%d messages, want none", len(resp.messages))
+			}
+			if resp.trailers["grpc-status"] != "5" || !strings.Contains(resp.trailers["grpc-message"], "not found") {
+				t.Errorf("trailers = %v, want grpc-status 5 with a message", resp.trailers)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package quota
+
+import (
+	"context"
+	"time"
+)
+
+// bucket is a token bucket refilled at a rate given on each call, holding
+// at most one second worth of tokens. Byte charges may exceed the balance,
+// leaving the bucket in debt until it refills.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64) *bucket {
+	return &bucket{tokens: rate, last: time.Now()}
+}
+
+func (b *bucket) refill(rate float64, now time.Time) {
+	b.tokens = min(rate, b.tokens+rate*now.Sub(b.last).Seconds())
+	b.last = now
+}
+
+// take deducts n tokens if enough are available, where requests larger
+// than the burst only need a full bucket. Otherwise it returns how long
+// until they will be. A zero rate is unlimited.
+func (b *bucket) take(rate, n float64, now time.Time) (bool, time.Duration) {
+	if rate <= 0 {
+		return true, 0
+	}
+	b.refill(rate, now)
+	need := min(n, rate)
+	if b.tokens < need {
+		return false, time.Duration((need - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens -= n
+	return true, 0
+}
+
+// charge deducts n tokens unconditionally, for usage known only after the
+// fact
+func (b *bucket) charge(rate, n float64, now time.Time) {
+	if rate <= 0 {
+		return
+	}
+	b.refill(rate, now)
+	b.tokens -= n
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
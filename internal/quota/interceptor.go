@@ -0,0 +1,182 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/auth"
+)
+
+// serviceMethodPrefix selects the RPCs subject to quotas
+const serviceMethodPrefix = "/rocksdb.RocksDBService/"
+
+// classify returns the database a request addresses, whether it writes, and
+// whether the write can grow the database
+func classify(req any) (database string, write, grows bool) {
+	if r, ok := req.(interface{ GetDatabaseName() string }); ok {
+		database = r.GetDatabaseName()
+	}
+	switch r := req.(type) {
+	case *pb.PutRequest, *pb.CompareAndSwapRequest, *pb.PutIfAbsentRequest,
+		*pb.IncrementRequest, *pb.IncrementManyRequest:
+		return database, true, true
+	case *pb.DeleteRequest, *pb.DeleteIfEqualsRequest:
+		return database, true, false
+	case *pb.StreamWriteRequest:
+		return database, true, !r.Delete
+	}
+	return database, false, false
+}
+
+// exceededStatus converts a quota error into a RESOURCE_EXHAUSTED status,
+// along with the retry-after trailer to set, if any
+func exceededStatus(err error) (metadata.MD, error) {
+	var qerr *ExceededError
+	if !errors.As(err, &qerr) {
+		return nil, err
+	}
+	st := status.New(codes.ResourceExhausted, qerr.Error())
+	if qerr.RetryAfter <= 0 {
+		return nil, st.Err()
+	}
+
+	if detailed, detErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(qerr.RetryAfter)}); detErr == nil {
+		st = detailed
+	}
+	seconds := int(math.Ceil(qerr.RetryAfter.Seconds()))
+	return metadata.Pairs("retry-after", strconv.Itoa(max(seconds, 1))), st.Err()
+}
+
+// UnaryInterceptor admits unary RPCs and charges their response size to
+// the read budget. It must run after authentication.
+func (m *Manager) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, serviceMethodPrefix) {
+			return handler(ctx, req)
+		}
+		principal, _ := auth.PrincipalFromContext(ctx)
+		database, write, grows := classify(req)
+
+		var writeBytes int64
+		if msg, ok := req.(proto.Message); ok && write {
+			writeBytes = int64(proto.Size(msg))
+		}
+		release, err := m.Admit(Request{
+			Principal:    principal,
+			Database:     database,
+			WriteBytes:   writeBytes,
+			GrowsStorage: grows,
+		})
+		if err != nil {
+			trailer, err := exceededStatus(err)
+			if trailer != nil {
+				grpc.SetTrailer(ctx, trailer)
+			}
+			return nil, err
+		}
+		defer release()
+
+		resp, err := handler(ctx, req)
+		if msg, ok := resp.(proto.Message); ok && !write && err == nil {
+			m.ChargeRead(principal, database, int64(proto.Size(msg)))
+		}
+		return resp, err
+	}
+}
+
+// StreamInterceptor admits streaming RPCs on their first message and paces
+// the messages that follow to the byte rate limits. It must run after
+// authentication.
+func (m *Manager) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasPrefix(info.FullMethod, serviceMethodPrefix) {
+			return handler(srv, ss)
+		}
+		principal, _ := auth.PrincipalFromContext(ss.Context())
+		qs := &quotaStream{ServerStream: ss, manager: m, principal: principal}
+		defer qs.release()
+		return handler(srv, qs)
+	}
+}
+
+// quotaStream applies quotas to the messages of a stream
+type quotaStream struct {
+	grpc.ServerStream
+	manager   *Manager
+	principal string
+
+	admitted bool
+	database string
+	write    bool
+	done     func()
+}
+
+func (s *quotaStream) release() {
+	if s.done != nil {
+		s.done()
+	}
+}
+
+func (s *quotaStream) fail(err error) error {
+	trailer, err := exceededStatus(err)
+	if trailer != nil {
+		s.SetTrailer(trailer)
+	}
+	return err
+}
+
+func (s *quotaStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	database, write, grows := classify(m)
+	var n int64
+	if msg, ok := m.(proto.Message); ok && write {
+		n = int64(proto.Size(msg))
+	}
+
+	if !s.admitted {
+		done, err := s.manager.Admit(Request{
+			Principal:    s.principal,
+			Database:     database,
+			WriteBytes:   n,
+			GrowsStorage: grows,
+			Stream:       true,
+		})
+		if err != nil {
+			return s.fail(err)
+		}
+		s.admitted, s.database, s.write, s.done = true, database, write, done
+		return nil
+	}
+
+	if grows {
+		if err := s.manager.checkStorage(s.database); err != nil {
+			return s.fail(err)
+		}
+	}
+	if n > 0 {
+		return s.manager.WaitWrite(s.Context(), s.principal, s.database, n)
+	}
+	return nil
+}
+
+func (s *quotaStream) SendMsg(m any) error {
+	if msg, ok := m.(proto.Message); ok && s.admitted && !s.write {
+		if err := s.manager.WaitRead(s.Context(), s.principal, s.database, int64(proto.Size(msg))); err != nil {
+			return err
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
@@ -0,0 +1,417 @@
+// Package quota enforces per-principal and per-database rate limits,
+// stream concurrency limits and storage limits.
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"rocksdb-service/internal/filewatch"
+)
+
+const (
+	// reloadCheckInterval bounds how often the quota file is checked for
+	// changes
+	reloadCheckInterval = time.Second
+	// storageCacheTTL is how long a database's storage size is cached
+	storageCacheTTL = 10 * time.Second
+)
+
+// Limits are the limits of one principal or database. Zero values are
+// unlimited.
+type Limits struct {
+	RequestsPerSecond   float64 `json:"requests_per_second,omitempty"`
+	ReadBytesPerSecond  int64   `json:"read_bytes_per_second,omitempty"`
+	WriteBytesPerSecond int64   `json:"write_bytes_per_second,omitempty"`
+	MaxStreams          int     `json:"max_streams,omitempty"`
+	MaxStorageBytes     int64   `json:"max_storage_bytes,omitempty"`
+}
+
+// Config holds limits keyed by principal and by database name. The "*"
+// entry applies to names without their own entry.
+type Config struct {
+	Principals map[string]Limits `json:"principals"`
+	Databases  map[string]Limits `json:"databases"`
+}
+
+func (c *Config) limits(m map[string]Limits, name string) Limits {
+	if l, ok := m[name]; ok {
+		return l
+	}
+	return m["*"]
+}
+
+// ExceededError is returned when a request exceeds a limit
+type ExceededError struct {
+	Reason string
+	// RetryAfter is when the request may succeed, zero if unknown
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return "quota exceeded: " + e.Reason
+}
+
+// StorageFunc returns the on-disk size of a database
+type StorageFunc func(database string) (uint64, error)
+
+// Usage is the usage of one principal or database since startup
+type Usage struct {
+	Name          string
+	Requests      uint64
+	BytesRead     uint64
+	BytesWritten  uint64
+	ActiveStreams int
+	StorageBytes  uint64
+	Limits        Limits
+}
+
+// subject tracks the usage of one principal or database
+type subject struct {
+	requests, read, write *bucket
+	streams               int
+	usage                 Usage
+}
+
+func newSubject(name string, l Limits) *subject {
+	return &subject{
+		requests: newBucket(l.RequestsPerSecond),
+		read:     newBucket(float64(l.ReadBytesPerSecond)),
+		write:    newBucket(float64(l.WriteBytesPerSecond)),
+		usage:    Usage{Name: name},
+	}
+}
+
+type storageSize struct {
+	bytes   uint64
+	checked time.Time
+}
+
+// Manager enforces limits, usually loaded from a JSON file that is reloaded
+// when it changes:
+//
+//	{
+//	  "principals": {"*": {"requests_per_second": 100}, "etl": {"max_streams": 8}},
+//	  "databases": {"catalog": {"read_bytes_per_second": 10485760, "max_storage_bytes": 10737418240}}
+//	}
+type Manager struct {
+	path    string
+	watcher *filewatch.Watcher
+	storage StorageFunc
+
+	mu         sync.Mutex
+	cfg        Config
+	principals map[string]*subject
+	databases  map[string]*subject
+	sizes      map[string]storageSize
+}
+
+// Load reads the quota file at path. storage is used to check storage
+// limits.
+func Load(path string, storage StorageFunc) (*Manager, error) {
+	m := newManager(storage)
+	m.path = path
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	watcher, err := filewatch.New(reloadCheckInterval, path)
+	if err != nil {
+		return nil, err
+	}
+	m.watcher = watcher
+	return m, nil
+}
+
+// New returns a manager enforcing cfg. storage is used to check storage
+// limits.
+func New(cfg Config, storage StorageFunc) *Manager {
+	m := newManager(storage)
+	m.cfg = cfg
+	return m
+}
+
+func newManager(storage StorageFunc) *Manager {
+	return &Manager{
+		storage:    storage,
+		principals: make(map[string]*subject),
+		databases:  make(map[string]*subject),
+		sizes:      make(map[string]storageSize),
+	}
+}
+
+// SetConfig replaces the limits. Usage is kept.
+func (m *Manager) SetConfig(cfg Config) {
+	m.mu.Lock()
+	m.cfg = cfg
+	m.mu.Unlock()
+}
+
+// Reload re-reads the quota file, if the limits were loaded from one
+func (m *Manager) Reload() error {
+	if m.path == "" {
+		return nil
+	}
+	return m.load()
+}
+
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return fmt.Errorf("failed to read quota file: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse quota file: %w", err)
+	}
+
+	m.SetConfig(cfg)
+	return nil
+}
+
+func (m *Manager) reload() {
+	if m.watcher != nil && m.watcher.Changed() {
+		if err := m.load(); err != nil {
+			log.Printf("Failed to reload quota file: %v", err)
+		}
+	}
+}
+
+// subjects returns the principal and database subjects of a request, with
+// their current limits. A request without a principal, as when
+// authentication is disabled, is only subject to database limits. Must be
+// called with m.mu held.
+func (m *Manager) subjects(principal, database string) ([]*subject, []Limits) {
+	var subjects []*subject
+	var limits []Limits
+	add := func(tracked map[string]*subject, configured map[string]Limits, name string) {
+		l := m.cfg.limits(configured, name)
+		s, ok := tracked[name]
+		if !ok {
+			s = newSubject(name, l)
+			tracked[name] = s
+		}
+		s.usage.Limits = l
+		subjects = append(subjects, s)
+		limits = append(limits, l)
+	}
+	if principal != "" {
+		add(m.principals, m.cfg.Principals, principal)
+	}
+	if database != "" {
+		add(m.databases, m.cfg.Databases, database)
+	}
+	return subjects, limits
+}
+
+// Request describes a request being admitted
+type Request struct {
+	Principal string
+	Database  string
+	// WriteBytes is the size of data written by the request
+	WriteBytes int64
+	// GrowsStorage is set for writes subject to storage limits. Deletes are
+	// exempt so tenants over their limit can free space.
+	GrowsStorage bool
+	// Stream is set for streaming RPCs, which count against stream limits
+	// until released
+	Stream bool
+}
+
+// Admit checks a request against all applicable limits and records it. The
+// returned function must be called when the request completes.
+func (m *Manager) Admit(req Request) (func(), error) {
+	m.reload()
+
+	if req.GrowsStorage {
+		if err := m.checkStorage(req.Database); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subjects, limits := m.subjects(req.Principal, req.Database)
+	now := time.Now()
+
+	// Check every limit before taking any tokens, so a rejected request
+	// does not consume quota
+	for i, s := range subjects {
+		l := limits[i]
+		if req.Stream && l.MaxStreams > 0 && s.streams >= l.MaxStreams {
+			return nil, &ExceededError{Reason: fmt.Sprintf("%s has %d concurrent streams", s.usage.Name, s.streams)}
+		}
+		checks := []struct {
+			b    *bucket
+			rate float64
+			n    float64
+			what string
+		}{
+			{s.requests, l.RequestsPerSecond, 1, "requests"},
+			{s.read, float64(l.ReadBytesPerSecond), 0, "read bytes"},
+			{s.write, float64(l.WriteBytesPerSecond), float64(req.WriteBytes), "write bytes"},
+		}
+		for _, c := range checks {
+			probe := *c.b
+			if ok, retry := probe.take(c.rate, c.n, now); !ok {
+				return nil, &ExceededError{
+					Reason:     fmt.Sprintf("%s rate limit of %s exceeded", c.what, s.usage.Name),
+					RetryAfter: retry,
+				}
+			}
+		}
+	}
+
+	for i, s := range subjects {
+		l := limits[i]
+		s.requests.take(l.RequestsPerSecond, 1, now)
+		s.write.take(float64(l.WriteBytesPerSecond), float64(req.WriteBytes), now)
+		s.usage.Requests++
+		s.usage.BytesWritten += uint64(req.WriteBytes)
+		if req.Stream {
+			s.streams++
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if !req.Stream {
+				return
+			}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			for _, s := range subjects {
+				s.streams--
+			}
+		})
+	}, nil
+}
+
+// checkStorage rejects writes to a database at or over its storage limit
+func (m *Manager) checkStorage(database string) error {
+	m.mu.Lock()
+	limit := m.cfg.limits(m.cfg.Databases, database).MaxStorageBytes
+	cached, ok := m.sizes[database]
+	m.mu.Unlock()
+	if limit <= 0 {
+		return nil
+	}
+
+	size := cached.bytes
+	if !ok || time.Since(cached.checked) > storageCacheTTL {
+		var err error
+		if size, err = m.storage(database); err != nil {
+			// Let the request fail on its own if the database is unusable.
+			// Databases are only measured once open, so the write opening
+			// one is not checked.
+			return nil
+		}
+		m.mu.Lock()
+		m.sizes[database] = storageSize{bytes: size, checked: time.Now()}
+		m.mu.Unlock()
+	}
+
+	if size >= uint64(limit) {
+		return &ExceededError{Reason: fmt.Sprintf("database %s is at its storage limit of %d bytes", database, limit)}
+	}
+	return nil
+}
+
+// ChargeRead records n bytes read by a completed request. Later requests
+// are rejected until the read budget recovers.
+func (m *Manager) ChargeRead(principal, database string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subjects, limits := m.subjects(principal, database)
+	now := time.Now()
+	for i, s := range subjects {
+		s.read.charge(float64(limits[i].ReadBytesPerSecond), float64(n), now)
+		s.usage.BytesRead += uint64(n)
+	}
+}
+
+// WaitRead paces a stream sending n bytes, blocking until the read budget
+// of every subject allows it
+func (m *Manager) WaitRead(ctx context.Context, principal, database string, n int64) error {
+	return m.wait(ctx, principal, database, n, false)
+}
+
+// WaitWrite paces a stream receiving n bytes, blocking until the write
+// budget of every subject allows it
+func (m *Manager) WaitWrite(ctx context.Context, principal, database string, n int64) error {
+	return m.wait(ctx, principal, database, n, true)
+}
+
+func (m *Manager) wait(ctx context.Context, principal, database string, n int64, write bool) error {
+	for {
+		m.mu.Lock()
+		subjects, limits := m.subjects(principal, database)
+		now := time.Now()
+		var retry time.Duration
+		for i, s := range subjects {
+			b, rate := s.read, float64(limits[i].ReadBytesPerSecond)
+			if write {
+				b, rate = s.write, float64(limits[i].WriteBytesPerSecond)
+			}
+			probe := *b
+			if ok, d := probe.take(rate, float64(n), now); !ok {
+				retry = max(retry, d)
+			}
+		}
+		if retry == 0 {
+			for i, s := range subjects {
+				if write {
+					s.write.take(float64(limits[i].WriteBytesPerSecond), float64(n), now)
+					s.usage.BytesWritten += uint64(n)
+				} else {
+					s.read.take(float64(limits[i].ReadBytesPerSecond), float64(n), now)
+					s.usage.BytesRead += uint64(n)
+				}
+			}
+			m.mu.Unlock()
+			return nil
+		}
+		m.mu.Unlock()
+
+		if err := sleep(ctx, retry); err != nil {
+			return err
+		}
+	}
+}
+
+// Usage returns the usage of principals and databases seen since startup,
+// optionally filtered by name
+func (m *Manager) Usage(principal, database string) ([]Usage, []Usage) {
+	m.mu.Lock()
+	collect := func(tracked map[string]*subject, filter string) []Usage {
+		var usage []Usage
+		for name, s := range tracked {
+			if filter != "" && name != filter {
+				continue
+			}
+			u := s.usage
+			u.ActiveStreams = s.streams
+			usage = append(usage, u)
+		}
+		sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+		return usage
+	}
+	principals := collect(m.principals, principal)
+	databases := collect(m.databases, database)
+	m.mu.Unlock()
+
+	for i := range databases {
+		if size, err := m.storage(databases[i].Name); err == nil {
+			databases[i].StorageBytes = size
+		}
+	}
+	return principals, databases
+}
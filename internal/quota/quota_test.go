@@ -0,0 +1,114 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+)
+
+func noStorage(string) (uint64, error) {
+	return 0, errors.New("not open")
+}
+
+func TestAdmitRequestRate(t *testing.T) {
+	m := New(Config{Principals: map[string]Limits{"etl": {RequestsPerSecond: 2}}}, noStorage)
+
+	for i := range 2 {
+		release, err := m.Admit(Request{Principal: "etl", Database: "db"})
+		if err != nil {
+			t.Fatalf("request %d within the burst: %v", i, err)
+		}
+		release()
+	}
+	_, err := m.Admit(Request{Principal: "etl", Database: "db"})
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.RetryAfter <= 0 {
+		t.Fatalf("request over the rate: got %v, want an ExceededError with a retry delay", err)
+	}
+
+	// Other principals are unlimited
+	if _, err := m.Admit(Request{Principal: "web", Database: "db"}); err != nil {
+		t.Errorf("unlimited principal: %v", err)
+	}
+}
+
+func TestAdmitStreams(t *testing.T) {
+	m := New(Config{Databases: map[string]Limits{"*": {MaxStreams: 1}}}, noStorage)
+
+	release, err := m.Admit(Request{Database: "db", Stream: true})
+	if err != nil {
+		t.Fatalf("first stream: %v", err)
+	}
+	if _, err := m.Admit(Request{Database: "db", Stream: true}); err == nil {
+		t.Fatal("second concurrent stream was admitted")
+	}
+	if _, err := m.Admit(Request{Database: "db"}); err != nil {
+		t.Errorf("unary request while the stream limit is reached: %v", err)
+	}
+
+	release()
+	release()
+	if _, err := m.Admit(Request{Database: "db", Stream: true}); err != nil {
+		t.Errorf("stream after the first was released: %v", err)
+	}
+}
+
+func TestAdmitStorage(t *testing.T) {
+	sizes := map[string]uint64{"full": 100, "roomy": 10}
+	storage := func(database string) (uint64, error) {
+		size, ok := sizes[database]
+		if !ok {
+			return 0, errors.New("not open")
+		}
+		return size, nil
+	}
+	m := New(Config{Databases: map[string]Limits{"*": {MaxStorageBytes: 100}}}, storage)
+
+	if _, err := m.Admit(Request{Database: "full", GrowsStorage: true}); err == nil {
+		t.Error("write to a database at its storage limit was admitted")
+	}
+	if _, err := m.Admit(Request{Database: "full"}); err != nil {
+		t.Errorf("delete from a database at its storage limit: %v", err)
+	}
+	if _, err := m.Admit(Request{Database: "roomy", GrowsStorage: true}); err != nil {
+		t.Errorf("write to a database under its storage limit: %v", err)
+	}
+	if _, err := m.Admit(Request{Database: "closed", GrowsStorage: true}); err != nil {
+		t.Errorf("write to a database that is not open: %v", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	m := New(Config{}, func(database string) (uint64, error) {
+		if database != "open" {
+			return 0, errors.New("not open")
+		}
+		return 42, nil
+	})
+	for _, req := range []Request{
+		{Principal: "etl", Database: "open", WriteBytes: 10},
+		{Principal: "etl", Database: "closed", WriteBytes: 5},
+	} {
+		release, err := m.Admit(req)
+		if err != nil {
+			t.Fatalf("Admit(%+v): %v", req, err)
+		}
+		release()
+	}
+	m.ChargeRead("etl", "open", 7)
+
+	principals, databases := m.Usage("", "")
+	if len(principals) != 1 || principals[0].Requests != 2 || principals[0].BytesWritten != 15 || principals[0].BytesRead != 7 {
+		t.Errorf("principal usage = %+v", principals)
+	}
+	if len(databases) != 2 || databases[0].Name != "closed" || databases[1].Name != "open" {
+		t.Fatalf("database usage = %+v", databases)
+	}
+	if databases[0].StorageBytes != 0 || databases[1].StorageBytes != 42 {
+		t.Errorf("storage of closed and open databases = %d, %d; want 0, 42", databases[0].StorageBytes, databases[1].StorageBytes)
+	}
+
+	_, databases = m.Usage("", "open")
+	if len(databases) != 1 || databases[0].Name != "open" {
+		t.Errorf("usage filtered by database = %+v", databases)
+	}
+}
@@ -0,0 +1,771 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+)
+
+const (
+	// defaultScanCount is the number of keys SCAN examines without COUNT
+	defaultScanCount = 10
+	// maxCursors is the number of unfinished SCAN cursors kept per
+	// connection
+	maxCursors = 1024
+	// maxIncrRetries bounds the retries of INCRBY racing with other writes
+	maxIncrRetries = 100
+)
+
+// command is a supported command. arity counts the command name; a
+// negative arity is a minimum, as in Redis.
+type command struct {
+	arity int
+	run   func(c *conn, args [][]byte) error
+}
+
+var commands = map[string]command{
+	"ping":    {-1, (*conn).ping},
+	"echo":    {2, (*conn).echo},
+	"quit":    {1, (*conn).quitCmd},
+	"auth":    {-2, (*conn).auth},
+	"hello":   {-1, (*conn).hello},
+	"select":  {2, (*conn).selectCmd},
+	"command": {-1, (*conn).commandCmd},
+	"client":  {-2, (*conn).client},
+	"get":     {2, (*conn).getCmd},
+	"set":     {-3, (*conn).set},
+	"del":     {-2, (*conn).del},
+	"exists":  {-2, (*conn).exists},
+	"mget":    {-2, (*conn).mget},
+	"mset":    {-3, (*conn).mset},
+	"scan":    {-2, (*conn).scan},
+	"incr":    {2, (*conn).incr},
+	"decr":    {2, (*conn).decr},
+	"incrby":  {3, (*conn).incrby},
+	"decrby":  {3, (*conn).decrby},
+	"expire":  {3, (*conn).expireCmd},
+	"ttl":     {2, (*conn).ttl},
+}
+
+func (c *conn) ping(args [][]byte) error {
+	switch len(args) {
+	case 0:
+		c.w.simple("PONG")
+	case 1:
+		c.w.bulk(args[0])
+	default:
+		return replyError("ERR wrong number of arguments for 'ping' command")
+	}
+	return nil
+}
+
+func (c *conn) echo(args [][]byte) error {
+	c.w.bulk(args[0])
+	return nil
+}
+
+func (c *conn) quitCmd(args [][]byte) error {
+	c.w.simple("OK")
+	c.quit = true
+	return nil
+}
+
+// auth sets the bearer token sent with the connection's calls, given as
+// "AUTH <token>" or "AUTH <username> <token>". Tokens are checked by the
+// calls, and by Options.Authenticate if set, which also lifts the limits
+// on the size of commands.
+func (c *conn) auth(args [][]byte) error {
+	if len(args) > 2 {
+		return errSyntax
+	}
+	token := string(args[len(args)-1])
+	if c.s.auth != nil {
+		if err := c.s.auth(token); err != nil {
+			return replyError("WRONGPASS invalid username-password pair or user is disabled")
+		}
+		c.r.maxBulkLen = maxBulkLen
+		c.r.maxArgs = maxArgs
+	}
+	c.token = token
+	c.w.simple("OK")
+	return nil
+}
+
+// hello rejects RESP3, so clients fall back to RESP2
+func (c *conn) hello(args [][]byte) error {
+	return replyError("NOPROTO unsupported protocol version")
+}
+
+// selectCmd selects a database by index in the configured mapping, or by
+// name
+func (c *conn) selectCmd(args [][]byte) error {
+	name := string(args[0])
+	if i, err := strconv.Atoi(name); err == nil {
+		if i < 0 || i >= len(c.s.databases) {
+			return replyError("ERR DB index is out of range")
+		}
+		name = c.s.databases[i]
+	}
+	c.database = name
+	c.cursors = make(map[uint64]string)
+	c.w.simple("OK")
+	return nil
+}
+
+// commandCmd replies with an empty command table, which redis-cli accepts
+func (c *conn) commandCmd(args [][]byte) error {
+	c.w.array(0)
+	return nil
+}
+
+// client accepts the CLIENT SETNAME and SETINFO calls client libraries
+// make on connecting
+func (c *conn) client(args [][]byte) error {
+	switch strings.ToLower(string(args[0])) {
+	case "setname", "setinfo":
+		c.w.simple("OK")
+		return nil
+	}
+	return replyError(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+}
+
+// key returns the expiry key of key in the selected database
+func (c *conn) key(key []byte) expiryKey {
+	return expiryKey{database: c.database, key: string(key)}
+}
+
+// get returns the value and version of key, deleting it first if it has
+// expired
+func (c *conn) get(key []byte) ([]byte, uint64, bool, error) {
+	items, err := c.getMany([]string{string(key)})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return items[0].value, items[0].version, items[0].found, nil
+}
+
+// item is a value read by getMany
+type item struct {
+	value   []byte
+	version uint64
+	found   bool
+}
+
+// getMany returns the values of keys, deleting first the keys that have
+// expired
+func (c *conn) getMany(keys []string) ([]item, error) {
+	for _, key := range keys {
+		if err := c.s.expire(c.ctx, c.key([]byte(key))); err != nil {
+			return nil, err
+		}
+	}
+	values, err := c.streamGet(keys)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]item, len(keys))
+	for i, key := range keys {
+		if resp, ok := values[key]; ok {
+			items[i] = item{value: resp.Value, version: resp.Version, found: true}
+		}
+	}
+	return items, nil
+}
+
+// streamGet returns the values of the keys that exist, by key
+func (c *conn) streamGet(keys []string) (map[string]*pb.StreamGetResponse, error) {
+	stream, err := c.s.client.StreamGet(c.outgoing(), &pb.StreamGetRequest{
+		DatabaseName: c.database,
+		Query:        &pb.StreamGetRequest_Keys{Keys: &pb.KeySet{Keys: keys}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]*pb.StreamGetResponse, len(keys))
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error != "" {
+			return nil, errors.New(resp.Error)
+		}
+		values[resp.Key] = resp
+	}
+}
+
+func (c *conn) getCmd(args [][]byte) error {
+	value, _, found, err := c.get(args[0])
+	if err != nil {
+		return err
+	}
+	if !found {
+		c.w.null()
+		return nil
+	}
+	c.w.bulk(value)
+	return nil
+}
+
+// set supports the EX, PX, NX, XX and KEEPTTL options
+func (c *conn) set(args [][]byte) error {
+	key, value := args[0], args[1]
+	var (
+		ttl          time.Duration
+		nx, xx, keep bool
+	)
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "KEEPTTL":
+			keep = true
+		case "EX", "PX":
+			if i+1 == len(args) || ttl != 0 {
+				return errSyntax
+			}
+			i++
+			n, err := parseInt(args[i])
+			if err != nil {
+				return err
+			}
+			if n <= 0 {
+				return replyError("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			return errSyntax
+		}
+	}
+	if (nx && xx) || (keep && ttl != 0) {
+		return errSyntax
+	}
+
+	// Expired keys are absent for NX and XX
+	if err := c.s.expire(c.ctx, c.key(key)); err != nil {
+		return err
+	}
+
+	var ifVersion *uint64
+	if xx {
+		_, version, found, err := c.get(key)
+		if err != nil {
+			return err
+		}
+		if !found {
+			c.w.null()
+			return nil
+		}
+		ifVersion = &version
+	}
+	var (
+		version uint64
+		err     error
+	)
+	if nx {
+		version, err = c.putIfAbsent(key, value)
+	} else {
+		version, err = c.put(key, value, ifVersion)
+	}
+	if status.Code(err) == codes.FailedPrecondition {
+		c.w.null()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.setExpiry(key, version, ttl, keep); err != nil {
+		return err
+	}
+	c.w.simple("OK")
+	return nil
+}
+
+// put writes value under key and returns its version. A non-nil ifVersion
+// is the version the key must have, failing with FailedPrecondition
+// otherwise.
+func (c *conn) put(key, value []byte, ifVersion *uint64) (uint64, error) {
+	resp, err := c.s.client.Put(c.outgoing(), &pb.PutRequest{
+		DatabaseName: c.database,
+		Key:          string(key),
+		Value:        value,
+		IfVersion:    ifVersion,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Success {
+		return 0, errors.New(resp.Error)
+	}
+	return resp.Version, nil
+}
+
+// putIfAbsent writes value under key if the key does not exist, and
+// returns its version. It fails with FailedPrecondition if the key exists.
+func (c *conn) putIfAbsent(key, value []byte) (uint64, error) {
+	resp, err := c.s.client.PutIfAbsent(c.outgoing(), &pb.PutIfAbsentRequest{DatabaseName: c.database, Key: string(key), Value: value})
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Success {
+		return 0, errors.New(resp.Error)
+	}
+	return resp.Version, nil
+}
+
+// setExpiry updates the expiry of key after a write. A positive ttl sets
+// it, keep moves the current expiry to the new version, and otherwise the
+// expiry is cleared.
+func (c *conn) setExpiry(key []byte, version uint64, ttl time.Duration, keep bool) error {
+	k := c.key(key)
+	switch {
+	case ttl > 0:
+		return c.s.setExpiry(c.ctx, k, expiry{at: time.Now().Add(ttl), version: version})
+	case keep:
+		if x, ok := c.s.expiries.get(k); ok {
+			x.version = version
+			return c.s.setExpiry(c.ctx, k, x)
+		}
+		return nil
+	default:
+		return c.s.clearExpiry(c.ctx, k)
+	}
+}
+
+func (c *conn) del(args [][]byte) error {
+	var deleted int64
+	for _, key := range args {
+		_, _, found, err := c.get(key)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		resp, err := c.s.client.Delete(c.outgoing(), &pb.DeleteRequest{DatabaseName: c.database, Key: string(key)})
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return errors.New(resp.Error)
+		}
+		if err := c.s.clearExpiry(c.ctx, c.key(key)); err != nil {
+			return err
+		}
+		deleted++
+	}
+	c.w.integer(deleted)
+	return nil
+}
+
+// exists counts the keys that exist, counting repeated keys repeatedly
+func (c *conn) exists(args [][]byte) error {
+	var n int64
+	for _, key := range args {
+		_, _, found, err := c.get(key)
+		if err != nil {
+			return err
+		}
+		if found {
+			n++
+		}
+	}
+	c.w.integer(n)
+	return nil
+}
+
+func (c *conn) mget(args [][]byte) error {
+	keys := make([]string, len(args))
+	for i, key := range args {
+		keys[i] = string(key)
+	}
+	items, err := c.getMany(keys)
+	if err != nil {
+		return err
+	}
+
+	c.w.array(len(items))
+	for _, item := range items {
+		if item.found {
+			c.w.bulk(item.value)
+		} else {
+			c.w.null()
+		}
+	}
+	return nil
+}
+
+// mset writes the pairs over StreamWrite. They are committed in one batch
+// unless there are more than the server's StreamWrite batch size.
+func (c *conn) mset(args [][]byte) error {
+	if len(args)%2 != 0 {
+		return replyError("ERR wrong number of arguments for 'mset' command")
+	}
+	stream, err := c.s.client.StreamWrite(c.outgoing())
+	if err != nil {
+		return err
+	}
+	n := len(args) / 2
+	for i := range n {
+		err := stream.Send(&pb.StreamWriteRequest{
+			DatabaseName: c.database,
+			Sequence:     uint64(i + 1),
+			Key:          string(args[2*i]),
+			Value:        args[2*i+1],
+			BatchSize:    uint32(min(n, math.MaxUint32)),
+		})
+		if err != nil {
+			// The reason is returned by Recv
+			break
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	var committed uint64
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		committed = resp.CommittedSequence
+	}
+	if committed != uint64(n) {
+		return fmt.Errorf("only %d of %d keys were written", committed, n)
+	}
+
+	for i := range n {
+		if err := c.s.clearExpiry(c.ctx, c.key(args[2*i])); err != nil {
+			return err
+		}
+	}
+	c.w.simple("OK")
+	return nil
+}
+
+// scan supports the MATCH and COUNT options. Cursors are numbers standing
+// for the key the scan continues at, valid on the connection that
+// returned them.
+func (c *conn) scan(args [][]byte) error {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return replyError("ERR invalid cursor")
+	}
+	start := ""
+	if cursor != 0 {
+		var ok bool
+		if start, ok = c.cursors[cursor]; !ok {
+			return replyError("ERR invalid cursor")
+		}
+		delete(c.cursors, cursor)
+	}
+
+	var (
+		match  *regexp.Regexp
+		prefix string
+		count  int64 = defaultScanCount
+		// none is set by a TYPE other than string, matching no keys
+		none bool
+	)
+	for i := 1; i < len(args); i++ {
+		if i+1 == len(args) {
+			return errSyntax
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			i++
+			match, prefix = glob(string(args[i]))
+		case "COUNT":
+			i++
+			if count, err = parseInt(args[i]); err != nil {
+				return err
+			}
+			if count < 1 {
+				return errSyntax
+			}
+		case "TYPE":
+			// Every key is a string
+			i++
+			none = !strings.EqualFold(string(args[i]), "string")
+		default:
+			return errSyntax
+		}
+	}
+
+	// One key more than requested tells where the next call continues
+	stream, err := c.s.client.Scan(c.outgoing(), &pb.ScanRequest{
+		DatabaseName: c.database,
+		Prefix:       prefix,
+		StartKey:     start,
+		Limit:        uint64(count) + 1,
+		KeysOnly:     true,
+	})
+	if err != nil {
+		return err
+	}
+	var (
+		keys []string
+		next string
+		// examined counts the keys streamed, whether or not they match
+		examined int64
+	)
+	now := time.Now()
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if examined == count {
+			next = resp.Key
+			break
+		}
+		examined++
+		if x, ok := c.s.expiries.get(c.key([]byte(resp.Key))); ok && !now.Before(x.at) && x.version == resp.Version {
+			continue
+		}
+		if !none && (match == nil || match.MatchString(resp.Key)) {
+			keys = append(keys, resp.Key)
+		}
+	}
+
+	cursor = 0
+	if next != "" {
+		if len(c.cursors) >= maxCursors {
+			c.cursors = make(map[uint64]string)
+		}
+		c.nextCursor++
+		cursor = c.nextCursor
+		c.cursors[cursor] = next
+	}
+	c.w.array(2)
+	c.w.bulk([]byte(strconv.FormatUint(cursor, 10)))
+	c.w.array(len(keys))
+	for _, key := range keys {
+		c.w.bulk([]byte(key))
+	}
+	return nil
+}
+
+// glob returns a regexp matching the Redis glob pattern, and the literal
+// prefix of the pattern
+func glob(pattern string) (*regexp.Regexp, string) {
+	var (
+		re      strings.Builder
+		prefix  strings.Builder
+		literal = true
+	)
+	re.WriteString(`(?s)^`)
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch ch {
+		case '*':
+			re.WriteString(`.*`)
+			literal = false
+		case '?':
+			re.WriteString(`.`)
+			literal = false
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				if literal {
+					prefix.WriteByte(ch)
+				}
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			re.WriteByte('[')
+			if strings.HasPrefix(class, "^") {
+				re.WriteByte('^')
+				class = class[1:]
+			}
+			re.WriteString(strings.NewReplacer(`\`, `\\`, `[`, `\[`).Replace(class))
+			re.WriteByte(']')
+			i += end + 1
+			literal = false
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				ch = pattern[i]
+			}
+			fallthrough
+		default:
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+			if literal {
+				prefix.WriteByte(ch)
+			}
+		}
+	}
+	re.WriteString(`$`)
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		// A malformed class matches itself literally
+		compiled = regexp.MustCompile(`^` + regexp.QuoteMeta(pattern) + `$`)
+	}
+	return compiled, prefix.String()
+}
+
+func (c *conn) incr(args [][]byte) error {
+	return c.incrementBy(args[0], 1)
+}
+
+func (c *conn) decr(args [][]byte) error {
+	return c.incrementBy(args[0], -1)
+}
+
+func (c *conn) incrby(args [][]byte) error {
+	delta, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	return c.incrementBy(args[0], delta)
+}
+
+func (c *conn) decrby(args [][]byte) error {
+	delta, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	if delta == math.MinInt64 {
+		return replyError("ERR decrement would overflow")
+	}
+	return c.incrementBy(args[0], -delta)
+}
+
+// incrementBy adds delta to the decimal integer stored under key, as Redis
+// stores counters, keeping its expiry. It writes with a version
+// precondition and retries if the key changed in between. Counters of the
+// Increment RPC are stored in binary and are rejected rather than read as
+// text.
+func (c *conn) incrementBy(key []byte, delta int64) error {
+	for range maxIncrRetries {
+		value, version, found, err := c.get(key)
+		if err != nil {
+			return err
+		}
+		var n int64
+		if found {
+			if n, err = parseInt(value); err != nil {
+				if len(value) == 8 {
+					return errCounter
+				}
+				return err
+			}
+		}
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+			return replyError("ERR increment or decrement would overflow")
+		}
+		n += delta
+
+		resp, err := c.s.client.Put(c.outgoing(), &pb.PutRequest{
+			DatabaseName: c.database,
+			Key:          string(key),
+			Value:        []byte(strconv.FormatInt(n, 10)),
+			IfVersion:    &version,
+		})
+		if status.Code(err) == codes.FailedPrecondition {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return errors.New(resp.Error)
+		}
+		if err := c.setExpiry(key, resp.Version, 0, true); err != nil {
+			return err
+		}
+		c.w.integer(n)
+		return nil
+	}
+	return replyError("ERR too many concurrent writes to the key, try again")
+}
+
+// expireCmd sets the expiry of a key in seconds. A non-positive time
+// deletes the key.
+func (c *conn) expireCmd(args [][]byte) error {
+	seconds, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	if seconds > math.MaxInt64/int64(time.Second) {
+		return replyError("ERR invalid expire time in 'expire' command")
+	}
+	_, version, found, err := c.get(args[0])
+	if err != nil {
+		return err
+	}
+	if !found {
+		c.w.integer(0)
+		return nil
+	}
+
+	k := c.key(args[0])
+	x := expiry{at: time.Now().Add(time.Duration(seconds) * time.Second), version: version}
+	if seconds > 0 {
+		if err := c.s.setExpiry(c.ctx, k, x); err != nil {
+			return err
+		}
+	} else {
+		// Replaces and deletes the stored expiry along with the key
+		c.s.expiries.set(k, x)
+		if err := c.s.expire(c.ctx, k); err != nil {
+			return err
+		}
+	}
+	c.w.integer(1)
+	return nil
+}
+
+// ttl returns the seconds until a key expires, -1 for keys without an
+// expiry and -2 for missing keys
+func (c *conn) ttl(args [][]byte) error {
+	_, version, found, err := c.get(args[0])
+	if err != nil {
+		return err
+	}
+	if !found {
+		c.w.integer(-2)
+		return nil
+	}
+	x, ok := c.s.expiries.get(c.key(args[0]))
+	if !ok || x.version != version {
+		c.w.integer(-1)
+		return nil
+	}
+	c.w.integer(int64((time.Until(x.at) + time.Second - 1) / time.Second))
+	return nil
+}
@@ -0,0 +1,233 @@
+package resp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+)
+
+// expiryDatabase is the database holding the expiries, so that they
+// survive restarts. It is read and written with the trusted client only.
+const expiryDatabase = "resp-expiries"
+
+// expiryKey identifies a key across databases
+type expiryKey struct {
+	database string
+	key      string
+}
+
+// recordKey returns the key of the expiry of k in expiryDatabase. Database
+// names cannot contain NUL, as they name directories.
+func (k expiryKey) recordKey() string {
+	return k.database + "\x00" + k.key
+}
+
+// parseRecordKey returns the key an expiry record is stored under
+func parseRecordKey(record string) (expiryKey, bool) {
+	database, key, ok := strings.Cut(record, "\x00")
+	return expiryKey{database: database, key: key}, ok
+}
+
+// expiry is a pending deletion of a key. It applies only while the key has
+// the version it had when the expiry was set, so writes that do not keep
+// the expiry, including writes through gRPC, cancel it.
+type expiry struct {
+	at      time.Time
+	version uint64
+}
+
+// encode returns the stored record of x: the time in Unix milliseconds and
+// the version, big-endian
+func (x expiry) encode() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(x.at.UnixMilli()))
+	binary.BigEndian.PutUint64(b[8:], x.version)
+	return b
+}
+
+// decodeExpiry parses a stored record
+func decodeExpiry(b []byte) (expiry, bool) {
+	if len(b) != 16 {
+		return expiry{}, false
+	}
+	return expiry{
+		at:      time.UnixMilli(int64(binary.BigEndian.Uint64(b))),
+		version: binary.BigEndian.Uint64(b[8:]),
+	}, true
+}
+
+// expiries holds the expiries set by EXPIRE and SET. They are written to
+// expiryDatabase as well and loaded from it when the server starts.
+type expiries struct {
+	mu sync.Mutex
+	m  map[expiryKey]expiry
+}
+
+func newExpiries() *expiries {
+	return &expiries{m: make(map[expiryKey]expiry)}
+}
+
+func (e *expiries) get(k expiryKey) (expiry, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	x, ok := e.m[k]
+	return x, ok
+}
+
+func (e *expiries) set(k expiryKey, x expiry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.m[k] = x
+}
+
+// clearIf removes the expiry of k if it is still x
+func (e *expiries) clearIf(k expiryKey, x expiry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.m[k] == x {
+		delete(e.m, k)
+	}
+}
+
+// due returns the expiries whose time has come
+func (e *expiries) due(now time.Time) map[expiryKey]expiry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	due := make(map[expiryKey]expiry)
+	for k, x := range e.m {
+		if !now.Before(x.at) {
+			due[k] = x
+		}
+	}
+	return due
+}
+
+// load reads the stored expiries
+func (s *Server) load(ctx context.Context) error {
+	stream, err := s.trusted.Scan(ctx, &pb.ScanRequest{DatabaseName: expiryDatabase})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		k, ok := parseRecordKey(resp.Key)
+		x, valid := decodeExpiry(resp.Value)
+		if !ok || !valid {
+			log.Printf("Ignoring invalid expiry record %q", resp.Key)
+			continue
+		}
+		s.expiries.set(k, x)
+	}
+}
+
+// setExpiry sets the expiry of k and stores it
+func (s *Server) setExpiry(ctx context.Context, k expiryKey, x expiry) error {
+	s.expiries.set(k, x)
+	resp, err := s.trusted.Put(ctx, &pb.PutRequest{DatabaseName: expiryDatabase, Key: k.recordKey(), Value: x.encode()})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// clearExpiry removes the expiry of k, if it has one, and its record
+func (s *Server) clearExpiry(ctx context.Context, k expiryKey) error {
+	x, ok := s.expiries.get(k)
+	if !ok {
+		return nil
+	}
+	s.expiries.clearIf(k, x)
+	return s.deleteRecord(ctx, k, x)
+}
+
+// deleteRecord deletes the stored record of x, unless it has been replaced
+func (s *Server) deleteRecord(ctx context.Context, k expiryKey, x expiry) error {
+	resp, err := s.trusted.DeleteIfEquals(ctx, &pb.DeleteIfEqualsRequest{
+		DatabaseName:  expiryDatabase,
+		Key:           k.recordKey(),
+		ExpectedValue: x.encode(),
+	})
+	if status.Code(err) == codes.FailedPrecondition {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// expire deletes k if its expiry is due and the key still has the version
+// the expiry was set for. Keys are deleted with the trusted client, as the
+// client that set the expiry may be gone.
+func (s *Server) expire(ctx context.Context, k expiryKey) error {
+	x, ok := s.expiries.get(k)
+	if !ok || time.Now().Before(x.at) {
+		return nil
+	}
+
+	resp, err := s.trusted.Get(ctx, &pb.GetRequest{DatabaseName: k.database, Key: k.key})
+	if err != nil {
+		return err
+	}
+	if resp.Found && resp.Version == x.version {
+		_, err := s.trusted.DeleteIfEquals(ctx, &pb.DeleteIfEqualsRequest{
+			DatabaseName:  k.database,
+			Key:           k.key,
+			ExpectedValue: resp.Value,
+		})
+		// A concurrent write replaced the value, and with it the expiry
+		if err != nil && status.Code(err) != codes.FailedPrecondition {
+			return err
+		}
+	}
+	if err := s.deleteRecord(ctx, k, x); err != nil {
+		return err
+	}
+	s.expiries.clearIf(k, x)
+	return nil
+}
+
+// sweep deletes expired keys until ctx is done, so keys that are not read
+// again are deleted too
+func (s *Server) sweep(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for k := range s.expiries.due(now) {
+				err := s.expire(ctx, k)
+				if err != nil && ctx.Err() == nil {
+					// Retried on the next sweep
+					log.Printf("Failed to delete expired key %q of database %s: %v", k.key, k.database, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// maxBulkLen is the largest bulk string accepted, as in Redis
+	maxBulkLen = 512 << 20
+	// maxArgs is the largest number of arguments of a command
+	maxArgs = 1 << 20
+	// maxInlineLen is the longest inline command accepted
+	maxInlineLen = 64 << 10
+
+	// Connections that have to authenticate are held to lower limits
+	// until they do, so that they cannot make the server allocate much
+	maxUnauthenticatedBulkLen = 64 << 10
+	maxUnauthenticatedArgs    = 10
+)
+
+// errProtocol is returned for malformed requests. The connection is closed
+// after replying.
+var errProtocol = errors.New("Protocol error")
+
+// reader reads commands sent as RESP arrays of bulk strings, or as inline
+// commands of space-separated words as typed into telnet
+type reader struct {
+	r *bufio.Reader
+	// maxBulkLen and maxArgs limit the size of commands
+	maxBulkLen int
+	maxArgs    int
+}
+
+// command returns the arguments of the next command, nil for an empty
+// inline command
+func (r *reader) command() ([][]byte, error) {
+	b, err := r.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != '*' {
+		line, err := r.line(maxInlineLen)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Fields(line), nil
+	}
+
+	n, err := r.length('*', r.maxArgs)
+	if err != nil {
+		return nil, err
+	}
+	args := make([][]byte, 0, min(n, 1024))
+	for range n {
+		size, err := r.length('$', r.maxBulkLen)
+		if err != nil {
+			return nil, err
+		}
+		// The buffer grows as the string arrives rather than being
+		// allocated at the announced size
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, r.r, int64(size)+2); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		arg := buf.Bytes()
+		if !bytes.HasSuffix(arg, []byte("\r\n")) {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// length reads a "<prefix><n>\r\n" header
+func (r *reader) length(prefix byte, limit int) (int, error) {
+	line, err := r.line(32)
+	if err != nil {
+		return 0, err
+	}
+	if len(line) == 0 || line[0] != prefix {
+		return 0, fmt.Errorf("%w: expected '%c', got %q", errProtocol, prefix, line)
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 || n > limit {
+		return 0, fmt.Errorf("%w: invalid length %q", errProtocol, line[1:])
+	}
+	return n, nil
+}
+
+// line reads a line of at most limit bytes, without its line ending
+func (r *reader) line(limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > limit+2 {
+			return nil, fmt.Errorf("%w: line too long", errProtocol)
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+	}
+	line = bytes.TrimSuffix(line[:len(line)-1], []byte("\r"))
+	return line, nil
+}
+
+// writer buffers RESP replies
+type writer struct {
+	w *bufio.Writer
+}
+
+func (w *writer) simple(s string) {
+	w.w.WriteByte('+')
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// error writes an error reply. message starts with the error code, e.g.
+// "ERR" or "NOAUTH".
+func (w *writer) error(message string) {
+	w.w.WriteByte('-')
+	w.w.WriteString(message)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.w.WriteByte(':')
+	w.w.WriteString(strconv.FormatInt(n, 10))
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) bulk(b []byte) {
+	w.w.WriteByte('$')
+	w.w.WriteString(strconv.Itoa(len(b)))
+	w.w.WriteString("\r\n")
+	w.w.Write(b)
+	w.w.WriteString("\r\n")
+}
+
+// null writes a null bulk string, the reply for missing keys
+func (w *writer) null() {
+	w.w.WriteString("$-1\r\n")
+}
+
+// array writes the header of an array of n replies
+func (w *writer) array(n int) {
+	w.w.WriteByte('*')
+	w.w.WriteString(strconv.Itoa(n))
+	w.w.WriteString("\r\n")
+}
@@ -0,0 +1,268 @@
+// Package resp serves a subset of the Redis protocol (RESP) on top of the
+// gRPC service, so redis-cli and Redis client libraries can use the
+// databases. Commands are translated into gRPC calls, so authentication,
+// ACLs, quotas and request logging apply to them as to any other client.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/accesslog"
+)
+
+// sweepInterval is the interval at which expired keys are deleted
+const sweepInterval = time.Second
+
+// Options configures a Server
+type Options struct {
+	// Databases maps the database indexes of SELECT to database names.
+	// Index 0 is the database selected by new connections; if Databases
+	// is empty it is "default". SELECT also accepts a database name.
+	Databases []string
+	// Trusted is a client whose calls need no credentials. The server
+	// stores expiries with it and deletes expired keys with it, as the
+	// connections that set them may be gone. It defaults to the client
+	// passed to NewServer.
+	Trusted pb.RocksDBServiceClient
+	// Authenticate, if set, checks the tokens given to AUTH. Connections
+	// are then limited to small commands until AUTH succeeds.
+	Authenticate func(token string) error
+}
+
+// Server serves RESP connections
+type Server struct {
+	client    pb.RocksDBServiceClient
+	trusted   pb.RocksDBServiceClient
+	auth      func(token string) error
+	databases []string
+	expiries  *expiries
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	closed   bool
+	stop     context.CancelFunc
+	sweeping sync.WaitGroup
+}
+
+// NewServer returns a server translating commands into calls of client.
+// It loads the stored expiries and deletes expired keys in the background
+// until closed.
+func NewServer(client pb.RocksDBServiceClient, opts Options) *Server {
+	databases := opts.Databases
+	if len(databases) == 0 {
+		databases = []string{"default"}
+	}
+	trusted := opts.Trusted
+	if trusted == nil {
+		trusted = client
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	s := &Server{
+		client:    client,
+		trusted:   trusted,
+		auth:      opts.Authenticate,
+		databases: databases,
+		expiries:  newExpiries(),
+		conns:     make(map[net.Conn]struct{}),
+		stop:      stop,
+	}
+	if err := s.load(ctx); err != nil {
+		log.Printf("Failed to load expiries, keys set to expire before a restart are kept: %v", err)
+	}
+	s.sweeping.Add(1)
+	go func() {
+		defer s.sweeping.Done()
+		s.sweep(ctx)
+	}()
+	return s
+}
+
+// Serve accepts connections on lis until it fails or the server is closed
+func (s *Server) Serve(lis net.Listener) error {
+	defer lis.Close()
+	for {
+		nc, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return nil
+		}
+		s.conns[nc] = struct{}{}
+		s.mu.Unlock()
+
+		go func() {
+			s.serveConn(nc)
+			s.mu.Lock()
+			delete(s.conns, nc)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close closes all connections and stops deleting expired keys. Listeners
+// passed to Serve must be closed by the caller.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+	s.stop()
+	s.sweeping.Wait()
+}
+
+// conn is the state of one client connection
+type conn struct {
+	s        *Server
+	ctx      context.Context
+	r        reader
+	w        writer
+	database string
+	token    string
+	quit     bool
+
+	// cursors maps SCAN cursors to the key the scan continues at
+	cursors    map[uint64]string
+	nextCursor uint64
+}
+
+func (s *Server) serveConn(nc net.Conn) {
+	defer nc.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = accesslog.WithClientAddress(ctx, nc.RemoteAddr().String())
+
+	c := &conn{
+		s:        s,
+		ctx:      ctx,
+		r:        reader{r: bufio.NewReader(nc), maxBulkLen: maxBulkLen, maxArgs: maxArgs},
+		w:        writer{bufio.NewWriter(nc)},
+		database: s.databases[0],
+		cursors:  make(map[uint64]string),
+	}
+	if s.auth != nil {
+		c.r.maxBulkLen = maxUnauthenticatedBulkLen
+		c.r.maxArgs = maxUnauthenticatedArgs
+	}
+	for !c.quit {
+		args, err := c.r.command()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				c.w.error("ERR " + err.Error())
+				c.w.w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("RESP connection from %v failed: %v", nc.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) > 0 {
+			c.dispatch(args)
+		}
+		// Replies to pipelined commands are flushed together
+		if c.r.r.Buffered() == 0 || c.quit {
+			if err := c.w.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatch runs a command and writes its reply
+func (c *conn) dispatch(args [][]byte) {
+	name := strings.ToLower(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		c.w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity {
+		c.w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	if err := cmd.run(c, args[1:]); err != nil {
+		c.w.error(errorReply(err))
+	}
+}
+
+// outgoing returns the context of calls made for the connection
+func (c *conn) outgoing() context.Context {
+	return withToken(c.ctx, c.token)
+}
+
+// withToken sends token as the bearer token of calls made with ctx
+func withToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// replyError is an error reply with its own error code
+type replyError string
+
+func (e replyError) Error() string {
+	return string(e)
+}
+
+var (
+	errSyntax     = replyError("ERR syntax error")
+	errNotInteger = replyError("ERR value is not an integer or out of range")
+	// errCounter is returned for values that look like counters of the
+	// Increment RPC, which are 8-byte binary integers
+	errCounter = replyError("ERR value is not a decimal integer; use the Increment RPC for 8-byte counters")
+)
+
+// errorReply converts an error into the message of an error reply,
+// mapping gRPC statuses to the error codes of Redis
+func errorReply(err error) string {
+	var re replyError
+	if errors.As(err, &re) {
+		return string(re)
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return "ERR " + err.Error()
+	}
+	switch st.Code() {
+	case codes.Unauthenticated:
+		return "NOAUTH " + st.Message()
+	case codes.PermissionDenied:
+		return "NOPERM " + st.Message()
+	}
+	return "ERR " + st.Message()
+}
+
+// parseInt parses an integer argument
+func parseInt(arg []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
@@ -0,0 +1,384 @@
+package resp_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"rocksdb-service/api/client"
+	"rocksdb-service/api/embedded"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/resp"
+)
+
+// respError is an error reply
+type respError string
+
+// testConn is a RESP connection to a server backed by an in-memory
+// embedded server
+type testConn struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+func newTestServer(t *testing.T, srv *embedded.Server) *resp.Server {
+	t.Helper()
+	cc, err := srv.Dial()
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return resp.NewServer(pb.NewRocksDBServiceClient(cc), resp.Options{})
+}
+
+func dialTestServer(t *testing.T, s *resp.Server) *testConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(lis) }()
+	t.Cleanup(func() {
+		s.Close()
+		lis.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+
+	nc, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { nc.Close() })
+	return &testConn{t: t, nc: nc, r: bufio.NewReader(nc)}
+}
+
+func newEmbeddedServer(t *testing.T) *embedded.Server {
+	t.Helper()
+	srv, err := embedded.NewServer(embedded.Options{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestConn returns a connection to a new server with an empty database
+func newTestConn(t *testing.T) *testConn {
+	t.Helper()
+	return dialTestServer(t, newTestServer(t, newEmbeddedServer(t)))
+}
+
+// do sends a command and returns its reply: a string for simple and bulk
+// strings, int64, nil, []any or respError
+func (c *testConn) do(args ...string) any {
+	c.t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.nc, b.String()); err != nil {
+		c.t.Fatalf("sending %q: %v", args, err)
+	}
+	reply, err := c.reply()
+	if err != nil {
+		c.t.Fatalf("reading the reply to %q: %v", args, err)
+	}
+	return reply
+}
+
+func (c *testConn) reply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply")
+	}
+	switch rest := line[1:]; line[0] {
+	case '+':
+		return rest, nil
+	case '-':
+		return respError(rest), nil
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		elems := make([]any, n)
+		for i := range elems {
+			if elems[i], err = c.reply(); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
+
+// scanAll scans with args until the cursor is 0, returning the keys
+// returned by each call
+func (c *testConn) scanAll(args ...string) [][]any {
+	c.t.Helper()
+	var pages [][]any
+	cursor := "0"
+	for {
+		reply, ok := c.do(append([]string{"SCAN", cursor}, args...)...).([]any)
+		if !ok || len(reply) != 2 {
+			c.t.Fatalf("SCAN %s = %v", cursor, reply)
+		}
+		pages = append(pages, reply[1].([]any))
+		if cursor = reply[0].(string); cursor == "0" {
+			return pages
+		}
+		if len(pages) > 100 {
+			c.t.Fatal("SCAN does not terminate")
+		}
+	}
+}
+
+func TestGetSetDel(t *testing.T) {
+	c := newTestConn(t)
+
+	steps := []struct {
+		args []string
+		want any
+	}{
+		{[]string{"GET", "k"}, nil},
+		{[]string{"SET", "k", "v"}, "OK"},
+		{[]string{"GET", "k"}, "v"},
+		{[]string{"EXISTS", "k", "k", "missing"}, int64(2)},
+		{[]string{"MSET", "a", "1", "b", "2"}, "OK"},
+		{[]string{"MGET", "a", "missing", "b"}, []any{"1", nil, "2"}},
+		{[]string{"INCR", "a"}, int64(2)},
+		{[]string{"DECRBY", "b", "5"}, int64(-3)},
+		{[]string{"INCR", "k"}, respError("ERR value is not an integer or out of range")},
+		{[]string{"DEL", "k", "a", "missing"}, int64(2)},
+		{[]string{"GET", "k"}, nil},
+	}
+	for _, step := range steps {
+		if got := c.do(step.args...); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%q = %#v, want %#v", step.args, got, step.want)
+		}
+	}
+}
+
+func TestSetConditions(t *testing.T) {
+	c := newTestConn(t)
+
+	steps := []struct {
+		args []string
+		want any
+	}{
+		{[]string{"SET", "k", "v1", "XX"}, nil},
+		{[]string{"SET", "k", "v1", "NX"}, "OK"},
+		{[]string{"SET", "k", "v2", "NX"}, nil},
+		{[]string{"GET", "k"}, "v1"},
+		{[]string{"SET", "k", "v3", "XX"}, "OK"},
+		{[]string{"GET", "k"}, "v3"},
+		{[]string{"SET", "k", "v4", "NX", "XX"}, respError("ERR syntax error")},
+	}
+	for _, step := range steps {
+		if got := c.do(step.args...); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%q = %#v, want %#v", step.args, got, step.want)
+		}
+	}
+}
+
+func TestScanCursor(t *testing.T) {
+	c := newTestConn(t)
+	for i := range 25 {
+		c.do("SET", fmt.Sprintf("key%02d", i), "v")
+	}
+	c.do("SET", "other", "v")
+
+	pages := c.scanAll("COUNT", "10")
+	var n int
+	for _, page := range pages {
+		if len(page) > 10 {
+			t.Errorf("SCAN COUNT 10 returned %d keys", len(page))
+		}
+		n += len(page)
+	}
+	if n != 26 {
+		t.Errorf("SCAN returned %d keys in all, want 26", n)
+	}
+
+	// Calls returning few or no matches still advance the cursor
+	pages = c.scanAll("MATCH", "*5", "COUNT", "10")
+	var matched []any
+	for _, page := range pages {
+		matched = append(matched, page...)
+	}
+	if want := []any{"key05", "key15"}; !reflect.DeepEqual(matched, want) {
+		t.Errorf("SCAN MATCH *5 = %v, want %v", matched, want)
+	}
+	if len(pages) != 3 {
+		t.Errorf("SCAN MATCH *5 COUNT 10 took %d calls over 26 keys, want 3", len(pages))
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := newTestConn(t)
+
+	steps := []struct {
+		args []string
+		want any
+	}{
+		{[]string{"SET", "k", "v", "EX", "100"}, "OK"},
+		{[]string{"TTL", "k"}, int64(100)},
+		{[]string{"SET", "k", "v2", "KEEPTTL"}, "OK"},
+		{[]string{"TTL", "k"}, int64(100)},
+		{[]string{"SET", "k", "v3"}, "OK"},
+		{[]string{"TTL", "k"}, int64(-1)},
+		{[]string{"EXPIRE", "k", "50"}, int64(1)},
+		{[]string{"TTL", "k"}, int64(50)},
+		{[]string{"EXPIRE", "missing", "50"}, int64(0)},
+		{[]string{"TTL", "missing"}, int64(-2)},
+		{[]string{"EXPIRE", "k", "0"}, int64(1)},
+		{[]string{"GET", "k"}, nil},
+		{[]string{"SET", "k", "v", "PX", "1"}, "OK"},
+	}
+	for _, step := range steps {
+		if got := c.do(step.args...); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%q = %#v, want %#v", step.args, got, step.want)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := c.do("MGET", "k"); !reflect.DeepEqual(got, []any{nil}) {
+		t.Errorf("MGET of an expired key = %#v, want [nil]", got)
+	}
+}
+
+func TestExpirySurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	srv := newEmbeddedServer(t)
+	kv, err := srv.Client(client.Options{})
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	defer kv.Close()
+
+	before := newTestServer(t, srv)
+	c := dialTestServer(t, before)
+	for _, args := range [][]string{
+		{"SET", "soon", "v", "PX", "200"},
+		{"SET", "later", "v", "EX", "100"},
+		{"SET", "deleted", "v", "EX", "100"},
+	} {
+		if got := c.do(args...); got != "OK" {
+			t.Fatalf("%q = %#v", args, got)
+		}
+	}
+	before.Close()
+
+	if _, err := kv.Get(ctx, "resp-expiries", "default\x00later"); err != nil {
+		t.Errorf("expiry record: %v", err)
+	}
+
+	c = dialTestServer(t, newTestServer(t, srv))
+	if got := c.do("TTL", "later"); got != int64(100) {
+		t.Errorf("TTL after a restart = %#v, want 100", got)
+	}
+	if got := c.do("DEL", "deleted"); got != int64(1) {
+		t.Errorf("DEL = %#v, want 1", got)
+	}
+	if _, err := kv.Get(ctx, "resp-expiries", "default\x00deleted"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expiry record after DEL: %v, want ErrNotFound", err)
+	}
+
+	// The records are kept out of the database, so SCAN only returns keys
+	// that have not expired
+	time.Sleep(250 * time.Millisecond)
+	if got := c.scanAll(); !reflect.DeepEqual(got, [][]any{{"later"}}) {
+		t.Errorf("SCAN = %#v, want [[later]]", got)
+	}
+	if got := c.do("GET", "soon"); got != nil {
+		t.Errorf("GET of a key expired before a restart = %#v, want nil", got)
+	}
+	if _, err := kv.Get(ctx, "resp-expiries", "default\x00soon"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expiry record of an expired key: %v, want ErrNotFound", err)
+	}
+}
+
+func TestUnauthenticatedLimits(t *testing.T) {
+	srv := newEmbeddedServer(t)
+	cc, err := srv.Dial()
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer cc.Close()
+	s := resp.NewServer(pb.NewRocksDBServiceClient(cc), resp.Options{
+		Authenticate: func(token string) error {
+			if token != "s3cr3t" {
+				return errors.New("invalid token")
+			}
+			return nil
+		},
+	})
+	large := strings.Repeat("x", 100<<10)
+
+	c := dialTestServer(t, s)
+	if got := c.do("AUTH", "wrong"); got != respError("WRONGPASS invalid username-password pair or user is disabled") {
+		t.Errorf("AUTH with an invalid token = %#v", got)
+	}
+	// The length is rejected before the string is sent
+	if _, err := fmt.Fprintf(c.nc, "*2\r\n$4\r\nECHO\r\n$%d\r\n", len(large)); err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+	got, err := c.reply()
+	if reply, _ := got.(respError); err != nil || !strings.HasPrefix(string(reply), "ERR Protocol error: invalid length") {
+		t.Errorf("large ECHO before AUTH = %#v, %v; want a protocol error", got, err)
+	}
+
+	c = dialTestServer(t, s)
+	if got := c.do("AUTH", "user", "s3cr3t"); got != "OK" {
+		t.Fatalf("AUTH = %#v, want OK", got)
+	}
+	if got := c.do("ECHO", large); got != large {
+		t.Errorf("large ECHO after AUTH returned %d bytes, want %d", len(fmt.Sprint(got)), len(large))
+	}
+}
+
+func TestIncrRejectsCounters(t *testing.T) {
+	srv := newEmbeddedServer(t)
+	kv, err := srv.Client(client.Options{})
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	defer kv.Close()
+	if _, err := kv.Increment(context.Background(), "default", client.Increment{Key: "counter", Delta: 1}); err != nil {
+		t.Fatalf("Increment: %v", err)
+	}
+
+	c := dialTestServer(t, newTestServer(t, srv))
+	want := respError("ERR value is not a decimal integer; use the Increment RPC for 8-byte counters")
+	if got := c.do("INCR", "counter"); got != want {
+		t.Errorf("INCR of an Increment counter = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+)
+
+// conditionStatus converts a failed precondition into a FAILED_PRECONDITION
+// status carrying the current value. It returns nil for other errors.
+func conditionStatus(err error) error {
+	var cerr *db.ConditionError
+	if !errors.As(err, &cerr) {
+		return nil
+	}
+	st, detErr := status.New(codes.FailedPrecondition, cerr.Error()).WithDetails(&pb.ConditionFailure{
+		CurrentValue:   cerr.Value,
+		Found:          cerr.Found,
+		CurrentVersion: cerr.Version,
+	})
+	if detErr != nil {
+		return status.Error(codes.FailedPrecondition, cerr.Error())
+	}
+	return st.Err()
+}
+
+func (s *Server) CompareAndSwap(ctx context.Context, req *pb.CompareAndSwapRequest) (*pb.CompareAndSwapResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	var version uint64
+	if req.ExpectedVersion != nil {
+		version, err = database.PutIfVersion(ctx, req.Key, req.Value, *req.ExpectedVersion)
+	} else {
+		version, err = database.CompareAndSwap(ctx, req.Key, req.ExpectedValue, req.Value)
+	}
+	if st := conditionStatus(err); st != nil {
+		return nil, st
+	}
+	if err != nil {
+		return &pb.CompareAndSwapResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.CompareAndSwapResponse{Success: true, Version: version}, nil
+}
+
+func (s *Server) PutIfAbsent(ctx context.Context, req *pb.PutIfAbsentRequest) (*pb.PutIfAbsentResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	version, err := database.PutIfAbsent(ctx, req.Key, req.Value)
+	if st := conditionStatus(err); st != nil {
+		return nil, st
+	}
+	if err != nil {
+		return &pb.PutIfAbsentResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.PutIfAbsentResponse{Success: true, Version: version}, nil
+}
+
+func (s *Server) DeleteIfEquals(ctx context.Context, req *pb.DeleteIfEqualsRequest) (*pb.DeleteIfEqualsResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	err = database.DeleteIfEquals(ctx, req.Key, req.ExpectedValue)
+	if st := conditionStatus(err); st != nil {
+		return nil, st
+	}
+	if err != nil {
+		return &pb.DeleteIfEqualsResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.DeleteIfEqualsResponse{Success: true}, nil
+}
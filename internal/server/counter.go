@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+)
+
+// counterStatus converts an increment out of range into a
+// FAILED_PRECONDITION status carrying the current value of the counter. It
+// returns nil for other errors.
+func counterStatus(err error) error {
+	var rerr *db.CounterRangeError
+	if !errors.As(err, &rerr) {
+		return nil
+	}
+	failure := &pb.ConditionFailure{Found: rerr.Found, CurrentVersion: rerr.Version}
+	if rerr.Found {
+		failure.CurrentValue = db.EncodeCounter(rerr.Value)
+	}
+	st, detErr := status.New(codes.FailedPrecondition, rerr.Error()).WithDetails(failure)
+	if detErr != nil {
+		return status.Error(codes.FailedPrecondition, rerr.Error())
+	}
+	return st.Err()
+}
+
+func (s *Server) Increment(ctx context.Context, req *pb.IncrementRequest) (*pb.IncrementResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	counters, err := database.Increment(ctx, []db.Increment{{
+		Key:   req.Key,
+		Delta: req.Delta,
+		Min:   req.Min,
+		Max:   req.Max,
+	}})
+	if st := counterStatus(err); st != nil {
+		return nil, st
+	}
+	if err != nil {
+		return &pb.IncrementResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.IncrementResponse{Success: true, Value: counters[0].Value, Version: counters[0].Version}, nil
+}
+
+func (s *Server) IncrementMany(ctx context.Context, req *pb.IncrementManyRequest) (*pb.IncrementManyResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	increments := make([]db.Increment, len(req.Increments))
+	for i, inc := range req.Increments {
+		increments[i] = db.Increment{Key: inc.Key, Delta: inc.Delta, Min: inc.Min, Max: inc.Max}
+	}
+	counters, err := database.Increment(ctx, increments)
+	if st := counterStatus(err); st != nil {
+		return nil, st
+	}
+	if err != nil {
+		return &pb.IncrementManyResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	resp := &pb.IncrementManyResponse{Success: true, Counters: make([]*pb.Counter, len(counters))}
+	for i, c := range counters {
+		resp.Counters[i] = &pb.Counter{Key: c.Key, Value: c.Value, Version: c.Version}
+	}
+	return resp, nil
+}
+
+func (s *Server) GetCounter(ctx context.Context, req *pb.GetCounterRequest) (*pb.GetCounterResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	raw, version, found, err := database.GetVersioned(ctx, req.Key)
+	if err != nil {
+		return &pb.GetCounterResponse{Error: err.Error()}, nil
+	}
+	if !found {
+		return &pb.GetCounterResponse{}, nil
+	}
+	value, err := db.DecodeCounter(raw)
+	if err != nil {
+		return &pb.GetCounterResponse{Error: err.Error()}, nil
+	}
+	return &pb.GetCounterResponse{Value: value, Found: true, Version: version}, nil
+}
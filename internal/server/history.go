@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+)
+
+// historyStatus converts history errors into gRPC statuses. It returns nil
+// for other errors.
+func historyStatus(err error) error {
+	switch {
+	case errors.Is(err, db.ErrHistoryDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, db.ErrBeforeRetention):
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	return nil
+}
+
+// timestampProto converts t to a protobuf timestamp, leaving zero times unset
+func timestampProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func (s *Server) GetAsOf(ctx context.Context, req *pb.GetAsOfRequest) (*pb.GetAsOfResponse, error) {
+	if req.Timestamp == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "timestamp is required")
+	}
+
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	entry, found, err := database.GetAsOf(ctx, req.Key, req.Timestamp.AsTime())
+	if st := historyStatus(err); st != nil {
+		return nil, st
+	}
+	if err != nil {
+		return &pb.GetAsOfResponse{Found: false, Error: err.Error()}, nil
+	}
+	return &pb.GetAsOfResponse{
+		Value:      entry.Value,
+		Found:      found,
+		Version:    entry.Version,
+		CommitTime: timestampProto(entry.Timestamp),
+	}, nil
+}
+
+func (s *Server) GetHistory(req *pb.GetHistoryRequest, stream pb.RocksDBService_GetHistoryServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	ch := database.GetHistory(ctx, req.Key)
+	defer drain(cancel, ch)
+
+	for entry := range ch {
+		if entry.Err != nil {
+			if st := historyStatus(entry.Err); st != nil {
+				return st
+			}
+			return status.Errorf(codes.Internal, "stream error: %v", entry.Err)
+		}
+
+		err := stream.Send(&pb.GetHistoryResponse{
+			Value:          entry.Value,
+			Version:        entry.Version,
+			CommitTime:     timestampProto(entry.Timestamp),
+			SupersededTime: timestampProto(entry.SupersededAt),
+		})
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to send response: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+
+	pb "rocksdb-service/api/proto"
+)
+
+func (s *Server) RotateKey(ctx context.Context, req *pb.RotateKeyRequest) (*pb.RotateKeyResponse, error) {
+	id, err := s.dbManager.RotateKey(req.DatabaseName)
+	if err != nil {
+		return &pb.RotateKeyResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	return &pb.RotateKeyResponse{
+		Success: true,
+		JobId:   id,
+	}, nil
+}
+
+func (s *Server) ListJobs(ctx context.Context, req *pb.ListJobsRequest) (*pb.ListJobsResponse, error) {
+	resp := &pb.ListJobsResponse{}
+	for _, job := range s.dbManager.Jobs() {
+		if req.DatabaseName != "" && job.Database != req.DatabaseName {
+			continue
+		}
+		resp.Jobs = append(resp.Jobs, &pb.Job{
+			Id:           job.ID,
+			Kind:         job.Kind,
+			DatabaseName: job.Database,
+			State:        string(job.State),
+			Processed:    job.Processed,
+			Started:      timestampProto(job.Started),
+			Finished:     timestampProto(job.Finished),
+			Error:        job.Err,
+		})
+	}
+	return resp, nil
+}
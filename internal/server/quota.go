@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+	"rocksdb-service/internal/quota"
+)
+
+// StorageSize returns a function reporting the SST size of a database of
+// dbManager, for storage quotas. Only open databases are measured, so that
+// checking or reporting usage never creates a database.
+func StorageSize(dbManager *db.DBManager) func(name string) (uint64, error) {
+	return func(name string) (uint64, error) {
+		database, release, ok := dbManager.LookupDB(name)
+		if !ok {
+			return 0, fmt.Errorf("database %s is not open", name)
+		}
+		defer release()
+
+		return database.SSTSize(), nil
+	}
+}
+
+func usageProto(usage []quota.Usage) []*pb.Usage {
+	out := make([]*pb.Usage, len(usage))
+	for i, u := range usage {
+		out[i] = &pb.Usage{
+			Name:          u.Name,
+			Requests:      u.Requests,
+			BytesRead:     u.BytesRead,
+			BytesWritten:  u.BytesWritten,
+			ActiveStreams: uint32(u.ActiveStreams),
+			StorageBytes:  u.StorageBytes,
+			Limits: &pb.QuotaLimits{
+				RequestsPerSecond:   u.Limits.RequestsPerSecond,
+				ReadBytesPerSecond:  u.Limits.ReadBytesPerSecond,
+				WriteBytesPerSecond: u.Limits.WriteBytesPerSecond,
+				MaxStreams:          int32(u.Limits.MaxStreams),
+				MaxStorageBytes:     u.Limits.MaxStorageBytes,
+			},
+		}
+	}
+	return out
+}
+
+func (s *Server) GetUsage(ctx context.Context, req *pb.GetUsageRequest) (*pb.GetUsageResponse, error) {
+	if s.quotas == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "quotas are not enabled")
+	}
+
+	principals, databases := s.quotas.Usage(req.Principal, req.DatabaseName)
+	return &pb.GetUsageResponse{
+		Principals: usageProto(principals),
+		Databases:  usageProto(databases),
+	}, nil
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"rocksdb-service/internal/db"
+)
+
+func TestStorageSizeDoesNotCreateDatabases(t *testing.T) {
+	dbManager, err := db.NewDBManager(t.TempDir(), db.Options{Backend: db.BackendMemory})
+	if err != nil {
+		t.Fatalf("NewDBManager: %v", err)
+	}
+	defer dbManager.Close()
+	size := StorageSize(dbManager)
+
+	if _, err := size("missing"); err == nil {
+		t.Error("StorageSize of a database that is not open succeeded")
+	}
+	if infos, _ := dbManager.Databases(); len(infos) != 0 {
+		t.Errorf("StorageSize created databases: %+v", infos)
+	}
+
+	_, release, err := dbManager.GetDB(context.Background(), "open")
+	if err != nil {
+		t.Fatalf("GetDB: %v", err)
+	}
+	release()
+	if _, err := size("open"); err != nil {
+		t.Errorf("StorageSize of an open database: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+)
+
+func (s *Server) Scan(req *pb.ScanRequest, stream pb.RocksDBService_ScanServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	ch := database.Scan(ctx, db.ScanOptions{
+		Prefix:   req.Prefix,
+		Start:    req.StartKey,
+		End:      req.EndKey,
+		Limit:    req.Limit,
+		KeysOnly: req.KeysOnly,
+	})
+	defer drain(cancel, ch)
+	for pair := range ch {
+		resp := &pb.ScanResponse{
+			Key:     pair.Key,
+			Value:   pair.Value,
+			Version: pair.Version,
+		}
+		if pair.Err != nil {
+			if pair.Key == "" {
+				return status.Errorf(codes.Internal, "stream error: %v", pair.Err)
+			}
+			resp.Error = pair.Err.Error()
+		}
+		if err := stream.Send(resp); err != nil {
+			return status.Errorf(codes.Internal, "failed to send response: %v", err)
+		}
+	}
+	return status.FromContextError(ctx.Err()).Err()
+}
+
+func (s *Server) ListDatabases(ctx context.Context, req *pb.ListDatabasesRequest) (*pb.ListDatabasesResponse, error) {
+	infos, err := s.dbManager.Databases()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+
+	resp := &pb.ListDatabasesResponse{}
+	for _, info := range infos {
+		resp.Databases = append(resp.Databases, &pb.DatabaseInfo{
+			Name:        info.Name,
+			AliasTarget: info.AliasTarget,
+			Open:        info.Open,
+		})
+	}
+	return resp, nil
+}
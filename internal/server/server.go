@@ -0,0 +1,166 @@
+// Package server implements the RocksDB gRPC service on top of a
+// DBManager. Authentication, quotas and logging are added by interceptors
+// of the grpc.Server it is registered with.
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+	"rocksdb-service/internal/quota"
+)
+
+// Options configures a Server
+type Options struct {
+	// WriteBatchSize is the maximum number of mutations per StreamWrite
+	// batch, 1000 if zero
+	WriteBatchSize int
+	// WriteFlushInterval is the interval at which partial StreamWrite
+	// batches are committed, 100ms if zero
+	WriteFlushInterval time.Duration
+	// Quotas serves GetUsage, if set. Enforcing quotas additionally needs
+	// its interceptors.
+	Quotas *quota.Manager
+}
+
+// Server implements pb.RocksDBServiceServer
+type Server struct {
+	pb.UnimplementedRocksDBServiceServer
+	dbManager *db.DBManager
+	quotas    *quota.Manager
+
+	// StreamWrite batching
+	writeBatchSize     int
+	writeFlushInterval time.Duration
+}
+
+// New returns a server of the databases of dbManager
+func New(dbManager *db.DBManager, opts Options) *Server {
+	s := &Server{
+		dbManager:          dbManager,
+		quotas:             opts.Quotas,
+		writeBatchSize:     opts.WriteBatchSize,
+		writeFlushInterval: opts.WriteFlushInterval,
+	}
+	if s.writeBatchSize <= 0 {
+		s.writeBatchSize = 1000
+	}
+	if s.writeFlushInterval <= 0 {
+		s.writeFlushInterval = 100 * time.Millisecond
+	}
+	return s
+}
+
+func (s *Server) Put(ctx context.Context, req *pb.PutRequest) (*pb.PutResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	var version uint64
+	if req.IfVersion != nil {
+		version, err = database.PutIfVersion(ctx, req.Key, req.Value, *req.IfVersion)
+		if st := conditionStatus(err); st != nil {
+			return nil, st
+		}
+	} else {
+		version, err = database.Put(ctx, req.Key, req.Value)
+	}
+	if err != nil {
+		return &pb.PutResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.PutResponse{Success: true, Version: version}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	value, version, exists, err := database.GetVersioned(ctx, req.Key)
+	if err != nil {
+		return &pb.GetResponse{Found: false, Error: err.Error()}, nil
+	}
+	return &pb.GetResponse{Value: value, Found: exists, Version: version}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	err = database.Delete(ctx, req.Key)
+	if err != nil {
+		return &pb.DeleteResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.DeleteResponse{Success: true}, nil
+}
+
+// drain stops the producer behind ch and waits for it to finish, so that
+// its iterator is closed before the database reference is released
+func drain[T any](cancel context.CancelFunc, ch <-chan T) {
+	cancel()
+	for range ch {
+	}
+}
+
+func (s *Server) StreamGet(req *pb.StreamGetRequest, stream pb.RocksDBService_StreamGetServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	database, release, err := s.dbManager.GetDB(ctx, req.DatabaseName)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	var ch chan db.KeyValuePair
+
+	switch query := req.Query.(type) {
+	case *pb.StreamGetRequest_Prefix:
+		ch = database.GetByPrefix(ctx, query.Prefix)
+	case *pb.StreamGetRequest_Keys:
+		ch = database.GetMultiple(ctx, query.Keys.Keys)
+	default:
+		return fmt.Errorf("invalid query type")
+	}
+	defer drain(cancel, ch)
+
+	for pair := range ch {
+		if pair.Err != nil {
+			return status.Errorf(codes.Internal, "stream error: %v", pair.Err)
+		}
+
+		err := stream.Send(&pb.StreamGetResponse{
+			Key:     pair.Key,
+			Value:   pair.Value,
+			Version: pair.Version,
+		})
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to send response: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) SwapAlias(ctx context.Context, req *pb.SwapAliasRequest) (*pb.SwapAliasResponse, error) {
+	if req.Alias == "" || req.DatabaseName == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "alias and database name are required")
+	}
+
+	prev, err := s.dbManager.SwapAlias(ctx, req.Alias, req.DatabaseName)
+	if err != nil {
+		return &pb.SwapAliasResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.SwapAliasResponse{Success: true, PreviousDatabaseName: prev}, nil
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+)
+
+func (s *Server) GetStats(ctx context.Context, req *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
+	// Stats are read-only, so unknown databases are not created
+	database, release, err := s.dbManager.OpenDB(ctx, req.DatabaseName)
+	if errors.Is(err, db.ErrDatabaseNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	return &pb.GetStatsResponse{Properties: database.Stats()}, nil
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+)
+
+func TestGetStatsOfMissingDatabase(t *testing.T) {
+	dbManager, err := db.NewDBManager(t.TempDir(), db.Options{Backend: db.BackendMemory})
+	if err != nil {
+		t.Fatalf("NewDBManager: %v", err)
+	}
+	defer dbManager.Close()
+	s := New(dbManager, Options{})
+
+	_, err = s.GetStats(context.Background(), &pb.GetStatsRequest{DatabaseName: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetStats of a missing database: %v, want NotFound", err)
+	}
+	if infos, _ := dbManager.Databases(); len(infos) != 0 {
+		t.Errorf("GetStats created databases: %+v", infos)
+	}
+}
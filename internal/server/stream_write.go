@@ -0,0 +1,132 @@
+package server
+
+import (
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "rocksdb-service/api/proto"
+	"rocksdb-service/internal/db"
+)
+
+// StreamWrite groups incoming mutations into batches and acknowledges the
+// highest committed sequence after each batch. Batches are flushed when
+// full or every writeFlushInterval, so slow producers still get acks.
+// Reading from the stream pauses while a batch is written, which lets gRPC
+// flow control push back on fast producers.
+func (s *Server) StreamWrite(stream pb.RocksDBService_StreamWriteServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if first.DatabaseName == "" {
+		return status.Errorf(codes.InvalidArgument, "database name is required on the first message")
+	}
+
+	database, release, err := s.dbManager.GetDB(stream.Context(), first.DatabaseName)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to get database: %v", err)
+	}
+	defer release()
+
+	batchSize := s.writeBatchSize
+	if first.BatchSize > 0 && int(first.BatchSize) < batchSize {
+		batchSize = int(first.BatchSize)
+	}
+
+	ctx := stream.Context()
+	reqs := make(chan *pb.StreamWriteRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(reqs)
+		req := first
+		for {
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+			var err error
+			if req, err = stream.Recv(); err != nil {
+				if err != io.EOF {
+					recvErr <- err
+				}
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(s.writeFlushInterval)
+	defer ticker.Stop()
+
+	var (
+		batch     []db.Mutation
+		last      uint64
+		committed uint64
+	)
+	// flush writes the pending batch. A failed write is reported to the
+	// client before the stream ends with an error.
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := database.Write(ctx, batch); err != nil {
+			sendErr := stream.Send(&pb.StreamWriteResponse{
+				CommittedSequence: committed,
+				Error:             err.Error(),
+			})
+			if sendErr != nil {
+				return sendErr
+			}
+			return status.Errorf(codes.Internal, "failed to write batch: %v", err)
+		}
+		batch = batch[:0]
+		committed = last
+		return stream.Send(&pb.StreamWriteResponse{CommittedSequence: committed})
+	}
+
+	for {
+		select {
+		case req, ok := <-reqs:
+			if !ok {
+				if err := ctx.Err(); err != nil {
+					return status.FromContextError(err).Err()
+				}
+				select {
+				case err := <-recvErr:
+					return err
+				default:
+				}
+				return flush()
+			}
+
+			if req.DatabaseName != "" && req.DatabaseName != first.DatabaseName {
+				return status.Errorf(codes.InvalidArgument, "database cannot change within a stream")
+			}
+			seq := req.Sequence
+			if seq == 0 {
+				seq = last + 1
+			} else if seq <= last {
+				return status.Errorf(codes.InvalidArgument, "sequence %d is not greater than %d", seq, last)
+			}
+
+			batch = append(batch, db.Mutation{Key: req.Key, Value: req.Value, Delete: req.Delete})
+			last = seq
+			if len(batch) < batchSize {
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package tlsutil builds TLS configurations for the server and client,
+// reloading certificates when their files change.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"rocksdb-service/internal/filewatch"
+)
+
+// reloadCheckInterval bounds how often certificate files are checked for
+// changes. Tests shorten it.
+var reloadCheckInterval = time.Second
+
+// ServerConfig returns a TLS configuration serving the certificate in
+// certFile and keyFile. If clientCAFile is set, clients must present a
+// certificate signed by one of its CAs (mutual TLS). All files are reloaded
+// when they change on disk, so certificates can be rotated without a
+// restart.
+func ServerConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	r := &reloader{certFile: certFile, keyFile: keyFile, caFile: clientCAFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	files := []string{certFile, keyFile}
+	if clientCAFile != "" {
+		files = append(files, clientCAFile)
+	}
+	watcher, err := filewatch.New(reloadCheckInterval, files...)
+	if err != nil {
+		return nil, err
+	}
+	r.watcher = watcher
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.serverConfig(), nil
+		},
+	}, nil
+}
+
+// ClientConfig returns a TLS configuration verifying the server against the
+// CAs in caFile, or the system roots if empty, and presenting the client
+// certificate in certFile and keyFile if set
+func ClientConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := loadCAs(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// reloader holds the server certificate and client CAs, reloading them
+// when any of the files is modified
+type reloader struct {
+	certFile, keyFile, caFile string
+	watcher                   *filewatch.Watcher
+
+	mu   sync.Mutex
+	cert tls.Certificate
+	cas  *x509.CertPool
+}
+
+// load reads all files. Must be called with r.mu held or before r is shared.
+func (r *reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	var cas *x509.CertPool
+	if r.caFile != "" {
+		if cas, err = loadCAs(r.caFile); err != nil {
+			return err
+		}
+	}
+
+	r.cert, r.cas = cert, cas
+	return nil
+}
+
+// serverConfig returns the configuration for a new connection, reloading
+// the files first if they changed. A failed reload keeps serving the
+// previous certificates, since files are often replaced one at a time.
+func (r *reloader) serverConfig() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.watcher.Changed() {
+		if err := r.load(); err != nil {
+			log.Printf("Failed to reload TLS certificates: %v", err)
+		}
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{r.cert},
+	}
+	if r.cas != nil {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = r.cas
+	}
+	return cfg
+}
+
+func loadCAs(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,305 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a PEM certificate and key for localhost, usable by servers
+// and clients
+func (ca *testCA) issue(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile replaces a file, moving its modification time forward so the
+// change is seen even on coarse-grained file systems
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	mtime := time.Now()
+	if info, err := os.Stat(path); err == nil && !mtime.After(info.ModTime()) {
+		mtime = info.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testFiles holds the paths of a set of certificate files
+type testFiles struct {
+	serverCert, serverKey, clientCA string
+	clientCert, clientKey, serverCA string
+}
+
+func newTestFiles(t *testing.T, serverCA, clientCA *testCA) testFiles {
+	t.Helper()
+	dir := t.TempDir()
+	f := testFiles{
+		serverCert: filepath.Join(dir, "server.crt"),
+		serverKey:  filepath.Join(dir, "server.key"),
+		clientCA:   filepath.Join(dir, "client-ca.crt"),
+		clientCert: filepath.Join(dir, "client.crt"),
+		clientKey:  filepath.Join(dir, "client.key"),
+		serverCA:   filepath.Join(dir, "server-ca.crt"),
+	}
+	f.writeServer(t, serverCA)
+	f.writeClient(t, clientCA)
+	writeFile(t, f.clientCA, clientCA.pem)
+	writeFile(t, f.serverCA, serverCA.pem)
+	return f
+}
+
+func (f testFiles) writeServer(t *testing.T, ca *testCA) {
+	t.Helper()
+	cert, key := ca.issue(t)
+	writeFile(t, f.serverCert, cert)
+	writeFile(t, f.serverKey, key)
+}
+
+func (f testFiles) writeClient(t *testing.T, ca *testCA) {
+	t.Helper()
+	cert, key := ca.issue(t)
+	writeFile(t, f.clientCert, cert)
+	writeFile(t, f.clientKey, key)
+}
+
+// serve accepts TLS connections with cfg, greeting each client that
+// completes the handshake
+func serve(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if err := conn.(*tls.Conn).Handshake(); err == nil {
+					conn.Write([]byte("ok"))
+				}
+			}()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// dial connects to addr with cfg and reports whether the server accepted
+// the connection. With TLS 1.3, a rejected client certificate is only seen
+// on the first read.
+func dial(addr string, cfg *tls.Config) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", addr, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(conn, buf)
+	return err
+}
+
+func clientConfig(t *testing.T, caFile, certFile, keyFile string) *tls.Config {
+	t.Helper()
+	cfg, err := ClientConfig(caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestMutualTLS(t *testing.T) {
+	serverCA := newTestCA(t, "server CA")
+	clientCA := newTestCA(t, "client CA")
+	f := newTestFiles(t, serverCA, clientCA)
+
+	cfg, err := ServerConfig(f.serverCert, f.serverKey, f.clientCA)
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	addr := serve(t, cfg)
+
+	if err := dial(addr, clientConfig(t, f.serverCA, f.clientCert, f.clientKey)); err != nil {
+		t.Errorf("client with a trusted certificate was rejected: %v", err)
+	}
+	if err := dial(addr, clientConfig(t, f.serverCA, "", "")); err == nil {
+		t.Error("client without a certificate was accepted")
+	}
+
+	// A certificate from a CA the server doesn't trust
+	other := newTestFiles(t, serverCA, newTestCA(t, "other CA"))
+	if err := dial(addr, clientConfig(t, f.serverCA, other.clientCert, other.clientKey)); err == nil {
+		t.Error("client with an untrusted certificate was accepted")
+	}
+
+	// The client rejects a server it doesn't trust
+	if err := dial(addr, clientConfig(t, f.clientCA, f.clientCert, f.clientKey)); err == nil {
+		t.Error("client accepted an untrusted server certificate")
+	}
+}
+
+func TestServerTLSWithoutClientCA(t *testing.T) {
+	serverCA := newTestCA(t, "server CA")
+	f := newTestFiles(t, serverCA, newTestCA(t, "client CA"))
+
+	cfg, err := ServerConfig(f.serverCert, f.serverKey, "")
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	addr := serve(t, cfg)
+
+	if err := dial(addr, clientConfig(t, f.serverCA, "", "")); err != nil {
+		t.Errorf("client without a certificate was rejected: %v", err)
+	}
+}
+
+func TestServerConfigReload(t *testing.T) {
+	reloadCheckInterval = 10 * time.Millisecond
+	defer func() { reloadCheckInterval = time.Second }()
+
+	oldCA := newTestCA(t, "old CA")
+	newCA := newTestCA(t, "new CA")
+	f := newTestFiles(t, oldCA, oldCA)
+
+	cfg, err := ServerConfig(f.serverCert, f.serverKey, f.clientCA)
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	addr := serve(t, cfg)
+
+	oldClient := clientConfig(t, f.serverCA, f.clientCert, f.clientKey)
+	if err := dial(addr, oldClient); err != nil {
+		t.Fatalf("client was rejected before rotation: %v", err)
+	}
+
+	// Rotate everything to the new CA
+	f.writeServer(t, newCA)
+	f.writeClient(t, newCA)
+	writeFile(t, f.clientCA, newCA.pem)
+	writeFile(t, f.serverCA, newCA.pem)
+	newClient := clientConfig(t, f.serverCA, f.clientCert, f.clientKey)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		time.Sleep(2 * reloadCheckInterval)
+		err := dial(addr, newClient)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("client with rotated certificates was rejected: %v", err)
+		}
+	}
+	if err := dial(addr, oldClient); err == nil {
+		t.Error("client with certificates from before the rotation was accepted")
+	}
+}
+
+func TestServerConfigKeepsCertificateOnFailedReload(t *testing.T) {
+	reloadCheckInterval = 10 * time.Millisecond
+	defer func() { reloadCheckInterval = time.Second }()
+
+	ca := newTestCA(t, "CA")
+	f := newTestFiles(t, ca, ca)
+
+	cfg, err := ServerConfig(f.serverCert, f.serverKey, f.clientCA)
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	addr := serve(t, cfg)
+	client := clientConfig(t, f.serverCA, f.clientCert, f.clientKey)
+
+	// A certificate whose key hasn't been replaced yet doesn't load
+	cert, _ := ca.issue(t)
+	writeFile(t, f.serverCert, cert)
+	time.Sleep(2 * reloadCheckInterval)
+
+	if err := dial(addr, client); err != nil {
+		t.Errorf("client was rejected after a failed reload: %v", err)
+	}
+}